@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/avii09/hookit/pkg/config"
+	"github.com/avii09/hookit/pkg/pipeline"
+)
+
+// describeCommand prints the resolved execution plan of each config file.
+func describeCommand(args []string) int {
+	fs := flag.NewFlagSet("describe", flag.ContinueOnError)
+	var configs configList
+	fs.Var(&configs, "config", "Path to a pipeline config file (may be repeated)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: hookit describe -config <pipeline.yaml> [-config <pipeline.yaml> ...]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	configs = append(configs, fs.Args()...)
+
+	if len(configs) == 0 {
+		fmt.Fprintln(os.Stderr, "Error: Missing required flag '-config'. Use 'hookit describe -config path/to/pipeline.yaml'.")
+		return 2
+	}
+
+	exitCode := 0
+	for i, configFilePath := range configs {
+		if i > 0 {
+			fmt.Println()
+		}
+		cfg, err := config.LoadConfig(configFilePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: error loading config file: %v\n", configFilePath, err)
+			exitCode = 1
+			continue
+		}
+		plan, err := pipeline.NewPlan(cfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", configFilePath, err)
+			exitCode = 1
+			continue
+		}
+		fmt.Printf("%s:\n%s", configFilePath, plan)
+	}
+	return exitCode
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/avii09/hookit/pkg/pipeline"
+)
+
+// listCommand prints the registered sources, sinks and transformation stages.
+func listCommand(args []string) int {
+	if len(args) > 0 {
+		fmt.Println("Usage: hookit list")
+		return 2
+	}
+
+	fmt.Printf("Sources:    %s\n", strings.Join(pipeline.SourceTypes(), ", "))
+	fmt.Printf("Sinks:      %s\n", strings.Join(pipeline.SinkTypes(), ", "))
+	fmt.Printf("Transforms: %s\n", strings.Join(pipeline.TransformerTypes(), ", "))
+	return 0
+}
@@ -1,369 +1,43 @@
 package main
 
 import (
-	"context"
-	"encoding/json"
-	"flag"
 	"fmt"
-	"log"
 	"os"
-	"strings"
-
-	firebase "firebase.google.com/go"
-	"github.com/avii09/hookit/pkg/config"
-	"github.com/avii09/hookit/pkg/input"
-	"github.com/avii09/hookit/pkg/output"
-	"github.com/avii09/hookit/pkg/transform"
-	"google.golang.org/api/option"
 )
 
-func main() {
-	// Define the pipeline type flag
-	pipelineType := flag.String("pipeline", "", "Specify the pipeline type: csv, json, or firebase")
-	flag.Parse()
-
-	// Validate if the flag is provided
-	if *pipelineType == "" {
-		fmt.Println("Error: Missing required flag '-pipeline'. Use '-pipeline=csv', '-pipeline=json', or '-pipeline=firebase'.")
-		os.Exit(1)
-	}
-
-	// Determine configuration file based on the pipeline type
-	var configFilePath string
-	switch *pipelineType {
-	case "csv":
-		configFilePath = "pipelines/csv.yaml"
-	case "json":
-		configFilePath = "pipelines/json.yaml"
-	case "csv-to-json":
-		configFilePath = "pipelines/csv_to_json.yaml"
-	case "json-to-csv":
-		configFilePath = "pipelines/csv_to_json.yaml"
-	case "firebase":
-		configFilePath = "pipelines/firebase.yaml"
-	default:
-		fmt.Println("Error: Invalid pipeline type. Use '-pipeline=csv', '-pipeline=json', or '-pipeline=firebase'.")
-		os.Exit(1)
-	}
-
-	// Load the configuration file
-	cfg, err := config.LoadConfig(configFilePath)
-	if err != nil {
-		log.Fatalf("error loading config file: %v", err)
-	}
-
-	// Run the appropriate pipeline based on the flag
-	switch *pipelineType {
-	case "csv":
-		runCSVPipeline(cfg)
-	case "json":
-		runJSONPipeline(cfg)
-	case "csv-to-json":
-		runCSVToJSONPipeline(cfg)
-	case "json-to-csv":
-		runCSVToJSONPipeline(cfg)
-	case "firebase":
-		runFirebasePipeline(cfg)
-	}
-}
-
-func runCSVPipeline(cfg config.Config) {
-    var data []map[string]interface{}
-
-    // Determine input type and read data accordingly
-    switch cfg.Pipeline.Input.Type {
-    case "firebase":
-        // Initialize Firebase app
-        opt := option.WithCredentialsFile("firebase-adminsdk.json")
-        app, err := firebase.NewApp(context.Background(), nil, opt)
-        if err != nil {
-            log.Fatalf("error initializing Firebase app: %v", err)
-        }
-
-        // Initialize Firestore client
-        client, err := app.Firestore(context.Background())
-        if err != nil {
-            log.Fatalf("error initializing Firestore client: %v", err)
-        }
-        defer client.Close()
-
-        // Read data from Firebase
-        data, err = input.ReadFirebase(client, cfg.Pipeline.Input.Config.Collection)
-        if err != nil {
-            log.Fatalf("error reading data from Firebase: %v", err)
-        }
-    case "csv":
-        // Read data from CSV
-        csvData, err := input.ReadCSV(cfg.Pipeline.Input.Config.FilePath)
-        if err != nil {
-            log.Fatalf("error reading data from CSV: %v", err)
-        }
-        // Convert CSV data to map[string]interface{}
-        data = make([]map[string]interface{}, len(csvData))
-        for i, row := range csvData {
-            data[i] = make(map[string]interface{})
-            for key, value := range row {
-                data[i][key] = value
-            }
-        }
-    default:
-        log.Fatalf("unsupported input type: %v", cfg.Pipeline.Input.Type)
-    }
-
-    // Apply transformations
-    transformedData := transform.ApplyFirebaseTransformations(data, cfg.Pipeline.Transformations.Mapping)
-
-    // Determine output type and write data accordingly
-    switch cfg.Pipeline.Output.Type {
-    case "firebase":
-        // Initialize Firebase app
-        opt := option.WithCredentialsFile("firebase-adminsdk.json")
-        app, err := firebase.NewApp(context.Background(), nil, opt)
-        if err != nil {
-            log.Fatalf("error initializing Firebase app: %v", err)
-        }
-
-        // Initialize Firestore client
-        client, err := app.Firestore(context.Background())
-        if err != nil {
-            log.Fatalf("error initializing Firestore client: %v", err)
-        }
-        defer client.Close()
-
-        // Write transformed data back to Firebase
-        if err := output.WriteFirebase(client, cfg.Pipeline.Output.Config.Collection, transformedData); err != nil {
-            log.Fatalf("error writing data to Firebase: %v", err)
-        }
-        fmt.Println("Data transformed and written to Firebase successfully!")
-    case "json":
-        // Convert data to map[string]string for JSON output
-        stringData, err := input.ConvertMapToStringMap(transformedData)
-        if err != nil {
-            log.Fatalf("error converting data: %v", err)
-        }
-        // Write transformed data to JSON
-        if err := output.WriteJSON(cfg.Pipeline.Output.Config.FilePath, stringData); err != nil {
-            log.Fatalf("error writing data to JSON: %v", err)
-        }
-        fmt.Println("Data transformed and written to JSON successfully!")
-    case "csv":
-        // Convert data to map[string]string for CSV output
-        stringData, err := input.ConvertMapToStringMap(transformedData)
-        if err != nil {
-            log.Fatalf("error converting data: %v", err)
-        }
-        // Write transformed data to CSV
-        if err := output.WriteCSV(cfg.Pipeline.Output.Config.FilePath, stringData); err != nil {
-            log.Fatalf("error writing data to CSV: %v", err)
-        }
-        fmt.Println("Data transformed and written to CSV successfully!")
-    default:
-        log.Fatalf("unsupported output type: %v", cfg.Pipeline.Output.Type)
-    }
-}
-
-func runJSONPipeline(cfg config.Config) {
-    var data []map[string]interface{}
-    var err error
-
-    // Determine input type and read data accordingly
-    switch cfg.Pipeline.Input.Type {
-    case "firebase":
-        // Initialize Firebase app
-        opt := option.WithCredentialsFile("firebase-adminsdk.json")
-        app, err := firebase.NewApp(context.Background(), nil, opt)
-        if err != nil {
-            log.Fatalf("error initializing Firebase app: %v", err)
-        }
-
-        // Initialize Firestore client
-        client, err := app.Firestore(context.Background())
-        if err != nil {
-            log.Fatalf("error initializing Firestore client: %v", err)
-        }
-        defer client.Close()
+const usage = `Usage: hookit <command> [flags]
 
-        // Read data from Firebase
-        data, err = input.ReadFirebase(client, cfg.Pipeline.Input.Config.Collection)
-        if err != nil {
-            log.Fatalf("error reading data from Firebase: %v", err)
-        }
-    case "json":
-        // Read data from JSON
-        data, err = input.ReadJSON(cfg.Pipeline.Input.Config.FilePath)
-        if err != nil {
-            log.Fatalf("error reading data from JSON: %v", err)
-        }
-    default:
-        log.Fatalf("unsupported input type: %v", cfg.Pipeline.Input.Type)
-    }
+Commands:
+  run       Run one or more pipelines from their config files
+  validate  Check config files and report every problem found
+  list      Show the registered sources, sinks and transforms
+  describe  Print the resolved execution plan of a config file
+  init      Write a new pipeline config for an input/output pair
 
-    // Convert data to map[string]string
-    stringData, err := input.ConvertMapToStringMap(data)
-    if err != nil {
-        log.Fatalf("error converting data: %v", err)
-    }
+Run 'hookit <command> -h' for the flags of a command.
+`
 
-    // Apply transformations
-    transformedData := transform.ApplyTransformations(stringData, cfg.Pipeline.Transformations)
-
-    // Convert transformedData back to []map[string]interface{} for Firebase output
-    interfaceData := make([]map[string]interface{}, len(transformedData))
-    for i, row := range transformedData {
-        interfaceData[i] = make(map[string]interface{})
-        for key, value := range row {
-            interfaceData[i][key] = value
-        }
-    }
-
-    // Determine output type and write data accordingly
-    switch cfg.Pipeline.Output.Type {
-    case "firebase":
-        // Initialize Firebase app
-        opt := option.WithCredentialsFile("firebase-adminsdk.json")
-        app, err := firebase.NewApp(context.Background(), nil, opt)
-        if err != nil {
-            log.Fatalf("error initializing Firebase app: %v", err)
-        }
-
-        // Initialize Firestore client
-        client, err := app.Firestore(context.Background())
-        if err != nil {
-            log.Fatalf("error initializing Firestore client: %v", err)
-        }
-        defer client.Close()
-
-        // Write transformed data back to Firebase
-        if err := output.WriteFirebase(client, cfg.Pipeline.Output.Config.Collection, interfaceData); err != nil {
-            log.Fatalf("error writing data to Firebase: %v", err)
-        }
-        fmt.Println("Data transformed and written to Firebase successfully!")
-    case "json":
-        // Write transformed data to JSON
-        if err := output.WriteJSON(cfg.Pipeline.Output.Config.FilePath, transformedData); err != nil {
-            log.Fatalf("error writing data to JSON: %v", err)
-        }
-        fmt.Println("Data transformed and written to JSON successfully!")
-    default:
-        log.Fatalf("unsupported output type: %v", cfg.Pipeline.Output.Type)
-    }
-}
-
-func runCSVToJSONPipeline(cfg config.Config) {
-	inputFilePath := cfg.Pipeline.Input.Config.FilePath
-	if strings.HasSuffix(inputFilePath, ".csv") {
-		// CSV Input
-		fmt.Println("Detected CSV input file")
-		data, err := input.ReadCSV(cfg.Pipeline.Input.Config.FilePath)
-		if err != nil {
-			log.Fatalf("error reading data from CSV: %v", err)
-		}
-
-		// Apply transformations (including conversion to JSON)
-		transformedData := transform.ApplyTransformations(data, cfg.Pipeline.Transformations)
-
-		// Convert transformedData (of type []map[string]string) to []map[string]interface{}
-		interfaceData := make([]map[string]interface{}, len(transformedData))
-		for i, row := range transformedData {
-			interfaceData[i] = make(map[string]interface{})
-			for key, value := range row {
-				interfaceData[i][key] = value
-			}
-		}
-
-		// Convert transformed CSV data to JSON format
-		jsonData := transform.CSVToJSON(interfaceData)
-
-		var jsonMap []map[string]string
-		if err := json.Unmarshal(jsonData, &jsonMap); err != nil {
-			log.Fatalf("error unmarshalling JSON data: %v", err)
-		}
-
-		// Write transformed data to JSON
-		if err := output.WriteJSON(cfg.Pipeline.Output.Config.FilePath, jsonMap); err != nil {
-			log.Fatalf("error writing data to JSON: %v", err)
-		}
-
-		fmt.Println("CSV data successfully transformed to JSON and written!")
-	} else if strings.HasSuffix(inputFilePath, ".json") {
-		data, err := input.ReadJSON(cfg.Pipeline.Input.Config.FilePath)
-		if err != nil {
-			log.Fatalf("error reading data from JSON: %v", err)
-		}
-		stringData := make([]map[string]string, len(data))
-		for i, row := range data {
-			stringData[i] = make(map[string]string)
-			for key, value := range row {
-				stringData[i][key] = fmt.Sprintf("%v", value) // Convert interface{} to string
-			}
-		}
-		transformedData := transform.ApplyTransformations(stringData, cfg.Pipeline.Transformations)
-		if err := output.WriteCSV(cfg.Pipeline.Output.Config.FilePath, transformedData); err != nil {
-			log.Fatalf("error writing data to CSV: %v", err)
-		}
-		fmt.Println("Data transformed and written to CSV successfully!")
-	}
-}
-
-func runFirebasePipeline(cfg config.Config) {
-	// Initialize Firebase app
-	opt := option.WithCredentialsFile("firebase-adminsdk.json")
-	app, err := firebase.NewApp(context.Background(), nil, opt)
-	if err != nil {
-		log.Fatalf("Error initializing Firebase app: %v", err)
-	}
-
-	// Initialize Firestore client
-	client, err := app.Firestore(context.Background())
-	if err != nil {
-		log.Fatalf("Error initializing Firestore client: %v", err)
-	}
-	defer func() {
-		if cerr := client.Close(); cerr != nil {
-			log.Printf("Error closing Firestore client: %v", cerr)
-		}
-	}()
-
-	// Read data from Firebase
-	data, err := input.ReadFirebase(client, cfg.Pipeline.Input.Config.Collection)
-	if err != nil {
-		log.Fatalf("Error reading data from Firebase collection '%s': %v", cfg.Pipeline.Input.Config.Collection, err)
-	}
-
-	// Apply transformations
-	transformedData := transform.ApplyFirebaseTransformations(data, cfg.Pipeline.Transformations.Mapping)
-
-	// Determine output type and write data accordingly
-	switch cfg.Pipeline.Output.Type {
-	case "firebase":
-		// Write transformed data back to Firebase
-		if err := output.WriteFirebase(client, cfg.Pipeline.Output.Config.Collection, transformedData); err != nil {
-			log.Fatalf("Error writing transformed data to Firebase collection '%s': %v", cfg.Pipeline.Output.Config.Collection, err)
-		}
-		log.Println("Data transformed and written to Firebase successfully!")
-	case "json":
-		// Convert data to map[string]string for JSON output
-		stringData, err := input.ConvertMapToStringMap(transformedData)
-		if err != nil {
-			log.Fatalf("Error converting data for JSON output: %v", err)
-		}
-		// Write transformed data to JSON
-		if err := output.WriteJSON(cfg.Pipeline.Output.Config.FilePath, stringData); err != nil {
-			log.Fatalf("Error writing transformed data to JSON file '%s': %v", cfg.Pipeline.Output.Config.FilePath, err)
-		}
-		log.Println("Data transformed and written to JSON successfully!")
-	case "csv":
-		// Convert data to map[string]string for CSV output
-		stringData, err := input.ConvertMapToStringMap(transformedData)
-		if err != nil {
-			log.Fatalf("Error converting data for CSV output: %v", err)
-		}
-		// Write transformed data to CSV
-		if err := output.WriteCSV(cfg.Pipeline.Output.Config.FilePath, stringData); err != nil {
-			log.Fatalf("Error writing transformed data to CSV file '%s': %v", cfg.Pipeline.Output.Config.FilePath, err)
-		}
-		log.Println("Data transformed and written to CSV successfully!")
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "run":
+		os.Exit(runCommand(os.Args[2:]))
+	case "validate":
+		os.Exit(validateCommand(os.Args[2:]))
+	case "list":
+		os.Exit(listCommand(os.Args[2:]))
+	case "describe":
+		os.Exit(describeCommand(os.Args[2:]))
+	case "init":
+		os.Exit(initCommand(os.Args[2:]))
+	case "-h", "-help", "--help", "help":
+		fmt.Print(usage)
 	default:
-		log.Fatalf("Unsupported output type '%s'. Supported types are: 'firebase', 'json', 'csv'", cfg.Pipeline.Output.Type)
+		fmt.Fprintf(os.Stderr, "Error: unknown command '%s'.\n\n%s", os.Args[1], usage)
+		os.Exit(2)
 	}
 }
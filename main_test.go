@@ -0,0 +1,155 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/avii09/hookit/pkg/pipeline"
+)
+
+// Helper function to run a command and return what it printed and its exit code
+func captureOutput(t *testing.T, command func() int) (string, int) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	printed := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(r)
+		printed <- string(b)
+	}()
+	code := command()
+	os.Stdout = stdout
+	w.Close()
+	return <-printed, code
+}
+
+func TestValidateCommand(t *testing.T) {
+	quiet(t)
+	dir := t.TempDir()
+	good, _ := writePipeline(t, dir, "good", `[]`)
+	bad := filepath.Join(dir, "bad.yaml")
+	content := `pipeline:
+  input:
+    type: xml
+    config:
+      filePath: in.xml
+      delimiter: ab
+  output:
+    type: json
+`
+	if err := os.WriteFile(bad, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, code := captureOutput(t, func() int { return validateCommand([]string{"-config", good, bad}) })
+	if code != 1 {
+		t.Errorf("got exit code %d, want 1", code)
+	}
+	// Every problem is reported, in the order of the file
+	want := good + `: ok
+` + bad + `: 3 problem(s)
+  - ` + bad + `:3:5: unsupported input type "xml" (supported: ` + strings.Join(pipeline.SourceTypes(), ", ") + `)
+  - ` + bad + `:4:5: CSV settings such as delimiter and header only apply to type "csv"
+  - ` + bad + `:7:3: output type "json" requires field "config.filePath"
+`
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	if _, code := captureOutput(t, func() int { return validateCommand([]string{"-config", good}) }); code != 0 {
+		t.Errorf("valid config: got exit code %d, want 0", code)
+	}
+	if code := validateCommand(nil); code != 2 {
+		t.Errorf("no config: got exit code %d, want 2", code)
+	}
+}
+
+func TestDescribeCommand(t *testing.T) {
+	quiet(t)
+	dir := t.TempDir()
+	path, out := writePipeline(t, dir, "users", `[]`)
+
+	got, code := captureOutput(t, func() int { return describeCommand([]string{path, filepath.Join(dir, "missing.yaml")}) })
+	if code != 1 {
+		t.Errorf("got exit code %d, want 1", code)
+	}
+	want := path + `:
+1. read json file "` + filepath.Join(dir, "users.json") + `"
+2. write json file "` + out + `"
+
+`
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	// Describing a pipeline does not run it
+	if _, err := os.Stat(out); err == nil {
+		t.Error("describe wrote the output file")
+	}
+}
+
+func TestListCommand(t *testing.T) {
+	got, code := captureOutput(t, func() int { return listCommand(nil) })
+	if code != 0 {
+		t.Errorf("got exit code %d, want 0", code)
+	}
+	for _, line := range []string{
+		"Sources:    " + strings.Join(pipeline.SourceTypes(), ", "),
+		"Sinks:      " + strings.Join(pipeline.SinkTypes(), ", "),
+		"Transforms: " + strings.Join(pipeline.TransformerTypes(), ", "),
+	} {
+		if !strings.Contains(got, line+"\n") {
+			t.Errorf("got\n%s\nwant the line %q", got, line)
+		}
+	}
+}
+
+func TestInitCommand(t *testing.T) {
+	quiet(t)
+	dir := t.TempDir()
+
+	// Every input and output pair gives a valid config
+	for _, inputType := range pipeline.SourceTypes() {
+		for _, outputType := range pipeline.SinkTypes() {
+			path := filepath.Join(dir, inputType+"-"+outputType+".yaml")
+			_, code := captureOutput(t, func() int {
+				return initCommand([]string{"-input", inputType, "-output", outputType, "-o", path})
+			})
+			if code != 0 {
+				t.Errorf("%s to %s: got exit code %d, want 0", inputType, outputType, code)
+				continue
+			}
+			for _, err := range validateConfig(path) {
+				t.Errorf("%s to %s: %v", inputType, outputType, err)
+			}
+		}
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"existing file", []string{"-o", filepath.Join(dir, "csv-json.yaml")}, 1},
+		{"existing file with force", []string{"-force", "-o", filepath.Join(dir, "csv-json.yaml")}, 0},
+		{"unsupported input", []string{"-input", "xml"}, 2},
+		{"unsupported output", []string{"-output", "xml"}, 2},
+	}
+	for _, test := range tests {
+		if _, code := captureOutput(t, func() int { return initCommand(test.args) }); code != test.code {
+			t.Errorf("%s: got exit code %d, want %d", test.name, code, test.code)
+		}
+	}
+
+	// Without -o the config is printed
+	got, _ := captureOutput(t, func() int { return initCommand([]string{"-input", "json", "-output", "csv"}) })
+	if want := scaffoldConfig("json", "csv"); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
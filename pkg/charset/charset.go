@@ -0,0 +1,53 @@
+// Package charset looks up the text encodings that file inputs and outputs
+// can be read and written in.
+package charset
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// encodings maps the common encoding names to their encodings. "utf-16"
+// detects the byte order from a byte order mark, defaulting to little endian,
+// and writes one.
+var encodings = map[string]encoding.Encoding{
+	"utf-8":        unicode.UTF8,
+	"utf8":         unicode.UTF8,
+	"latin1":       charmap.ISO8859_1,
+	"latin-1":      charmap.ISO8859_1,
+	"iso-8859-1":   charmap.ISO8859_1,
+	"windows-1252": charmap.Windows1252,
+	"cp1252":       charmap.Windows1252,
+	"utf-16":       unicode.UTF16(unicode.LittleEndian, unicode.UseBOM),
+	"utf-16le":     unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM),
+	"utf-16be":     unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM),
+}
+
+// Lookup returns the encoding with the given name, such as "utf-8", "latin1",
+// "windows-1252", "utf-16", "utf-16le" or "utf-16be". Other names are looked
+// up among the encodings of the WHATWG Encoding Standard, such as
+// "shift_jis". An empty name means UTF-8.
+func Lookup(name string) (encoding.Encoding, error) {
+	key := strings.ToLower(strings.TrimSpace(name))
+	if key == "" {
+		return unicode.UTF8, nil
+	}
+	if enc, ok := encodings[key]; ok {
+		return enc, nil
+	}
+	if enc, err := htmlindex.Get(key); err == nil {
+		return enc, nil
+	}
+	return nil, fmt.Errorf("unsupported encoding %q (supported: utf-8, latin1, windows-1252, utf-16, utf-16le, utf-16be, or a WHATWG encoding name)", name)
+}
+
+// WritesBOM reports whether encoding text with the named encoding starts with
+// a byte order mark by itself, as "utf-16" does.
+func WritesBOM(name string) bool {
+	return strings.EqualFold(strings.TrimSpace(name), "utf-16")
+}
@@ -0,0 +1,53 @@
+package charset
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"", "café", "caf\xc3\xa9"},
+		{"UTF-8", "café", "caf\xc3\xa9"},
+		{"latin1", "café", "caf\xe9"},
+		{" ISO-8859-1 ", "café", "caf\xe9"},
+		{"windows-1252", "€1", "\x801"},
+		{"cp1252", "€1", "\x801"},
+		{"utf-16", "hi", "\xff\xfeh\x00i\x00"},
+		{"utf-16le", "hi", "h\x00i\x00"},
+		{"utf-16be", "hi", "\x00h\x00i"},
+		{"shift_jis", "日本", "\x93\xfa\x96{"},
+	}
+	for _, test := range tests {
+		enc, err := Lookup(test.name)
+		if err != nil {
+			t.Errorf("Lookup(%q): %v", test.name, err)
+			continue
+		}
+		got, err := enc.NewEncoder().String(test.text)
+		if err != nil {
+			t.Errorf("%q: encoding %q: %v", test.name, test.text, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%q: got %q, want %q", test.name, got, test.want)
+		}
+	}
+
+	_, err := Lookup("ebcdic")
+	if err == nil || !strings.HasPrefix(err.Error(), `unsupported encoding "ebcdic"`) {
+		t.Errorf("unknown encoding: got %v", err)
+	}
+}
+
+func TestWritesBOM(t *testing.T) {
+	for name, want := range map[string]bool{"utf-16": true, "UTF-16": true, "utf-16le": false, "utf-8": false, "": false} {
+		if got := WritesBOM(name); got != want {
+			t.Errorf("WritesBOM(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -1,44 +1,247 @@
 package config
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"os"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/avii09/hookit/pkg/transform"
-	"gopkg.in/yaml.v2"
+	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
 	Pipeline struct {
-		Input struct {
-			Type   string `yaml:"type"`
-			Config struct {
-				Collection string `yaml:"collection"`
-				FilePath   string `yaml:"filePath"`
-			} `yaml:"config"`
-		} `yaml:"input"`
-		Transformations transform.TransformationRules `yaml:"transformations"`
-		Output struct {
-			Type   string `yaml:"type"`
-			Config struct {
-				Collection string `yaml:"collection"`
-				FilePath   string `yaml:"filePath"`
-			} `yaml:"config"`
-		} `yaml:"output"`
+		Input           Endpoint                  `yaml:"input"`
+		Transformations transform.Transformations `yaml:"transformations"`
+		Output          Endpoint                  `yaml:"output"`
+		DeadLetter      *Endpoint                 `yaml:"dead_letter"` // where records that fail a stage are written
+		Execution       `yaml:",inline"`
 	} `yaml:"pipeline"`
+
+	// file and root are kept so that problems found after loading can be
+	// reported at their position in the YAML file.
+	file string
+	root *yaml.Node
+}
+
+// Endpoint describes a pipeline input or output and the type that handles it.
+type Endpoint struct {
+	Type   string         `yaml:"type"`
+	Config EndpointConfig `yaml:"config"`
+}
+
+// Execution controls how records are processed. With a parallelism above 1,
+// the stateless transformation stages (filters, mappings, set, flatten and
+// unflatten) run on that many worker goroutines, each handling a batch of
+// records at a time.
+type Execution struct {
+	Parallelism   int   `yaml:"parallelism"`
+	BatchSize     int   `yaml:"batch_size"`
+	PreserveOrder *bool `yaml:"preserve_order"` // defaults to true
+}
+
+// defaultBatchSize is the number of records a worker handles at a time.
+const defaultBatchSize = 100
+
+// BatchSizeOrDefault returns the configured batch size, or 100 when none is set.
+func (e Execution) BatchSizeOrDefault() int {
+	if e.BatchSize <= 0 {
+		return defaultBatchSize
+	}
+	return e.BatchSize
+}
+
+// PreserveOrderOrDefault reports whether records leave the workers in the
+// order they arrived, which is the default.
+func (e Execution) PreserveOrderOrDefault() bool {
+	return e.PreserveOrder == nil || *e.PreserveOrder
+}
+
+// EndpointConfig holds the settings shared by all input and output types.
+type EndpointConfig struct {
+	Collection  string `yaml:"collection"`
+	FilePath    string `yaml:"filePath"`
+	Credentials string `yaml:"credentials"` // Firebase service account key file
+	ProjectID   string `yaml:"project_id"`  // Firebase project, when not the one of the credentials
+	Append      bool   `yaml:"append"`      // jsonl output: add to the file instead of replacing it
+
+	CSV       CSVOptions       `yaml:",inline"`
+	Firestore FirestoreOptions `yaml:",inline"`
+}
+
+// FirestoreOptions holds the query settings of firebase inputs and the write
+// settings of firebase outputs.
+type FirestoreOptions struct {
+	CollectionGroup bool              `yaml:"collection_group"` // input: read every collection with the name, wherever it is nested
+	Where           []FirestoreFilter `yaml:"where"`            // input: conditions the documents must meet
+	OrderBy         []string          `yaml:"order_by"`         // input: fields to order by, such as "created_at desc"
+	Limit           int               `yaml:"limit"`            // input: the most documents to read
+	Select          []string          `yaml:"select"`           // input: the fields to read
+	PageSize        int               `yaml:"page_size"`        // input: read the documents in pages of this size
+	PathField       string            `yaml:"path_field"`       // input: field to hold the document path
+
+	Subcollections    []string `yaml:"subcollections"`     // input: subcollections to read under each document; "items/reviews" reads reviews under each item
+	SubcollectionMode string   `yaml:"subcollection_mode"` // input: nest (default) or flatten
+	ResolveReferences []string `yaml:"resolve_references"` // input: reference fields to replace with the documents they point to
+
+	IDField    string  `yaml:"id_field"`    // input: field to hold the document ID; output: field whose value is the document ID
+	WriteMode  string  `yaml:"write_mode"`  // output: add (default), create, set, merge or update
+	IDTemplate string  `yaml:"id_template"` // output: template for the document ID, such as "{region}-{id}"
+	BatchSize  int     `yaml:"batch_size"`  // output: write this many documents at a time in one atomic batch
+	RateLimit  float64 `yaml:"rate_limit"`  // output: the most documents written per second
+}
+
+// How the documents of subcollections are returned.
+const (
+	SubcollectionsNest    = "nest"    // as an array field of their parent
+	SubcollectionsFlatten = "flatten" // as records of their own, with the IDs of their parents
+)
+
+// SubcollectionModeOrDefault returns how the documents of subcollections are returned.
+func (o FirestoreOptions) SubcollectionModeOrDefault() string {
+	if o.SubcollectionMode == "" {
+		return SubcollectionsNest
+	}
+	return o.SubcollectionMode
+}
+
+// FirestoreFilter is a where clause of a firebase input, such as
+// {field: status, op: "==", value: shipped}.
+type FirestoreFilter struct {
+	Field string      `yaml:"field"`
+	Op    string      `yaml:"op"`
+	Value interface{} `yaml:"value"`
+}
+
+// IsZero reports whether every Firestore setting is left at its default.
+func (o FirestoreOptions) IsZero() bool {
+	return !o.CollectionGroup && len(o.Where) == 0 && len(o.OrderBy) == 0 && o.Limit == 0 &&
+		len(o.Select) == 0 && o.PageSize == 0 && o.PathField == "" && o.IDField == "" &&
+		len(o.Subcollections) == 0 && o.SubcollectionMode == "" && len(o.ResolveReferences) == 0 &&
+		o.WriteMode == "" && o.IDTemplate == "" && o.BatchSize == 0 && o.RateLimit == 0
+}
+
+// CSVOptions holds the dialect settings of csv inputs and outputs.
+type CSVOptions struct {
+	Delimiter    string   `yaml:"delimiter"`     // one character, or tab, comma, semicolon or pipe
+	Header       *bool    `yaml:"header"`        // whether the file has a header row; defaults to true
+	Columns      []string `yaml:"columns"`       // input: column names, used instead of the header row; output: the fields to write, in order
+	Comment      string   `yaml:"comment"`       // input: lines starting with this character are skipped
+	LazyQuotes   bool     `yaml:"lazy_quotes"`   // input: allow stray quotes
+	TrimSpace    bool     `yaml:"trim_space"`    // input: trim white space around fields
+	Encoding     string   `yaml:"encoding"`      // utf-8 (default), latin1, windows-1252, utf-16, utf-16le or utf-16be
+	BOM          bool     `yaml:"bom"`           // output: start the file with a byte order mark
+	MissingValue string   `yaml:"missing_value"` // output: the value of a column a record does not have
+
+	OnError     string `yaml:"on_error"`     // input: what to do with rows that cannot be parsed
+	RejectsFile string `yaml:"rejects_file"` // input: where rejected rows are written
+}
+
+// Policies for CSV rows that cannot be parsed.
+const (
+	OnErrorFail   = "fail"   // stop the pipeline (default)
+	OnErrorSkip   = "skip"   // log the row and go on
+	OnErrorReject = "reject" // write the row to the rejects file and go on
+)
+
+// OnErrorOrDefault returns the policy for rows that cannot be parsed.
+func (o CSVOptions) OnErrorOrDefault() string {
+	if o.OnError == "" {
+		return OnErrorFail
+	}
+	return o.OnError
+}
+
+// namedDelimiters maps the delimiter names accepted in configs to their characters.
+var namedDelimiters = map[string]rune{
+	"tab":       '\t',
+	"comma":     ',',
+	"semicolon": ';',
+	"pipe":      '|',
+}
+
+// DelimiterRune returns the field delimiter, or 0 for the default comma.
+func (o CSVOptions) DelimiterRune() (rune, error) {
+	if o.Delimiter == "" {
+		return 0, nil
+	}
+	if r, ok := namedDelimiters[strings.ToLower(o.Delimiter)]; ok {
+		return r, nil
+	}
+	return singleRune("delimiter", o.Delimiter)
+}
+
+// CommentRune returns the comment character, or 0 when comments are not skipped.
+func (o CSVOptions) CommentRune() (rune, error) {
+	if o.Comment == "" {
+		return 0, nil
+	}
+	return singleRune("comment", o.Comment)
 }
 
-// LoadConfig loads the configuration from a YAML file.
+// HasHeader reports whether the file has a header row, which is the default.
+func (o CSVOptions) HasHeader() bool {
+	return o.Header == nil || *o.Header
+}
+
+// IsZero reports whether every CSV setting is left at its default.
+func (o CSVOptions) IsZero() bool {
+	return o.Delimiter == "" && o.Header == nil && len(o.Columns) == 0 && o.Comment == "" &&
+		!o.LazyQuotes && !o.TrimSpace && o.Encoding == "" && !o.BOM && o.MissingValue == "" && o.OnError == "" && o.RejectsFile == ""
+}
+
+// Helper function to parse a setting that must be a single character usable in CSV
+func singleRune(name, s string) (rune, error) {
+	runes := []rune(s)
+	if len(runes) != 1 {
+		return 0, fmt.Errorf("%s must be a single character, got %q", name, s)
+	}
+	if r := runes[0]; r == '"' || r == '\r' || r == '\n' || r == utf8.RuneError {
+		return 0, fmt.Errorf("%s cannot be %q", name, s)
+	}
+	return runes[0], nil
+}
+
+// LoadConfig loads the configuration from a YAML file, expanding environment
+// variable and secret file references in its values (see interpolate).
+//
+// Unknown fields, missing required settings and invalid transformation rules
+// are reported as an Errors value listing every problem with its line and
+// column. In that case the decoded config is returned along with the error so
+// that callers can run further checks on it.
 func LoadConfig(filePath string) (Config, error) {
-	file, err := os.Open(filePath)
+	content, err := os.ReadFile(filePath)
 	if err != nil {
 		return Config{}, err
 	}
-	defer file.Close()
 
-	var config Config
-	decoder := yaml.NewDecoder(file)
-	if err := decoder.Decode(&config); err != nil {
-		return Config{}, err
+	var root yaml.Node
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	if err := decoder.Decode(&root); err != nil {
+		return Config{}, syntaxError(filePath, err)
+	}
+
+	// Expand ${VAR} references before decoding; a value that cannot be
+	// expanded is reported without stopping the other checks.
+	errs := interpolate(filePath, &root)
+
+	config := Config{file: filePath, root: &root}
+	if err := root.Decode(&config); err != nil {
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			return Config{}, syntaxError(filePath, err)
+		}
+		errs = append(errs, typeErrors(filePath, typeErr)...)
+	}
+
+	errs = append(errs, checkFields(filePath, &root, config)...)
+	errs = append(errs, config.validate()...)
+	if len(errs) > 0 {
+		errs.Sort()
+		return config, errs
 	}
 
 	return config, nil
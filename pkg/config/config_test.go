@@ -0,0 +1,472 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Helper function to load a config from YAML text, returning the errors with
+// the file called "pipeline.yaml"
+func loadErrors(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "pipeline.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := LoadConfig(path)
+	if err == nil {
+		return ""
+	}
+	return strings.ReplaceAll(err.Error(), path, "pipeline.yaml")
+}
+
+// minimalPipeline is a valid config that the tests add to.
+const minimalPipeline = `pipeline:
+  input:
+    type: json
+    config:
+      filePath: in.json
+  output:
+    type: json
+    config:
+      filePath: out.json
+`
+
+func TestLoadConfig(t *testing.T) {
+	if errs := loadErrors(t, minimalPipeline); errs != "" {
+		t.Fatalf("minimal pipeline: %s", errs)
+	}
+}
+
+func TestUnknownFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			"top level",
+			minimalPipeline + "pipline: {}\n",
+			`pipeline.yaml:10:1: unknown field "pipline" in the top level (allowed: pipeline)`,
+		},
+		{
+			"endpoint config",
+			strings.Replace(minimalPipeline, "filePath: in.json", "file_path: in.json", 1),
+			`pipeline.yaml:4:5: input type "json" requires field "config.filePath"
+pipeline.yaml:5:7: unknown field "file_path" in pipeline.input.config (allowed: append, batch_size, bom, collection, collection_group, columns, comment, credentials, delimiter, encoding, filePath, header, id_field, id_template, lazy_quotes, limit, missing_value, on_error, order_by, page_size, path_field, project_id, rate_limit, rejects_file, resolve_references, select, subcollection_mode, subcollections, trim_space, where, write_mode)`,
+		},
+		{
+			"transformation rule",
+			minimalPipeline + `  transformations:
+    filter:
+      - column: age
+        conditon: "> 30"
+`,
+			`pipeline.yaml:12:9: invalid filter condition: expression is empty
+pipeline.yaml:13:9: unknown field "conditon" in pipeline.transformations.filter.0 (allowed: column, condition)`,
+		},
+		{
+			"transformation step",
+			minimalPipeline + `  transformations:
+    - type: set
+      field: total
+      exprs: "a * b"
+`,
+			`pipeline.yaml:13:7: unknown field "exprs" in pipeline.transformations.0 (allowed: expr, field, template, value, when)`,
+		},
+		{
+			"aggregation step",
+			minimalPipeline + `  transformations:
+    - type: aggregation
+      group_by: [region]
+      aggregation:
+        - {operation: sum, column: amount, as: total}
+      filter:
+        - condition: "total > 0"
+`,
+			`pipeline.yaml:15:7: unknown field "filter" in pipeline.transformations.0 (allowed: aggregation, aggregation_mode, group_by)`,
+		},
+		{
+			"unsupported step types",
+			minimalPipeline + `  transformations:
+    - type: rename
+      from: amt
+    - type: derive
+    - type: cast
+    - type: sort
+      by: id
+`,
+			`pipeline.yaml:11:7: unsupported transformation type "rename", use a mapping step with custom_mapping
+pipeline.yaml:13:7: unsupported transformation type "derive", use a set step with an expr
+pipeline.yaml:14:7: unsupported transformation type "cast", use a set step with an expr, such as "amount * 1" for a number
+pipeline.yaml:15:7: unsupported transformation type "sort" (supported: filter, mapping, set, aggregation, flatten, unflatten)`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := loadErrors(t, test.content); got != test.want {
+				t.Errorf("got\n%s\nwant\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRequiredFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			"missing type",
+			`pipeline:
+  input:
+    config:
+      filePath: in.json
+  output:
+    type: json
+    config:
+      filePath: out.json
+`,
+			`pipeline.yaml:2:3: missing required field "type"`,
+		},
+		{
+			"csv needs a file",
+			`pipeline:
+  input:
+    type: csv
+  output:
+    type: json
+    config:
+      filePath: out.json
+`,
+			`pipeline.yaml:2:3: input type "csv" requires field "config.filePath"`,
+		},
+		{
+			"firebase needs a collection",
+			`pipeline:
+  input:
+    type: json
+    config:
+      filePath: in.json
+  output:
+    type: firebase
+    config:
+      credentials: key.json
+`,
+			`pipeline.yaml:8:5: output type "firebase" requires field "config.collection"`,
+		},
+		{
+			"transformation step needs a type",
+			minimalPipeline + `  transformations:
+    - condition: "age > 30"
+`,
+			`pipeline.yaml:11:7: transformation step is missing required field "type"`,
+		},
+		{
+			"aggregation rule",
+			minimalPipeline + `  transformations:
+    aggregation:
+      - operation: percentile
+`,
+			`pipeline.yaml:12:9: aggregation rule with operation "percentile" is missing required field "percentile"
+pipeline.yaml:12:9: aggregation rule is missing required field "column"
+pipeline.yaml:12:9: aggregation rule is missing required field "as"`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := loadErrors(t, test.content); got != test.want {
+				t.Errorf("got\n%s\nwant\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestValidationPositions(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			"expression error points inside the value",
+			minimalPipeline + `  transformations:
+    filter:
+      - condition: "age >> 30"
+`,
+			`pipeline.yaml:12:26: invalid filter condition: missing value before ">"`,
+		},
+		{
+			"setting on the wrong side",
+			`pipeline:
+  input:
+    type: json
+    config:
+      filePath: in.json
+  output:
+    type: csv
+    config:
+      filePath: out.csv
+      comment: "#"
+`,
+			`pipeline.yaml:10:7: comment only applies to csv inputs`,
+		},
+		{
+			"subcollection without its parent",
+			`pipeline:
+  input:
+    type: firebase
+    config:
+      collection: orders
+      subcollections: ["items/reviews"]
+  output:
+    type: json
+    config:
+      filePath: out.json
+`,
+			`pipeline.yaml:6:24: subcollection "items/reviews" is listed without its parent "items"`,
+		},
+		{
+			"every error is reported, in file order",
+			`pipeline:
+  input:
+    type: csv
+    config:
+      filePath: in.csv
+      delimiter: "ab"
+  output:
+    type: json
+    config:
+      filePath: out.json
+  parallelism: -1
+`,
+			`pipeline.yaml:6:7: delimiter must be a single character, got "ab"
+pipeline.yaml:11:3: parallelism must not be negative, got -1`,
+		},
+		{
+			"syntax error",
+			"pipeline:\n  input: [\n",
+			`pipeline.yaml:2: did not find expected node content`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := loadErrors(t, test.content); got != test.want {
+				t.Errorf("got\n%s\nwant\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+// firebaseOutput is a valid config with a firebase output, whose settings the tests add to.
+const firebaseOutput = `pipeline:
+  input:
+    type: json
+    config:
+      filePath: in.json
+  output:
+    type: firebase
+    config:
+      collection: users
+`
+
+func TestFirestoreWriteSettings(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			"valid",
+			firebaseOutput + `      write_mode: set
+      id_template: "{region}-{id}"
+      batch_size: 100
+      rate_limit: 50
+`,
+			"",
+		},
+		{
+			"create with generated IDs",
+			firebaseOutput + `      write_mode: create
+`,
+			"",
+		},
+		{
+			"unknown write mode",
+			firebaseOutput + `      write_mode: upsert
+      id_field: id
+`,
+			`pipeline.yaml:10:7: unknown write_mode "upsert" (supported: add, create, set, merge, update)`,
+		},
+		{
+			// add is the default write mode
+			"add with an ID",
+			firebaseOutput + `      id_field: id
+`,
+			`pipeline.yaml:8:5: write_mode "add" generates document IDs; use "create" to write documents with id_field or id_template`,
+		},
+		{
+			"merge without an ID",
+			firebaseOutput + `      write_mode: merge
+`,
+			`pipeline.yaml:10:7: write_mode "merge" requires id_field or id_template`,
+		},
+		{
+			"both ID settings",
+			firebaseOutput + `      write_mode: set
+      id_field: id
+      id_template: "{id}"
+`,
+			`pipeline.yaml:12:7: id_field and id_template cannot both be set`,
+		},
+		{
+			"invalid ID template",
+			firebaseOutput + `      write_mode: set
+      id_template: "{region"
+`,
+			`pipeline.yaml:11:7: invalid template "{region": unclosed "{"`,
+		},
+		{
+			"invalid ID field",
+			firebaseOutput + `      write_mode: set
+      id_field: "a..b"
+`,
+			`pipeline.yaml:11:7: invalid path "a..b": empty field name at offset 2`,
+		},
+		{
+			"batch size and rate limit",
+			firebaseOutput + `      batch_size: 501
+      rate_limit: -1
+`,
+			`pipeline.yaml:10:7: batch_size must be between 1 and 500, got 501
+pipeline.yaml:11:7: rate_limit must not be negative, got -1`,
+		},
+		{
+			"write settings on an input",
+			`pipeline:
+  input:
+    type: firebase
+    config:
+      collection: users
+      write_mode: set
+  output:
+    type: json
+    config:
+      filePath: out.json
+`,
+			`pipeline.yaml:6:7: write_mode only applies to firebase outputs`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := loadErrors(t, test.content); got != test.want {
+				t.Errorf("got\n%s\nwant\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+// firebaseInput is a valid config with a firebase input, whose settings the tests add to.
+const firebaseInput = `pipeline:
+  input:
+    type: firebase
+    config:
+      collection: orders
+  output:
+    type: json
+    config:
+      filePath: out.json
+`
+
+func TestFirestoreQuerySettings(t *testing.T) {
+	// The query settings go under the input config, from line 6
+	withQuery := func(settings string) string {
+		return strings.Replace(firebaseInput, "      collection: orders\n", "      collection: orders\n"+settings, 1)
+	}
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			"valid",
+			withQuery(`      collection_group: true
+      where:
+        - field: status
+          op: "=="
+          value: shipped
+        - field: total
+          op: ">="
+          value: 10
+      order_by: ["total desc", "created_at"]
+      limit: 100
+      select: [status, total, address.city]
+      page_size: 20
+      id_field: _id
+      path_field: _path
+`),
+			"",
+		},
+		{
+			"where clauses",
+			withQuery(`      where:
+        - op: "=="
+          value: x
+        - field: "a..b"
+          op: "=="
+        - field: status
+          op: "like"
+`),
+			`pipeline.yaml:7:11: where clause is missing required field "field"
+pipeline.yaml:9:11: invalid path "a..b": empty field name at offset 2
+pipeline.yaml:12:11: unknown operator "like" (supported: ==, !=, <, <=, >, >=, in, not-in, array-contains, array-contains-any)`,
+		},
+		{
+			"order, select, limit and page size",
+			withQuery(`      order_by: ["total sideways"]
+      select: ["a..b"]
+      limit: -1
+      page_size: -5
+`),
+			`pipeline.yaml:6:18: invalid order "total sideways" (expected "field", "field asc" or "field desc")
+pipeline.yaml:7:16: invalid path "a..b": empty field name at offset 2
+pipeline.yaml:8:7: limit must not be negative, got -1
+pipeline.yaml:9:7: page_size must not be negative, got -5`,
+		},
+		{
+			"same metadata field",
+			withQuery(`      id_field: _id
+      path_field: _id
+`),
+			`pipeline.yaml:7:7: id_field and path_field must differ`,
+		},
+		{
+			"query settings on an output",
+			`pipeline:
+  input:
+    type: json
+    config:
+      filePath: in.json
+  output:
+    type: firebase
+    config:
+      collection: orders
+      where:
+        - field: status
+          op: "=="
+          value: shipped
+`,
+			`pipeline.yaml:10:7: where only applies to firebase inputs`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := loadErrors(t, test.content); got != test.want {
+				t.Errorf("got\n%s\nwant\n%s", got, test.want)
+			}
+		})
+	}
+}
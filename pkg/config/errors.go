@@ -0,0 +1,133 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Error describes a problem at a position in a config file.
+type Error struct {
+	File   string
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *Error) Error() string {
+	switch {
+	case e.Line > 0 && e.Column > 0:
+		return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Msg)
+	case e.Line > 0:
+		return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Msg)
+	default:
+		return fmt.Sprintf("%s: %s", e.File, e.Msg)
+	}
+}
+
+// Errors lists every problem found in a config file.
+type Errors []*Error
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Sort orders the errors by their position in the file.
+func (e Errors) Sort() {
+	sort.SliceStable(e, func(i, j int) bool {
+		if e[i].Line != e[j].Line {
+			return e[i].Line < e[j].Line
+		}
+		return e[i].Column < e[j].Column
+	})
+}
+
+// Errorf returns an error positioned at the field with the given dotted path,
+// such as "pipeline.input.type" or "pipeline.transformations.filter.0.condition".
+// If the field is missing from the file, the error points at the closest
+// enclosing field that is present.
+func (c Config) Errorf(path string, format string, args ...interface{}) *Error {
+	err := &Error{File: c.file, Msg: fmt.Sprintf(format, args...)}
+	if node := c.lookup(path); node != nil {
+		err.Line, err.Column = node.Line, node.Column
+	}
+	return err
+}
+
+// Helper function to find the key node of a dotted path, or of its closest present parent
+func (c Config) lookup(path string) *yaml.Node {
+	key, _ := c.find(path)
+	return key
+}
+
+// Helper function to find the value node of a dotted path, or nil if it is missing
+func (c Config) lookupValue(path string) *yaml.Node {
+	_, value := c.find(path)
+	return value
+}
+
+// Helper function to walk a dotted path, returning the key node of the deepest
+// field found and the value node when the whole path is present
+func (c Config) find(path string) (*yaml.Node, *yaml.Node) {
+	if c.root == nil {
+		return nil, nil
+	}
+	node := c.root
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	// found is the key node of the deepest field found so far, which is
+	// where errors about the field itself should point.
+	found := node
+	for _, part := range strings.Split(path, ".") {
+		var next, key *yaml.Node
+		switch node.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == part {
+					key, next = node.Content[i], node.Content[i+1]
+					break
+				}
+			}
+		case yaml.SequenceNode:
+			if index, err := strconv.Atoi(part); err == nil && index >= 0 && index < len(node.Content) {
+				key, next = node.Content[index], node.Content[index]
+			}
+		}
+		if next == nil {
+			return found, nil
+		}
+		node, found = next, key
+	}
+	return found, node
+}
+
+// lineRe matches the "line N: " prefix of yaml.v3 error messages.
+var lineRe = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// Helper function to convert a YAML syntax error into a positioned error
+func syntaxError(file string, err error) *Error {
+	msg := err.Error()
+	if m := lineRe.FindStringSubmatch(msg); m != nil {
+		line, _ := strconv.Atoi(m[1])
+		return &Error{File: file, Line: line, Msg: m[2]}
+	}
+	return &Error{File: file, Msg: strings.TrimPrefix(msg, "yaml: ")}
+}
+
+// Helper function to convert YAML type errors into positioned errors
+func typeErrors(file string, typeErr *yaml.TypeError) Errors {
+	var errs Errors
+	for _, msg := range typeErr.Errors {
+		errs = append(errs, syntaxError(file, fmt.Errorf("%s", msg)))
+	}
+	return errs
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// interpolate expands variable references in every scalar value of the
+// document before it is decoded:
+//
+//	${VAR}           the value of the environment variable VAR, which may be empty
+//	${VAR:-default}  the value of VAR, or default when VAR is unset or empty
+//	${file:PATH}     the contents of the file at PATH, without trailing newlines
+//	$$               a literal $
+//
+// When VAR is unset but VAR_FILE is set, the contents of the file named by
+// VAR_FILE are used instead, so secrets can be mounted as files.
+func interpolate(file string, node *yaml.Node) Errors {
+	var errs Errors
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if !strings.Contains(node.Value, "$") {
+			return nil
+		}
+		value, err := expand(node.Value)
+		if err != nil {
+			return Errors{{File: file, Line: node.Line, Column: node.Column, Msg: err.Error()}}
+		}
+		node.Value = value
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			errs = append(errs, interpolate(file, child)...)
+		}
+	case yaml.MappingNode:
+		// Only values are expanded; keys must match the config fields.
+		for i := 1; i < len(node.Content); i += 2 {
+			errs = append(errs, interpolate(file, node.Content[i])...)
+		}
+	}
+	return errs
+}
+
+// Helper function to expand the variable references in a single value
+func expand(s string) (string, error) {
+	var b strings.Builder
+	for {
+		i := strings.IndexByte(s, '$')
+		if i < 0 || i == len(s)-1 {
+			b.WriteString(s)
+			return b.String(), nil
+		}
+		b.WriteString(s[:i])
+		switch s[i+1] {
+		case '$':
+			b.WriteByte('$')
+			s = s[i+2:]
+		case '{':
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated variable reference in %q", s[i:])
+			}
+			value, err := resolve(s[i+2 : i+end])
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(value)
+			s = s[i+end+1:]
+		default:
+			b.WriteByte('$')
+			s = s[i+1:]
+		}
+	}
+}
+
+// Helper function to resolve the expression inside ${...}
+func resolve(expr string) (string, error) {
+	if path, ok := strings.CutPrefix(expr, "file:"); ok {
+		return readSecret(strings.TrimSpace(path))
+	}
+
+	name, def, hasDefault := strings.Cut(expr, ":-")
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("empty variable name in ${%s}", expr)
+	}
+
+	value, set := os.LookupEnv(name)
+	if value != "" {
+		return value, nil
+	}
+	if !set {
+		if path := os.Getenv(name + "_FILE"); path != "" {
+			return readSecret(path)
+		}
+	}
+	switch {
+	case hasDefault:
+		return def, nil
+	case set:
+		return "", nil
+	}
+	return "", fmt.Errorf("environment variable %s is not set (use ${%s:-default} to provide a default)", name, name)
+}
+
+// Helper function to read a secret from a file
+func readSecret(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("error reading secret file: %v", err)
+	}
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
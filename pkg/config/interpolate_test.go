@@ -0,0 +1,93 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "secret")
+	if err := os.WriteFile(secret, []byte("s3cret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HOOKIT_NAME", "orders")
+	t.Setenv("HOOKIT_EMPTY", "")
+	t.Setenv("HOOKIT_KEY_FILE", secret)
+	t.Setenv("HOOKIT_SET_FILE", secret)
+	t.Setenv("HOOKIT_SET", "env")
+	t.Setenv("HOOKIT_BLANK_FILE", secret)
+	t.Setenv("HOOKIT_BLANK", "")
+	os.Unsetenv("HOOKIT_UNSET")
+
+	tests := []struct {
+		value string
+		want  string
+		err   string
+	}{
+		{"${HOOKIT_NAME}", "orders", ""},
+		{"data/${HOOKIT_NAME}.json", "data/orders.json", ""},
+		{"${ HOOKIT_NAME }", "orders", ""},
+		{"${HOOKIT_NAME}-${HOOKIT_NAME}", "orders-orders", ""},
+		{"${HOOKIT_EMPTY}", "", ""},
+		{"${HOOKIT_UNSET:-fallback}", "fallback", ""},
+		{"${HOOKIT_EMPTY:-fallback}", "fallback", ""},
+		{"${HOOKIT_NAME:-fallback}", "orders", ""},
+		{"${HOOKIT_UNSET:-}", "", ""},
+		{"${HOOKIT_UNSET:-a:-b}", "a:-b", ""},
+		{"${HOOKIT_UNSET}", "", "environment variable HOOKIT_UNSET is not set (use ${HOOKIT_UNSET:-default} to provide a default)"},
+		{"${}", "", "empty variable name in ${}"},
+		{"${HOOKIT_NAME", "", `unterminated variable reference in "${HOOKIT_NAME"`},
+
+		// Secret files
+		{"${file:" + secret + "}", "s3cret", ""},
+		{"${file: " + secret + " }", "s3cret", ""},
+		{"${HOOKIT_KEY}", "s3cret", ""},
+		{"${HOOKIT_SET}", "env", ""},
+		{"${HOOKIT_BLANK}", "", ""},
+		{"${file:" + filepath.Join(dir, "missing") + "}", "", "error reading secret file: "},
+
+		// Escaping
+		{"$$", "$", ""},
+		{"$${HOOKIT_NAME}", "${HOOKIT_NAME}", ""},
+		{"$$$${HOOKIT_NAME}", "$${HOOKIT_NAME}", ""},
+		{"$$${HOOKIT_NAME}", "$orders", ""},
+		{"cost: 5$", "cost: 5$", ""},
+		{"$HOOKIT_NAME", "$HOOKIT_NAME", ""},
+	}
+	for _, test := range tests {
+		got, err := expand(test.value)
+		if test.err != "" {
+			if err == nil || !strings.HasPrefix(err.Error(), test.err) {
+				t.Errorf("expand(%q): got error %v, want %s", test.value, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("expand(%q): %v", test.value, err)
+		} else if got != test.want {
+			t.Errorf("expand(%q) = %q, want %q", test.value, got, test.want)
+		}
+	}
+}
+
+func TestInterpolateConfig(t *testing.T) {
+	t.Setenv("HOOKIT_INPUT", "in.json")
+	os.Unsetenv("HOOKIT_OUTPUT")
+	got := loadErrors(t, `pipeline:
+  input:
+    type: json
+    config:
+      filePath: ${HOOKIT_INPUT}
+  output:
+    type: json
+    config:
+      filePath: ${HOOKIT_OUTPUT}
+`)
+	want := `pipeline.yaml:9:17: environment variable HOOKIT_OUTPUT is not set (use ${HOOKIT_OUTPUT:-default} to provide a default)`
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,651 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/avii09/hookit/pkg/charset"
+	"github.com/avii09/hookit/pkg/expr"
+	"github.com/avii09/hookit/pkg/input"
+	"github.com/avii09/hookit/pkg/output"
+	"github.com/avii09/hookit/pkg/record"
+	"github.com/avii09/hookit/pkg/transform"
+	"gopkg.in/yaml.v3"
+)
+
+// requiredSettings lists the config settings that each input and output type needs.
+var requiredSettings = map[string][]string{
+	"csv":      {"filePath"},
+	"json":     {"filePath"},
+	"jsonl":    {"filePath"},
+	"firebase": {"collection"},
+}
+
+// validate checks the decoded config for missing settings and invalid
+// transformation rules.
+func (c Config) validate() Errors {
+	var errs Errors
+	errs = append(errs, c.validateEndpoint("pipeline.input", c.Pipeline.Input)...)
+	errs = append(errs, c.validateEndpoint("pipeline.output", c.Pipeline.Output)...)
+	if c.Pipeline.DeadLetter != nil {
+		errs = append(errs, c.validateEndpoint("pipeline.dead_letter", *c.Pipeline.DeadLetter)...)
+	}
+
+	transformations := c.Pipeline.Transformations
+	if transformations.Steps == nil {
+		errs = append(errs, c.validateRules(transformations.TransformationRules, func(field string) string {
+			return "pipeline.transformations." + field
+		})...)
+	}
+	for i, step := range transformations.Steps {
+		if step.Type == "" {
+			errs = append(errs, c.Errorf(fmt.Sprintf("pipeline.transformations.%d", i), "transformation step is missing required field \"type\""))
+		} else if err := transform.ValidateStepType(step.Type); err != nil {
+			errs = append(errs, c.Errorf(fmt.Sprintf("pipeline.transformations.%d.type", i), "%v", err))
+		}
+		errs = append(errs, c.validateRules(step.Rules, stepPath(i, step))...)
+	}
+
+	if c.Pipeline.Parallelism < 0 {
+		errs = append(errs, c.Errorf("pipeline.parallelism", "parallelism must not be negative, got %d", c.Pipeline.Parallelism))
+	}
+	if c.Pipeline.BatchSize < 0 {
+		errs = append(errs, c.Errorf("pipeline.batch_size", "batch_size must not be negative, got %d", c.Pipeline.BatchSize))
+	}
+
+	return errs
+}
+
+// Helper function to check transformation rules, reporting problems at the
+// YAML path that at returns for each field of the rules
+func (c Config) validateRules(rules transform.TransformationRules, at func(field string) string) Errors {
+	var errs Errors
+	for i, filter := range rules.Filter {
+		path := at(fmt.Sprintf("filter.%d.condition", i))
+		if _, err := transform.CompileFilter(filter.Column, filter.Condition); err != nil {
+			errs = append(errs, c.exprError(path, "invalid filter condition", err))
+		}
+	}
+	if rules.Mapping.Case != "" {
+		if err := transform.ValidateCase(rules.Mapping.Case); err != nil {
+			errs = append(errs, c.Errorf(at("mapping.case"), "invalid mapping: %v", err))
+		}
+	}
+	for _, section := range []struct {
+		name     string
+		mappings []transform.FieldMapping
+	}{
+		{"custom_mapping", rules.Mapping.CustomMapping},
+		{"copy", rules.Mapping.Copy},
+	} {
+		for i, m := range section.mappings {
+			path := at(fmt.Sprintf("mapping.%s.%d", section.name, i))
+			if m.From == "" || m.To == "" {
+				errs = append(errs, c.Errorf(path, "%s entry requires both \"from\" and \"to\"", section.name))
+			}
+		}
+	}
+	for i, rule := range rules.Set {
+		errs = append(errs, c.validateSetRule(at(fmt.Sprintf("set.%d", i)), rule)...)
+	}
+	for i, aggregation := range rules.Aggregation {
+		path := at(fmt.Sprintf("aggregation.%d", i))
+		if aggregation.Operation == "percentile" && aggregation.Percentile == nil {
+			errs = append(errs, c.Errorf(path, "aggregation rule with operation \"percentile\" is missing required field \"percentile\""))
+		} else if err := transform.ValidateAggregation(aggregation); err != nil {
+			errs = append(errs, c.Errorf(path+".operation", "invalid aggregation: %v", err))
+		}
+		if aggregation.Column == "" {
+			errs = append(errs, c.Errorf(path, "aggregation rule is missing required field \"column\""))
+		}
+		if aggregation.As == "" {
+			errs = append(errs, c.Errorf(path, "aggregation rule is missing required field \"as\""))
+		}
+	}
+
+	if err := transform.ValidateAggregationMode(rules.AggregationMode); err != nil {
+		errs = append(errs, c.Errorf(at("aggregation_mode"), "%v", err))
+	}
+	for i, field := range rules.GroupBy {
+		if field == "" {
+			errs = append(errs, c.Errorf(at(fmt.Sprintf("group_by.%d", i)), "group_by field names must not be empty"))
+		}
+	}
+	if rules.Flatten.Enabled && rules.Unflatten.Enabled {
+		errs = append(errs, c.Errorf(at("unflatten"), "flatten and unflatten cannot both be enabled"))
+	}
+
+	errs = append(errs, c.validatePaths(rules, at)...)
+	return errs
+}
+
+// Helper function to return the YAML path of a field of the rules of step i.
+// Filter and set steps hold a single rule, and mapping, flatten and unflatten
+// steps the options of their section, directly in the step; other steps hold
+// rule sections such as aggregation and group_by.
+func stepPath(i int, step transform.Step) func(field string) string {
+	prefix := fmt.Sprintf("pipeline.transformations.%d", i)
+	return func(field string) string {
+		_, rest, _ := strings.Cut(field, ".")
+		switch step.Type {
+		case "filter", "set":
+			// Drop the index of the single rule, as in "filter.0.condition"
+			_, rest, _ = strings.Cut(rest, ".")
+		case "mapping", "flatten", "unflatten":
+		default:
+			return joinPath(prefix, field)
+		}
+		if rest == "" {
+			return prefix
+		}
+		return joinPath(prefix, rest)
+	}
+}
+
+// Helper function to check that a set rule names a field and has at most one
+// kind of value, and that its template, expression and condition parse
+func (c Config) validateSetRule(path string, rule transform.SetRule) Errors {
+	var errs Errors
+	if rule.Field == "" {
+		errs = append(errs, c.Errorf(path, "set rule is missing required field \"field\""))
+	}
+	kinds := 0
+	for _, set := range []bool{rule.Value != nil, rule.Template != "", rule.Expr != ""} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds > 1 {
+		errs = append(errs, c.Errorf(path, "set rule can have only one of \"value\", \"template\" and \"expr\""))
+	}
+	if rule.Template != "" {
+		if _, err := transform.CompileTemplate(rule.Template); err != nil {
+			errs = append(errs, c.Errorf(path+".template", "%v", err))
+		}
+	}
+	if rule.Expr != "" {
+		if _, err := expr.Compile(rule.Expr); err != nil {
+			errs = append(errs, c.exprError(path+".expr", "invalid expr", err))
+		}
+	}
+	if rule.When != "" {
+		if _, err := expr.Compile(rule.When); err != nil {
+			errs = append(errs, c.exprError(path+".when", "invalid when condition", err))
+		}
+	}
+	return errs
+}
+
+// Helper function to check the syntax of the field paths used by the transformation rules
+func (c Config) validatePaths(rules transform.TransformationRules, at func(field string) string) Errors {
+	paths := make(map[string]string)
+	for i, filter := range rules.Filter {
+		if filter.Column != "*" {
+			paths[at(fmt.Sprintf("filter.%d.column", i))] = filter.Column
+		}
+	}
+	for name, mappings := range map[string][]transform.FieldMapping{
+		"custom_mapping": rules.Mapping.CustomMapping,
+		"copy":           rules.Mapping.Copy,
+	} {
+		for i, m := range mappings {
+			paths[at(fmt.Sprintf("mapping.%s.%d.from", name, i))] = m.From
+			paths[at(fmt.Sprintf("mapping.%s.%d.to", name, i))] = m.To
+		}
+	}
+	for name, fields := range map[string][]string{"drop": rules.Mapping.Drop, "keep": rules.Mapping.Keep} {
+		for i, field := range fields {
+			paths[at(fmt.Sprintf("mapping.%s.%d", name, i))] = field
+		}
+	}
+	for i, aggregation := range rules.Aggregation {
+		if aggregation.Column != "*" {
+			paths[at(fmt.Sprintf("aggregation.%d.column", i))] = aggregation.Column
+		}
+		paths[at(fmt.Sprintf("aggregation.%d.as", i))] = aggregation.As
+	}
+	for i, field := range rules.GroupBy {
+		paths[at(fmt.Sprintf("group_by.%d", i))] = field
+	}
+	for i, rule := range rules.Set {
+		paths[at(fmt.Sprintf("set.%d.field", i))] = rule.Field
+	}
+
+	var errs Errors
+	for path, field := range paths {
+		// Missing fields are reported by the checks above.
+		if field == "" {
+			continue
+		}
+		if err := record.ValidatePath(field); err != nil {
+			errs = append(errs, c.Errorf(path, "%v", err))
+		}
+	}
+	return errs
+}
+
+// Helper function to report an expression error at its position inside the YAML value
+func (c Config) exprError(path, msg string, err error) *Error {
+	var exprErr *expr.Error
+	if !errors.As(err, &exprErr) {
+		return c.Errorf(path, "%s: %v", msg, err)
+	}
+	e := c.Errorf(path, "%s: %s", msg, exprErr.Msg)
+	node := c.lookupValue(path)
+	if node == nil || strings.Contains(node.Value, "\n") {
+		return e
+	}
+	// Point at the offending character when the value is on a single line.
+	e.Line, e.Column = node.Line, node.Column+exprErr.Pos-1
+	if node.Style&(yaml.SingleQuotedStyle|yaml.DoubleQuotedStyle) != 0 {
+		e.Column++
+	}
+	return e
+}
+
+// Helper function to check that an input or output has the settings its type needs
+func (c Config) validateEndpoint(path string, endpoint Endpoint) Errors {
+	if endpoint.Type == "" {
+		return Errors{c.Errorf(path, "missing required field \"type\"")}
+	}
+
+	var errs Errors
+	settings := map[string]string{
+		"collection": endpoint.Config.Collection,
+		"filePath":   endpoint.Config.FilePath,
+	}
+	for _, name := range requiredSettings[endpoint.Type] {
+		if settings[name] == "" {
+			errs = append(errs, c.Errorf(path+".config."+name, "%s type %q requires field \"config.%s\"", strings.TrimPrefix(path, "pipeline."), endpoint.Type, name))
+		}
+	}
+	errs = append(errs, c.validateCSVOptions(path, endpoint)...)
+	errs = append(errs, c.validateFirestoreOptions(path, endpoint)...)
+	return errs
+}
+
+// Helper function to check the query settings of a firebase input and the
+// write settings of a firebase output
+func (c Config) validateFirestoreOptions(path string, endpoint Endpoint) Errors {
+	opts := endpoint.Config.Firestore
+	if opts.IsZero() {
+		return nil
+	}
+	if endpoint.Type != "firebase" {
+		return Errors{c.Errorf(path+".config", "Firestore settings such as where and write_mode only apply to type \"firebase\"")}
+	}
+
+	input := path == "pipeline.input"
+	errs := c.checkDirection(path, "firebase", []endpointSetting{
+		{"collection_group", opts.CollectionGroup, true},
+		{"where", len(opts.Where) > 0, true},
+		{"order_by", len(opts.OrderBy) > 0, true},
+		{"limit", opts.Limit != 0, true},
+		{"select", len(opts.Select) > 0, true},
+		{"page_size", opts.PageSize != 0, true},
+		{"path_field", opts.PathField != "", true},
+		{"subcollections", len(opts.Subcollections) > 0, true},
+		{"subcollection_mode", opts.SubcollectionMode != "", true},
+		{"resolve_references", len(opts.ResolveReferences) > 0, true},
+		{"write_mode", opts.WriteMode != "", false},
+		{"id_template", opts.IDTemplate != "", false},
+		{"batch_size", opts.BatchSize != 0, false},
+		{"rate_limit", opts.RateLimit != 0, false},
+	})
+	if opts.IDField != "" {
+		if err := record.ValidatePath(opts.IDField); err != nil {
+			errs = append(errs, c.Errorf(path+".config.id_field", "%v", err))
+		}
+	}
+	if input {
+		return append(errs, c.validateFirestoreQuery(path, opts)...)
+	}
+
+	mode := opts.WriteMode
+	if mode == "" {
+		mode = output.WriteModeAdd
+	}
+	known := false
+	for _, m := range output.WriteModes {
+		known = known || m == mode
+	}
+	if !known {
+		errs = append(errs, c.Errorf(path+".config.write_mode", "unknown write_mode %q (supported: %s)", opts.WriteMode, strings.Join(output.WriteModes, ", ")))
+	}
+
+	hasID := opts.IDField != "" || opts.IDTemplate != ""
+	switch {
+	case opts.IDField != "" && opts.IDTemplate != "":
+		errs = append(errs, c.Errorf(path+".config.id_template", "id_field and id_template cannot both be set"))
+	case mode == output.WriteModeAdd && hasID:
+		errs = append(errs, c.Errorf(path+".config.write_mode", "write_mode %q generates document IDs; use %q to write documents with id_field or id_template", output.WriteModeAdd, output.WriteModeCreate))
+	case known && !hasID && mode != output.WriteModeAdd && mode != output.WriteModeCreate:
+		errs = append(errs, c.Errorf(path+".config.write_mode", "write_mode %q requires id_field or id_template", mode))
+	}
+	if opts.IDTemplate != "" {
+		if _, err := transform.CompileTemplate(opts.IDTemplate); err != nil {
+			errs = append(errs, c.Errorf(path+".config.id_template", "%v", err))
+		}
+	}
+	if opts.BatchSize < 0 || opts.BatchSize > output.MaxFirestoreBatchSize {
+		errs = append(errs, c.Errorf(path+".config.batch_size", "batch_size must be between 1 and %d, got %d", output.MaxFirestoreBatchSize, opts.BatchSize))
+	}
+	if opts.RateLimit < 0 {
+		errs = append(errs, c.Errorf(path+".config.rate_limit", "rate_limit must not be negative, got %v", opts.RateLimit))
+	}
+	return errs
+}
+
+// Helper function to check the query settings of a firebase input
+func (c Config) validateFirestoreQuery(path string, opts FirestoreOptions) Errors {
+	var errs Errors
+	for i, filter := range opts.Where {
+		filterPath := fmt.Sprintf("%s.config.where.%d", path, i)
+		if filter.Field == "" {
+			errs = append(errs, c.Errorf(filterPath, "where clause is missing required field \"field\""))
+		} else if err := record.ValidatePath(filter.Field); err != nil {
+			errs = append(errs, c.Errorf(filterPath+".field", "%v", err))
+		}
+		known := false
+		for _, op := range input.FirebaseOperators {
+			known = known || op == filter.Op
+		}
+		if !known {
+			errs = append(errs, c.Errorf(filterPath+".op", "unknown operator %q (supported: %s)", filter.Op, strings.Join(input.FirebaseOperators, ", ")))
+		}
+	}
+	for i, order := range opts.OrderBy {
+		if _, err := input.ParseFirebaseOrder(order); err != nil {
+			errs = append(errs, c.Errorf(fmt.Sprintf("%s.config.order_by.%d", path, i), "%v", err))
+		}
+	}
+	for i, field := range opts.Select {
+		if err := record.ValidatePath(field); err != nil {
+			errs = append(errs, c.Errorf(fmt.Sprintf("%s.config.select.%d", path, i), "%v", err))
+		}
+	}
+	if opts.Limit < 0 {
+		errs = append(errs, c.Errorf(path+".config.limit", "limit must not be negative, got %d", opts.Limit))
+	}
+	if opts.PageSize < 0 {
+		errs = append(errs, c.Errorf(path+".config.page_size", "page_size must not be negative, got %d", opts.PageSize))
+	}
+	if opts.IDField != "" && opts.IDField == opts.PathField {
+		errs = append(errs, c.Errorf(path+".config.path_field", "id_field and path_field must differ"))
+	}
+
+	listed := make(map[string]bool)
+	for _, name := range opts.Subcollections {
+		listed[name] = true
+	}
+	for i, name := range opts.Subcollections {
+		at := fmt.Sprintf("%s.config.subcollections.%d", path, i)
+		if name == "" || strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/") || strings.Contains(name, "//") {
+			errs = append(errs, c.Errorf(at, "invalid subcollection name %q", name))
+			continue
+		}
+		// A nested subcollection is only reached through its parent
+		if slash := strings.LastIndex(name, "/"); slash >= 0 && !listed[name[:slash]] {
+			errs = append(errs, c.Errorf(at, "subcollection %q is listed without its parent %q", name, name[:slash]))
+		}
+	}
+	switch opts.SubcollectionModeOrDefault() {
+	case SubcollectionsNest, SubcollectionsFlatten:
+		if opts.SubcollectionMode != "" && len(opts.Subcollections) == 0 {
+			errs = append(errs, c.Errorf(path+".config.subcollection_mode", "subcollection_mode requires subcollections"))
+		}
+	default:
+		errs = append(errs, c.Errorf(path+".config.subcollection_mode", "unknown subcollection_mode %q (supported: %s, %s)", opts.SubcollectionMode, SubcollectionsNest, SubcollectionsFlatten))
+	}
+	for i, field := range opts.ResolveReferences {
+		if err := record.ValidatePath(field); err != nil {
+			errs = append(errs, c.Errorf(fmt.Sprintf("%s.config.resolve_references.%d", path, i), "%v", err))
+		}
+	}
+	return errs
+}
+
+// endpointSetting is an input or output setting that only applies to one of the two.
+type endpointSetting struct {
+	name      string
+	set       bool
+	inputOnly bool
+}
+
+// Helper function to report the settings used on the wrong side of a pipeline
+func (c Config) checkDirection(path, endpointType string, settings []endpointSetting) Errors {
+	var errs Errors
+	input := path == "pipeline.input"
+	for _, setting := range settings {
+		if setting.set && setting.inputOnly != input {
+			direction := "outputs"
+			if setting.inputOnly {
+				direction = "inputs"
+			}
+			errs = append(errs, c.Errorf(path+".config."+setting.name, "%s only applies to %s %s", setting.name, endpointType, direction))
+		}
+	}
+	return errs
+}
+
+// Helper function to check the CSV dialect settings of an input or output
+func (c Config) validateCSVOptions(path string, endpoint Endpoint) Errors {
+	opts := endpoint.Config.CSV
+	if opts.IsZero() {
+		return nil
+	}
+	if endpoint.Type != "csv" {
+		return Errors{c.Errorf(path+".config", "CSV settings such as delimiter and header only apply to type \"csv\"")}
+	}
+
+	var errs Errors
+	delimiter, err := opts.DelimiterRune()
+	if err != nil {
+		errs = append(errs, c.Errorf(path+".config.delimiter", "%v", err))
+	}
+	comment, err := opts.CommentRune()
+	if err != nil {
+		errs = append(errs, c.Errorf(path+".config.comment", "%v", err))
+	}
+	if comment != 0 && (comment == delimiter || delimiter == 0 && comment == ',') {
+		errs = append(errs, c.Errorf(path+".config.comment", "comment character must differ from the delimiter"))
+	}
+	if _, err := charset.Lookup(opts.Encoding); err != nil {
+		errs = append(errs, c.Errorf(path+".config.encoding", "%v", err))
+	}
+	for i, column := range opts.Columns {
+		columnPath := fmt.Sprintf("%s.config.columns.%d", path, i)
+		if column == "" {
+			errs = append(errs, c.Errorf(columnPath, "column names must not be empty"))
+		} else if path != "pipeline.input" {
+			// Output columns name the fields to write, which may be nested
+			if err := record.ValidatePath(column); err != nil {
+				errs = append(errs, c.Errorf(columnPath, "%v", err))
+			}
+		}
+	}
+
+	input := path == "pipeline.input"
+	errs = append(errs, c.checkDirection(path, "csv", []endpointSetting{
+		{"comment", opts.Comment != "", true},
+		{"lazy_quotes", opts.LazyQuotes, true},
+		{"trim_space", opts.TrimSpace, true},
+		{"on_error", opts.OnError != "", true},
+		{"rejects_file", opts.RejectsFile != "", true},
+		{"bom", opts.BOM, false},
+		{"missing_value", opts.MissingValue != "", false},
+	})...)
+	if input {
+		switch opts.OnErrorOrDefault() {
+		case OnErrorFail, OnErrorSkip:
+			if opts.RejectsFile != "" {
+				errs = append(errs, c.Errorf(path+".config.rejects_file", "rejects_file requires on_error: %s", OnErrorReject))
+			}
+		case OnErrorReject:
+			if opts.RejectsFile == "" {
+				errs = append(errs, c.Errorf(path+".config.on_error", "on_error: %s requires field \"config.rejects_file\"", OnErrorReject))
+			}
+		default:
+			errs = append(errs, c.Errorf(path+".config.on_error", "unknown on_error policy %q (supported: %s, %s, %s)", opts.OnError, OnErrorFail, OnErrorSkip, OnErrorReject))
+		}
+	}
+	return errs
+}
+
+var unmarshalerType = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+
+var (
+	transformationsType = reflect.TypeOf(transform.Transformations{})
+	stepType            = reflect.TypeOf(transform.Step{})
+)
+
+// checkFields reports every mapping key in the document that does not match a
+// field of the value it is decoded into.
+func checkFields(file string, root *yaml.Node, v interface{}) Errors {
+	node := root
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	var errs Errors
+	walkFields(file, node, reflect.TypeOf(v), "", &errs)
+	return errs
+}
+
+// Helper function to walk a YAML node alongside the Go type it is decoded into
+func walkFields(file string, node *yaml.Node, t reflect.Type, path string, errs *Errors) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	// A list of transformation steps is checked step by step, and each step
+	// accepts the options of its type.
+	switch {
+	case t == transformationsType && node.Kind == yaml.SequenceNode:
+		walkFields(file, node, reflect.TypeOf([]transform.Step{}), path, errs)
+		return
+	case t == stepType && node.Kind == yaml.MappingNode:
+		walkStep(file, node, path, errs)
+		return
+	}
+	// Types with their own decoding decide which keys they accept, except
+	// for structs written as a mapping of their fields.
+	if reflect.PointerTo(t).Implements(unmarshalerType) && !(t.Kind() == reflect.Struct && node.Kind == yaml.MappingNode) {
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			field, ok := fields[key.Value]
+			if !ok {
+				*errs = append(*errs, &Error{
+					File:   file,
+					Line:   key.Line,
+					Column: key.Column,
+					Msg:    fmt.Sprintf("unknown field %q in %s (allowed: %s)", key.Value, describePath(path), strings.Join(sortedNames(fields), ", ")),
+				})
+				continue
+			}
+			walkFields(file, value, field, joinPath(path, key.Value), errs)
+		}
+	case reflect.Slice, reflect.Array:
+		if node.Kind != yaml.SequenceNode {
+			return
+		}
+		for i, item := range node.Content {
+			walkFields(file, item, t.Elem(), joinPath(path, strconv.Itoa(i)), errs)
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			walkFields(file, node.Content[i+1], t.Elem(), joinPath(path, node.Content[i].Value), errs)
+		}
+	}
+}
+
+// Helper function to walk a transformation step, whose fields other than
+// "type" are the options of its type
+func walkStep(file string, node *yaml.Node, path string, errs *Errors) {
+	options := &yaml.Node{Kind: yaml.MappingNode, Line: node.Line, Column: node.Column}
+	var step transform.Step
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == "type" {
+			step.Type = node.Content[i+1].Value
+			continue
+		}
+		options.Content = append(options.Content, node.Content[i], node.Content[i+1])
+	}
+	// Without a supported type there are no options to check; validate
+	// reports the step
+	stepOptions := step.Options()
+	if stepOptions == nil {
+		return
+	}
+	walkFields(file, options, reflect.TypeOf(stepOptions), path, errs)
+}
+
+// Helper function to map the YAML names of a struct's fields to their types
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag := field.Tag.Get("yaml")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if strings.Contains(opts, "inline") {
+			for inlineName, inlineType := range yamlFields(field.Type) {
+				fields[inlineName] = inlineType
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+// Helper function to return the sorted field names of a struct
+func sortedNames(fields map[string]reflect.Type) []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Helper function to join a dotted path
+func joinPath(path, part string) string {
+	if path == "" {
+		return part
+	}
+	return path + "." + part
+}
+
+// Helper function to name a path in error messages
+func describePath(path string) string {
+	if path == "" {
+		return "the top level"
+	}
+	return path
+}
@@ -0,0 +1,546 @@
+// Package expr implements the expression language used by filter conditions.
+//
+// An expression compares record fields against literals or other fields:
+//
+//	age >= 25 and status == 'active'
+//	country in ['IN', 'US'] or not (email matches '@example\.com$')
+//	name contains 'Smith' and manager is not null
+//
+// Field names are bare identifiers, or quoted with backticks when they contain
+// spaces or other characters (`First Name`). Identifiers may include dots and
+// array indexes, as in address.city or items[0].sku, which rows can resolve
+// as paths into nested values. Strings use single or double
+// quotes. Numbers compare numerically with numeric strings, so CSV values
+// such as "30" compare equal to 30. Timestamps compare with each other and
+// with date strings such as '2024-01-31' or '2024-01-31T12:00:00Z'.
+//
+// A condition attached to a single column may leave out the left operand of
+// its comparisons, which then compare that column: "> 10", ">= 18 and < 65",
+// "in ['a', 'b']" or "is not null".
+package expr
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Error is a syntax error at a 1-based character position in the expression.
+type Error struct {
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("at position %d: %s", e.Pos, e.Msg)
+}
+
+// Row gives an expression access to the fields of a record.
+type Row interface {
+	Get(name string) (interface{}, bool)
+}
+
+// Map adapts a plain map to Row.
+type Map map[string]interface{}
+
+// Get returns the value of a field and whether it exists.
+func (m Map) Get(name string) (interface{}, bool) {
+	value, ok := m[name]
+	return value, ok
+}
+
+// Keys returns the field names in unspecified order.
+func (m Map) Keys() []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Expr is a compiled expression that can be evaluated against records.
+type Expr struct {
+	src  string
+	root node
+}
+
+// Compile parses a full expression in which every comparison names its operands.
+func Compile(src string) (*Expr, error) {
+	return compile(src, false)
+}
+
+// CompileCondition parses a condition whose comparisons may leave out their
+// left operand, which is then the subject passed to EvalSubject.
+func CompileCondition(src string) (*Expr, error) {
+	return compile(src, true)
+}
+
+// MustCompile is like Compile but panics if the expression cannot be parsed.
+func MustCompile(src string) *Expr {
+	e, err := Compile(src)
+	if err != nil {
+		panic(fmt.Sprintf("expr: Compile(%q): %v", src, err))
+	}
+	return e
+}
+
+func compile(src string, allowSubject bool) (*Expr, error) {
+	if strings.TrimSpace(src) == "" {
+		return nil, &Error{Pos: 1, Msg: "expression is empty"}
+	}
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, allowSubject: allowSubject}
+	root, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+	return &Expr{src: src, root: root}, nil
+}
+
+// String returns the source of the expression.
+func (e *Expr) String() string {
+	return strings.TrimSpace(e.src)
+}
+
+// Eval evaluates the expression against a record.
+func (e *Expr) Eval(row Row) interface{} {
+	return e.root.eval(env{row: row})
+}
+
+// EvalSubject evaluates a condition against a record, using subject as the
+// left operand of comparisons that leave it out.
+func (e *Expr) EvalSubject(row Row, subject interface{}) interface{} {
+	return e.root.eval(env{row: row, subject: subject})
+}
+
+// Match evaluates the expression against a record and reports whether the result is true.
+func (e *Expr) Match(row Row) bool {
+	return Truthy(e.Eval(row))
+}
+
+// Truthy reports whether a value counts as true: false, nil, zero and the
+// empty string are false; everything else is true.
+func Truthy(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case string:
+		return v != ""
+	}
+	if f, ok := toFloat(v); ok {
+		return f != 0
+	}
+	return true
+}
+
+// env holds the values an expression is evaluated against.
+type env struct {
+	row     Row
+	subject interface{}
+}
+
+type node interface {
+	eval(env env) interface{}
+}
+
+type literalNode struct {
+	value interface{}
+}
+
+func (n *literalNode) eval(env) interface{} {
+	return n.value
+}
+
+type fieldNode struct {
+	name string
+}
+
+func (n *fieldNode) eval(env env) interface{} {
+	value, _ := env.row.Get(n.name)
+	return value
+}
+
+type subjectNode struct{}
+
+func (subjectNode) eval(env env) interface{} {
+	return env.subject
+}
+
+type listNode struct {
+	items []node
+}
+
+func (n *listNode) eval(env env) interface{} {
+	values := make([]interface{}, len(n.items))
+	for i, item := range n.items {
+		values[i] = item.eval(env)
+	}
+	return values
+}
+
+type notNode struct {
+	x node
+}
+
+func (n *notNode) eval(env env) interface{} {
+	return !Truthy(n.x.eval(env))
+}
+
+type logicalNode struct {
+	op          string
+	left, right node
+}
+
+func (n *logicalNode) eval(env env) interface{} {
+	left := Truthy(n.left.eval(env))
+	if n.op == "and" {
+		return left && Truthy(n.right.eval(env))
+	}
+	return left || Truthy(n.right.eval(env))
+}
+
+type isNullNode struct {
+	x      node
+	negate bool
+}
+
+func (n *isNullNode) eval(env env) interface{} {
+	return (n.x.eval(env) == nil) != n.negate
+}
+
+type compareNode struct {
+	op          string
+	left, right node
+}
+
+func (n *compareNode) eval(env env) interface{} {
+	left, right := n.left.eval(env), n.right.eval(env)
+	switch n.op {
+	case "==":
+		return equal(left, right)
+	case "!=":
+		return !equal(left, right)
+	case "in":
+		items, _ := right.([]interface{})
+		for _, item := range items {
+			if equal(left, item) {
+				return true
+			}
+		}
+		return false
+	case "contains":
+		return contains(left, right)
+	}
+
+	cmp, ok := compare(left, right)
+	if !ok {
+		return false
+	}
+	switch n.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return false
+}
+
+type matchNode struct {
+	left, right node
+	re          *regexp.Regexp // set when the pattern is a literal
+}
+
+func (n *matchNode) eval(env env) interface{} {
+	left := n.left.eval(env)
+	if left == nil {
+		return false
+	}
+	re := n.re
+	if re == nil {
+		pattern, ok := n.right.eval(env).(string)
+		if !ok {
+			return false
+		}
+		var err error
+		if re, err = regexp.Compile(pattern); err != nil {
+			return false
+		}
+	}
+	return re.MatchString(toString(left))
+}
+
+type arithNode struct {
+	op          string
+	left, right node
+}
+
+// eval returns nil when an operand is not a number, except that + joins
+// strings. Integers give an integer result unless it overflows, or a division
+// leaves a remainder.
+func (n *arithNode) eval(env env) interface{} {
+	left, right := n.left.eval(env), n.right.eval(env)
+	if a, ok := toInt(left); ok {
+		if b, ok := toInt(right); ok {
+			if result, ok := intArith(n.op, a, b); ok {
+				return result
+			}
+		}
+	}
+	a, aok := toFloat(left)
+	b, bok := toFloat(right)
+	if !aok || !bok {
+		if n.op == "+" && left != nil && right != nil {
+			return toString(left) + toString(right)
+		}
+		return nil
+	}
+	switch n.op {
+	case "+":
+		return a + b
+	case "-":
+		return a - b
+	case "*":
+		return a * b
+	case "/":
+		if b == 0 {
+			return nil
+		}
+		return a / b
+	case "%":
+		if b == 0 {
+			return nil
+		}
+		return math.Mod(a, b)
+	}
+	return nil
+}
+
+// Helper function to apply an arithmetic operator to integers. ok is false
+// when the result is not an integer, so that it is computed as a float.
+func intArith(op string, a, b int64) (interface{}, bool) {
+	switch op {
+	case "+":
+		sum := a + b
+		return sum, (sum > a) == (b > 0)
+	case "-":
+		diff := a - b
+		return diff, (diff < a) == (b > 0)
+	case "*":
+		if a == 0 || b == 0 {
+			return int64(0), true
+		}
+		product := a * b
+		return product, product/b == a && !(a == -1 && b == math.MinInt64) && !(b == -1 && a == math.MinInt64)
+	case "/":
+		if b == 0 {
+			return nil, true
+		}
+		if a%b != 0 || (a == math.MinInt64 && b == -1) {
+			return nil, false
+		}
+		return a / b, true
+	case "%":
+		if b == 0 {
+			return nil, true
+		}
+		return a % b, true
+	}
+	return nil, false
+}
+
+// Helper function to check two values for equality, comparing numerically when both are numbers
+func equal(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if cmp, ok := compare(a, b); ok {
+		return cmp == 0
+	}
+	return toString(a) == toString(b)
+}
+
+// Helper function to order two values. Numbers and numeric strings compare
+// numerically, other strings lexically; ok is false when they cannot be ordered.
+func compare(a, b interface{}) (int, bool) {
+	if a == nil || b == nil {
+		return 0, false
+	}
+	if x, ok := toTime(a, b); ok {
+		if y, ok := toTime(b, a); ok {
+			return x.Compare(y), true
+		}
+	}
+	if x, ok := toFloat(a); ok {
+		if y, ok := toFloat(b); ok {
+			switch {
+			case x < y:
+				return -1, true
+			case x > y:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	if x, ok := a.(bool); ok {
+		if y, ok := b.(bool); ok && x == y {
+			return 0, true
+		}
+		return 0, false
+	}
+	as, aok := a.(string)
+	bs, bok := b.(string)
+	if aok && bok {
+		return strings.Compare(as, bs), true
+	}
+	return 0, false
+}
+
+// Helper function to check if a string contains a substring or a list contains an element
+func contains(container, item interface{}) bool {
+	switch c := container.(type) {
+	case nil:
+		return false
+	case []interface{}:
+		for _, element := range c {
+			if equal(element, item) {
+				return true
+			}
+		}
+		return false
+	}
+	if item == nil {
+		return false
+	}
+	return strings.Contains(toString(container), toString(item))
+}
+
+// Helper function to convert an integer to int64
+func toInt(v interface{}) (int64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	}
+	return 0, false
+}
+
+// Helper function to convert a number or numeric string to float64
+func toFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case string:
+		return ParseNumber(strings.TrimSpace(v))
+	}
+	return 0, false
+}
+
+// ParseNumber parses a finite decimal number such as "42", "-3.5", ".5" or
+// "1e6". Other forms that strconv.ParseFloat accepts, such as "NaN", "Inf",
+// hexadecimal floats and underscores, are not numbers, so CSV text like "Nan"
+// or "Infinity" stays a string.
+func ParseNumber(s string) (float64, bool) {
+	if !isDecimal(s) {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+		return 0, false
+	}
+	return f, true
+}
+
+// Helper function to check if a string has the form of a decimal number: an
+// optional sign, digits with an optional decimal point, and an optional exponent
+func isDecimal(s string) bool {
+	i := 0
+	if i < len(s) && (s[i] == '+' || s[i] == '-') {
+		i++
+	}
+	digits := 0
+	for ; i < len(s) && s[i] >= '0' && s[i] <= '9'; i++ {
+		digits++
+	}
+	if i < len(s) && s[i] == '.' {
+		for i++; i < len(s) && s[i] >= '0' && s[i] <= '9'; i++ {
+			digits++
+		}
+	}
+	if digits == 0 {
+		return false
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			i++
+		}
+		start := i
+		for ; i < len(s) && s[i] >= '0' && s[i] <= '9'; i++ {
+		}
+		if i == start {
+			return false
+		}
+	}
+	return i == len(s)
+}
+
+// Helper function to convert a value to a timestamp when it is compared with
+// one: timestamps compare with other timestamps and with RFC 3339 dates
+func toTime(v, other interface{}) (time.Time, bool) {
+	if t, ok := v.(time.Time); ok {
+		return t, true
+	}
+	s, ok := v.(string)
+	if !ok {
+		return time.Time{}, false
+	}
+	if _, ok := other.(time.Time); !ok {
+		return time.Time{}, false
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, strings.TrimSpace(s)); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Helper function to format a value as a string
+func toString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,236 @@
+package expr
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLex(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"age >= 25", `ident:age op:>= number:25`},
+		{"a.b[0].c == 'x y'", `ident:a.b[0].c op:== string:x y`},
+		{"`First Name` != \"it's\"", `ident:First Name op:!= string:it's`},
+		{`name == 'a\'b\n'`, "ident:name op:== string:a'b\n"},
+		{"x IN [1, 2.5e3, .5]", `ident:x keyword:in op:[ number:1 op:, number:2.5e3 op:, number:.5 op:]`},
+		{"not(a&&b)||c", `keyword:not op:( ident:a op:&& ident:b op:) op:|| ident:c`},
+		{"a <> b and c =~ 'x'", `ident:a op:<> ident:b keyword:and ident:c op:=~ string:x`},
+		{"Is NOT Null", `keyword:is keyword:not keyword:null`},
+	}
+	kinds := map[tokenKind]string{tokIdent: "ident", tokString: "string", tokNumber: "number", tokOp: "op", tokKeyword: "keyword"}
+	for _, test := range tests {
+		tokens, err := lex(test.src)
+		if err != nil {
+			t.Errorf("lex(%q): %v", test.src, err)
+			continue
+		}
+		var got []string
+		for _, tok := range tokens {
+			if tok.kind != tokEOF {
+				got = append(got, kinds[tok.kind]+":"+tok.value)
+			}
+		}
+		if strings.Join(got, " ") != test.want {
+			t.Errorf("lex(%q):\ngot  %s\nwant %s", test.src, strings.Join(got, " "), test.want)
+		}
+	}
+}
+
+func TestEval(t *testing.T) {
+	row := Map{
+		"age":     int64(30),
+		"price":   int64(7),
+		"qty":     int64(3),
+		"rate":    1.5,
+		"zero":    int64(0),
+		"name":    "Ann Smith",
+		"text":    "30",
+		"word":    "Nan",
+		"inf":     "Infinity",
+		"hex":     "0x10",
+		"tags":    []interface{}{"red", "big"},
+		"empty":   "",
+		"null":    nil,
+		"created": time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC),
+	}
+	tests := []struct {
+		src  string
+		want interface{}
+	}{
+		// Precedence
+		{"1 + 2 * 3", int64(7)},
+		{"(1 + 2) * 3", int64(9)},
+		{"10 - 4 - 3", int64(3)},
+		{"-price + 10", int64(3)},
+		{"2 * -3", int64(-6)},
+		{"age > 20 and age < 25 or name contains 'Ann'", true},
+		{"age > 20 and (age < 25 or name contains 'Bob')", false},
+		{"not age > 40 and price == 7", true},
+		{"! (age == 30) || qty == 3", true},
+		{"price * qty > 20", true},
+
+		// Arithmetic keeps integers integral
+		{"price * qty", int64(21)},
+		{"price + qty", int64(10)},
+		{"price - qty", int64(4)},
+		{"price % qty", int64(1)},
+		{"21 / qty", int64(7)},
+		{"price / 2", 3.5},
+		{"price * rate", 10.5},
+		{"text * 2", 60.0},
+		{"-price", int64(-7)},
+		{"2.0 * 3", 6.0},
+		{"9223372036854775807 + 1", 9223372036854775808.0},
+		{"-9223372036854775807 - 2", -9223372036854775809.0},
+		{"3 * 4000000000000000000", 12000000000000000000.0},
+
+		// Division by zero
+		{"price / zero", nil},
+		{"price % zero", nil},
+		{"rate / 0", nil},
+
+		// String +
+		{"name + '!'", "Ann Smith!"},
+		{"'n=' + price", "n=7"},
+		{"name + null", nil},
+		{"name - 1", nil},
+
+		// Null and missing fields
+		{"missing", nil},
+		{"missing is null", true},
+		{"null is null", true},
+		{"name is not null", true},
+		{"missing == null", true},
+		{"missing != 1", true},
+		{"missing > 1", false},
+		{"missing < 1", false},
+		{"missing + 1", nil},
+		{"missing in [1, null]", true},
+		{"missing contains 'a'", false},
+		{"missing matches 'a'", false},
+
+		// Comparisons
+		{"text == 30", true},
+		{"text > 4", true},
+		{"'abc' < 'abd'", true},
+		{"age in [10, 30]", true},
+		{"age not in [10, 30]", false},
+		{"tags contains 'red'", true},
+		{"name matches '^Ann'", true},
+		{"name =~ 'smith$'", false},
+		{"created > '2024-01-01'", true},
+		{"created == '2024-01-31T12:00:00Z'", true},
+		{"empty == ''", true},
+
+		// Only finite decimal strings are numbers
+		{"word > 10", false},
+		{"word == 'Nan'", true},
+		{"inf > 1", false},
+		{"inf == 'infinity'", false},
+		{"hex == 16", false},
+		{"word * 1", nil},
+	}
+	for _, test := range tests {
+		e, err := Compile(test.src)
+		if err != nil {
+			t.Errorf("Compile(%q): %v", test.src, err)
+			continue
+		}
+		if got := e.Eval(row); fmt.Sprintf("%T %v", got, got) != fmt.Sprintf("%T %v", test.want, test.want) {
+			t.Errorf("%s: got %T %v, want %T %v", test.src, got, got, test.want, test.want)
+		}
+	}
+}
+
+func TestEvalSubject(t *testing.T) {
+	tests := []struct {
+		src     string
+		subject interface{}
+		want    bool
+	}{
+		{"> 10", int64(12), true},
+		{"> 10", "9", false},
+		{">= 18 and < 65", 30.0, true},
+		{"in ['a', 'b']", "b", true},
+		{"not in ['a', 'b']", "b", false},
+		{"is not null", nil, false},
+		{"not contains 'x'", "abc", true},
+		{"> 10", "NaN", false},
+	}
+	for _, test := range tests {
+		e, err := CompileCondition(test.src)
+		if err != nil {
+			t.Errorf("CompileCondition(%q): %v", test.src, err)
+			continue
+		}
+		if got := Truthy(e.EvalSubject(Map{}, test.subject)); got != test.want {
+			t.Errorf("%s with %v: got %v, want %v", test.src, test.subject, got, test.want)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"", "at position 1: expression is empty"},
+		{"age >", "at position 6: expected a value, found end of expression"},
+		{"age > 'x", "at position 7: unterminated string"},
+		{"`age > 1", "at position 1: unterminated quoted field name"},
+		{"age # 1", `at position 5: unexpected character '#'`},
+		{"(age > 1", `at position 9: expected ")", found end of expression`},
+		{"age > 1 2", `at position 9: unexpected "2"`},
+		{"age in 'x'", "at position 8: expected a list such as ['a', 'b'] after in"},
+		{"name matches '('", "at position 14: invalid regular expression: error parsing regexp: missing closing ): `(`"},
+		{"> 10", `at position 1: missing value before ">"`},
+		{"age is 1", `at position 8: expected "null", found "1"`},
+	}
+	for _, test := range tests {
+		_, err := Compile(test.src)
+		if err == nil {
+			t.Errorf("Compile(%q): expected an error", test.src)
+			continue
+		}
+		if err.Error() != test.want {
+			t.Errorf("Compile(%q):\ngot  %v\nwant %s", test.src, err, test.want)
+		}
+	}
+}
+
+func TestParseNumber(t *testing.T) {
+	tests := []struct {
+		s    string
+		want float64
+		ok   bool
+	}{
+		{"42", 42, true},
+		{"-3.5", -3.5, true},
+		{"+.5", 0.5, true},
+		{"5.", 5, true},
+		{"1e3", 1000, true},
+		{"2.5E-1", 0.25, true},
+		{"", 0, false},
+		{".", 0, false},
+		{"-", 0, false},
+		{"1e", 0, false},
+		{"1e+", 0, false},
+		{"NaN", 0, false},
+		{"Inf", 0, false},
+		{"-Infinity", 0, false},
+		{"0x1p-2", 0, false},
+		{"1_000", 0, false},
+		{"1e999", 0, false},
+		{" 1", 0, false},
+	}
+	for _, test := range tests {
+		got, ok := ParseNumber(test.s)
+		if ok != test.ok || got != test.want {
+			t.Errorf("ParseNumber(%q) = %v, %v; want %v, %v", test.s, got, ok, test.want, test.ok)
+		}
+	}
+}
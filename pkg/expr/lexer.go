@@ -0,0 +1,180 @@
+package expr
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp
+	tokKeyword
+)
+
+// token is a lexical element of an expression. pos is the 1-based character
+// offset in the source, used in error messages.
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of expression"
+	case tokString:
+		return fmt.Sprintf("string %q", t.value)
+	default:
+		return fmt.Sprintf("%q", t.value)
+	}
+}
+
+// keywords are matched case-insensitively and stored in lower case.
+var keywords = map[string]bool{
+	"and": true, "or": true, "not": true,
+	"in": true, "contains": true, "matches": true,
+	"is": true, "null": true, "true": true, "false": true,
+}
+
+// twoCharOps are checked before single-character operators.
+var twoCharOps = []string{"==", "!=", "<>", "<=", ">=", "=~", "&&", "||"}
+
+const singleCharOps = "=<>!+-*/%()[],"
+
+// Helper function to split an expression into tokens
+func lex(src string) ([]token, error) {
+	var tokens []token
+	runes := []rune(src)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		pos := i + 1
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '\'' || r == '"':
+			value, n, err := lexString(runes[i:])
+			if err != nil {
+				return nil, &Error{Pos: pos, Msg: err.Error()}
+			}
+			tokens = append(tokens, token{kind: tokString, value: value, pos: pos})
+			i += n
+		case r == '`':
+			end := strings.IndexRune(string(runes[i+1:]), '`')
+			if end < 0 {
+				return nil, &Error{Pos: pos, Msg: "unterminated quoted field name"}
+			}
+			name := []rune(string(runes[i+1:])[:end])
+			tokens = append(tokens, token{kind: tokIdent, value: string(name), pos: pos})
+			i += len(name) + 2
+		case unicode.IsDigit(r) || (r == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			if i < len(runes) && (runes[i] == 'e' || runes[i] == 'E') {
+				i++
+				if i < len(runes) && (runes[i] == '+' || runes[i] == '-') {
+					i++
+				}
+				for i < len(runes) && unicode.IsDigit(runes[i]) {
+					i++
+				}
+			}
+			tokens = append(tokens, token{kind: tokNumber, value: string(runes[start:i]), pos: pos})
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) {
+				if isIdentRune(runes[i]) {
+					i++
+				} else if n := indexLength(runes[i:]); n > 0 && !keywords[strings.ToLower(string(runes[start:i]))] {
+					// An array index directly after a name, as in items[0].sku
+					i += n
+				} else {
+					break
+				}
+			}
+			word := string(runes[start:i])
+			if lower := strings.ToLower(word); keywords[lower] {
+				tokens = append(tokens, token{kind: tokKeyword, value: lower, pos: pos})
+			} else {
+				tokens = append(tokens, token{kind: tokIdent, value: word, pos: pos})
+			}
+		default:
+			op := ""
+			if i+1 < len(runes) {
+				for _, candidate := range twoCharOps {
+					if string(runes[i:i+2]) == candidate {
+						op = candidate
+						break
+					}
+				}
+			}
+			if op == "" && strings.ContainsRune(singleCharOps, r) {
+				op = string(r)
+			}
+			if op == "" {
+				return nil, &Error{Pos: pos, Msg: fmt.Sprintf("unexpected character %q", r)}
+			}
+			tokens = append(tokens, token{kind: tokOp, value: op, pos: pos})
+			i += len([]rune(op))
+		}
+	}
+	tokens = append(tokens, token{kind: tokEOF, pos: len(runes) + 1})
+	return tokens, nil
+}
+
+// Helper function to check if a rune can continue a field name
+func isIdentRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.'
+}
+
+// Helper function to return the length of an array index such as "[0]" at
+// the start of runes, or 0 if there is none
+func indexLength(runes []rune) int {
+	if len(runes) < 3 || runes[0] != '[' {
+		return 0
+	}
+	i := 1
+	for i < len(runes) && unicode.IsDigit(runes[i]) {
+		i++
+	}
+	if i == 1 || i >= len(runes) || runes[i] != ']' {
+		return 0
+	}
+	return i + 1
+}
+
+// Helper function to read a quoted string literal, returning its value and length in runes
+func lexString(runes []rune) (string, int, error) {
+	quote := runes[0]
+	var b strings.Builder
+	for i := 1; i < len(runes); i++ {
+		switch runes[i] {
+		case quote:
+			return b.String(), i + 1, nil
+		case '\\':
+			i++
+			if i >= len(runes) {
+				return "", 0, fmt.Errorf("unterminated string")
+			}
+			switch runes[i] {
+			case 'n':
+				b.WriteRune('\n')
+			case 't':
+				b.WriteRune('\t')
+			default:
+				b.WriteRune(runes[i])
+			}
+		default:
+			b.WriteRune(runes[i])
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
@@ -0,0 +1,391 @@
+package expr
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// parser is a recursive descent parser over the token list. Operator
+// precedence, from lowest to highest:
+//
+//	or, ||
+//	and, &&
+//	not, !
+//	==, =, !=, <>, <, <=, >, >=, in, not in, contains, matches, =~, is [not] null
+//	+, -
+//	*, /, %
+//	unary -
+type parser struct {
+	tokens []token
+	pos    int
+
+	// allowSubject permits comparisons with no left operand, such as "> 10",
+	// which then compare the subject value of the condition.
+	allowSubject bool
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) peekAt(offset int) token {
+	if p.pos+offset >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1]
+	}
+	return p.tokens[p.pos+offset]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) is(kind tokenKind, values ...string) bool {
+	t := p.peek()
+	if t.kind != kind {
+		return false
+	}
+	for _, value := range values {
+		if t.value == value {
+			return true
+		}
+	}
+	return len(values) == 0
+}
+
+func (p *parser) expect(kind tokenKind, value string) error {
+	if !p.is(kind, value) {
+		return p.errorf("expected %q, found %s", value, p.peek())
+	}
+	p.next()
+	return nil
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return &Error{Pos: p.peek().pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) parse() (node, error) {
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.is(tokEOF) {
+		return nil, p.errorf("unexpected %s", p.peek())
+	}
+	return n, nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.is(tokKeyword, "or") || p.is(tokOp, "||") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{op: "or", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.is(tokKeyword, "and") || p.is(tokOp, "&&") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{op: "and", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	// "not in", "not contains" and "not matches" with an implicit subject
+	// are negated comparisons, not a unary not.
+	if p.is(tokKeyword, "not") && !(p.allowSubject && isNegatableOp(p.peekAt(1))) || p.is(tokOp, "!") {
+		p.next()
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{x: x}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	var left node
+	if p.allowSubject && p.startsComparison() {
+		left = subjectNode{}
+	} else {
+		var err error
+		left, err = p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch t := p.peek(); {
+	case t.kind == tokOp && isComparisonOp(t.value):
+		p.next()
+		if t.value == "=~" {
+			return p.parseRegex(left)
+		}
+		right, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		return &compareNode{op: normalizeOp(t.value), left: left, right: right}, nil
+	case t.kind == tokKeyword && t.value == "is":
+		p.next()
+		negate := false
+		if p.is(tokKeyword, "not") {
+			p.next()
+			negate = true
+		}
+		if err := p.expect(tokKeyword, "null"); err != nil {
+			return nil, err
+		}
+		return &isNullNode{x: left, negate: negate}, nil
+	case t.kind == tokKeyword && (t.value == "in" || t.value == "contains" || t.value == "matches"),
+		t.kind == tokKeyword && t.value == "not" && isNegatableOp(p.peekAt(1)):
+		negate := false
+		if t.value == "not" {
+			p.next()
+			negate = true
+		}
+		op := p.next().value
+		return p.parseMembership(op, left, negate)
+	}
+	return left, nil
+}
+
+// Helper function to parse the right-hand side of in, contains and matches
+func (p *parser) parseMembership(op string, left node, negate bool) (node, error) {
+	var n node
+	switch op {
+	case "in":
+		start := p.peek()
+		right, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := right.(*listNode); !ok {
+			return nil, &Error{Pos: start.pos, Msg: "expected a list such as ['a', 'b'] after in"}
+		}
+		n = &compareNode{op: "in", left: left, right: right}
+	case "contains":
+		right, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		n = &compareNode{op: "contains", left: left, right: right}
+	case "matches":
+		match, err := p.parseRegex(left)
+		if err != nil {
+			return nil, err
+		}
+		n = match
+	}
+	if negate {
+		return &notNode{x: n}, nil
+	}
+	return n, nil
+}
+
+// Helper function to parse a regular expression operand, compiling literals up front
+func (p *parser) parseRegex(left node) (node, error) {
+	start := p.peek()
+	right, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	n := &matchNode{left: left, right: right}
+	if lit, ok := right.(*literalNode); ok {
+		pattern, ok := lit.value.(string)
+		if !ok {
+			return nil, &Error{Pos: start.pos, Msg: "expected a string pattern after matches"}
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, &Error{Pos: start.pos, Msg: fmt.Sprintf("invalid regular expression: %v", err)}
+		}
+		n.re = re
+	}
+	return n, nil
+}
+
+func (p *parser) parseAdditive() (node, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for p.is(tokOp, "+", "-") {
+		op := p.next().value
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = &arithNode{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseMultiplicative() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.is(tokOp, "*", "/", "%") {
+		op := p.next().value
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &arithNode{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.is(tokOp, "-") {
+		p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if lit, ok := x.(*literalNode); ok {
+			switch v := lit.value.(type) {
+			case int64:
+				return &literalNode{value: -v}, nil
+			case float64:
+				return &literalNode{value: -v}, nil
+			}
+		}
+		return &arithNode{op: "-", left: &literalNode{value: int64(0)}, right: x}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.peek()
+	switch {
+	case t.kind == tokNumber:
+		p.next()
+		// Integer literals are int64, so integer arithmetic stays integral
+		if !strings.ContainsAny(t.value, ".eE") {
+			if n, err := strconv.ParseInt(t.value, 10, 64); err == nil {
+				return &literalNode{value: n}, nil
+			}
+		}
+		f, err := strconv.ParseFloat(t.value, 64)
+		if err != nil {
+			return nil, &Error{Pos: t.pos, Msg: fmt.Sprintf("invalid number %q", t.value)}
+		}
+		return &literalNode{value: f}, nil
+	case t.kind == tokString:
+		p.next()
+		return &literalNode{value: t.value}, nil
+	case t.kind == tokKeyword && (t.value == "true" || t.value == "false"):
+		p.next()
+		return &literalNode{value: t.value == "true"}, nil
+	case t.kind == tokKeyword && t.value == "null":
+		p.next()
+		return &literalNode{value: nil}, nil
+	case t.kind == tokIdent:
+		p.next()
+		return &fieldNode{name: t.value}, nil
+	case t.kind == tokOp && t.value == "(":
+		p.next()
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokOp, ")"); err != nil {
+			return nil, err
+		}
+		return n, nil
+	case t.kind == tokOp && t.value == "[":
+		return p.parseList()
+	case p.startsComparison():
+		return nil, p.errorf("missing value before %s", t)
+	}
+	return nil, p.errorf("expected a value, found %s", t)
+}
+
+func (p *parser) parseList() (node, error) {
+	p.next()
+	list := &listNode{}
+	for !p.is(tokOp, "]") {
+		item, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		list.items = append(list.items, item)
+		if !p.is(tokOp, ",") {
+			break
+		}
+		p.next()
+	}
+	if err := p.expect(tokOp, "]"); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// startsComparison reports whether the next token is a comparison operator,
+// meaning the left operand has been left out.
+func (p *parser) startsComparison() bool {
+	t := p.peek()
+	switch t.kind {
+	case tokOp:
+		return isComparisonOp(t.value)
+	case tokKeyword:
+		switch t.value {
+		case "is", "in", "contains", "matches":
+			return true
+		case "not":
+			return isNegatableOp(p.peekAt(1))
+		}
+	}
+	return false
+}
+
+// Helper function to check if an operator token compares two values
+func isComparisonOp(op string) bool {
+	switch op {
+	case "==", "=", "!=", "<>", "<", "<=", ">", ">=", "=~":
+		return true
+	}
+	return false
+}
+
+// Helper function to check if a token can follow "not" as a negated comparison
+func isNegatableOp(t token) bool {
+	return t.kind == tokKeyword && (t.value == "in" || t.value == "contains" || t.value == "matches")
+}
+
+// Helper function to map operator aliases to a single spelling
+func normalizeOp(op string) string {
+	switch op {
+	case "=":
+		return "=="
+	case "<>":
+		return "!="
+	}
+	return op
+}
@@ -1,37 +1,201 @@
 package input
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/transform"
 )
 
-// ReadCSV reads the data from a CSV file.
-func ReadCSV(filePath string) ([]map[string]string, error) {
+// CSVOptions describes the dialect of a CSV file. The zero value reads
+// comma-separated UTF-8 with a header row.
+type CSVOptions struct {
+	Delimiter  rune     // field delimiter, ',' when zero
+	Comment    rune     // lines starting with this character are skipped
+	NoHeader   bool     // the file has no header row
+	Columns    []string // column names, used instead of the header row
+	LazyQuotes bool     // allow quotes inside unquoted fields and stray quotes in quoted ones
+	TrimSpace  bool     // trim leading and trailing white space from every field
+	Encoding   encoding.Encoding
+}
+
+// utf8BOM is the byte order mark that some tools write at the start of UTF-8 files.
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// CSVReader reads the rows of a CSV file one at a time, so that large files
+// are never held in memory at once.
+type CSVReader struct {
+	file      *os.File
+	reader    *csv.Reader
+	headers   []string
+	trimSpace bool
+}
+
+// OpenCSV opens a comma-separated UTF-8 file and reads its header row.
+func OpenCSV(filePath string) (*CSVReader, error) {
+	return OpenCSVWithOptions(filePath, CSVOptions{})
+}
+
+// OpenCSVWithOptions opens a CSV file written in the given dialect. Unless the
+// file has no header row, the header row is read; columns without a name in
+// opts.Columns keep their header name, and columns without either are named
+// column1, column2 and so on. A byte order mark at the start is skipped.
+func OpenCSVWithOptions(filePath string, opts CSVOptions) (*CSVReader, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
 
-	reader := csv.NewReader(file)
-	headers, err := reader.Read() // Read the header row
+	var src io.Reader = file
+	if opts.Encoding != nil {
+		src = transform.NewReader(file, opts.Encoding.NewDecoder())
+	}
+	buffered := bufio.NewReader(src)
+	if prefix, err := buffered.Peek(len(utf8BOM)); err == nil && bytes.Equal(prefix, utf8BOM) {
+		buffered.Discard(len(utf8BOM))
+	}
+
+	reader := csv.NewReader(buffered)
+	if opts.Delimiter != 0 {
+		reader.Comma = opts.Delimiter
+	}
+	reader.Comment = opts.Comment
+	reader.LazyQuotes = opts.LazyQuotes
+	reader.TrimLeadingSpace = opts.TrimSpace
+	r := &CSVReader{file: file, reader: reader, trimSpace: opts.TrimSpace}
+
+	if !opts.NoHeader {
+		headers, err := r.Read() // Read the header row
+		if err != nil {
+			file.Close()
+			if err == io.EOF {
+				return nil, fmt.Errorf("missing header row")
+			}
+			return nil, err
+		}
+		r.headers = append([]string(nil), headers...)
+	}
+	if len(opts.Columns) > 0 {
+		for i, name := range opts.Columns {
+			if i < len(r.headers) {
+				r.headers[i] = name
+			} else {
+				r.headers = append(r.headers, name)
+			}
+		}
+		// Rows may be shorter or longer than the named columns.
+		reader.FieldsPerRecord = -1
+	}
+	return r, nil
+}
+
+// Headers returns the column names, in order.
+func (r *CSVReader) Headers() []string {
+	return r.headers
+}
+
+// Column returns the name of the column at index i, naming columns beyond the
+// known ones column1, column2 and so on.
+func (r *CSVReader) Column(i int) string {
+	if i < len(r.headers) {
+		return r.headers[i]
+	}
+	return fmt.Sprintf("column%d", i+1)
+}
+
+// RowError reports a row that could not be parsed, such as one with a stray
+// quote or the wrong number of fields. Reading can go on with the next row.
+type RowError struct {
+	Line   int      // line the row starts on
+	Column int      // column of a quoting error, or 0
+	Fields []string // the fields that could be read, if any
+	Err    error
+}
+
+func (e *RowError) Error() string {
+	if e.Column > 0 {
+		return fmt.Sprintf("line %d, column %d: %v", e.Line, e.Column, e.Err)
+	}
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// Read returns the next row, or io.EOF after the last one. A row that cannot
+// be parsed gives a *RowError, after which the next call reads the next row.
+func (r *CSVReader) Read() ([]string, error) {
+	row, err := r.reader.Read()
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		rowErr := &RowError{Line: parseErr.StartLine, Fields: row, Err: parseErr.Err}
+		switch {
+		case parseErr.Err == csv.ErrFieldCount:
+			rowErr.Err = fmt.Errorf("expected %d fields, got %d", r.reader.FieldsPerRecord, len(row))
+		case parseErr.Line != parseErr.StartLine:
+			// A quoted field ran on past its line; the column is on another line
+			rowErr.Err = fmt.Errorf("%v at line %d, column %d", parseErr.Err, parseErr.Line, parseErr.Column)
+		default:
+			rowErr.Column = parseErr.Column
+		}
+		return nil, rowErr
+	}
 	if err != nil {
 		return nil, err
 	}
+	if r.trimSpace {
+		for i := range row {
+			row[i] = strings.TrimSpace(row[i])
+		}
+	}
+	return row, nil
+}
 
+// Close closes the file.
+func (r *CSVReader) Close() error {
+	return r.file.Close()
+}
+
+// ReadCSV reads the data from a CSV file.
+func ReadCSV(filePath string) ([]map[string]string, error) {
+	_, rows, err := ReadCSVWithHeader(filePath)
+	return rows, err
+}
+
+// ReadCSVWithHeader reads the data from a CSV file and also returns the
+// header row, which gives the column order.
+func ReadCSVWithHeader(filePath string) ([]string, []map[string]string, error) {
+	reader, err := OpenCSV(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer reader.Close()
+
+	headers := reader.Headers()
 	var rows []map[string]string
 	for {
 		record, err := reader.Read()
-		if err != nil {
+		if err == io.EOF {
 			break
 		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("error reading %s: %w", filePath, err)
+		}
 
 		row := make(map[string]string)
-		for i, header := range headers {
-			row[header] = record[i]
+		for i, value := range record {
+			row[reader.Column(i)] = value
 		}
 		rows = append(rows, row)
 	}
 
-	return rows, nil
+	return headers, rows, nil
 }
@@ -0,0 +1,151 @@
+package input
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// Helper function to read a CSV file as "header | row | row" text, with the
+// fields of each row joined by commas
+func readCSVText(t *testing.T, content []byte, opts CSVOptions) string {
+	t.Helper()
+	reader, err := OpenCSVWithOptions(writeTestFile(t, "in.csv", content), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	rows := []string{strings.Join(reader.Headers(), ",")}
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return strings.Join(rows, " | ")
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Name the fields beyond the headers, as the pipeline does
+		fields := make([]string, len(row))
+		for i, value := range row {
+			fields[i] = value
+			if i >= len(reader.Headers()) {
+				fields[i] = reader.Column(i) + "=" + value
+			}
+		}
+		rows = append(rows, strings.Join(fields, ","))
+	}
+}
+
+// Helper function to encode text for a test file
+func encode(t *testing.T, enc encoding.Encoding, s string) []byte {
+	t.Helper()
+	b, err := enc.NewEncoder().Bytes([]byte(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestCSVDialect(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		opts    CSVOptions
+		want    string
+	}{
+		{"defaults", "id,name\n1,ann\n2,\"bo, jr\"\n", CSVOptions{}, "id,name | 1,ann | 2,bo, jr"},
+		{"tab delimiter", "id\tname\n1\tann\n", CSVOptions{Delimiter: '\t'}, "id,name | 1,ann"},
+		{"semicolon delimiter", "id;amount\n1;3,50\n", CSVOptions{Delimiter: ';'}, "id,amount | 1,3,50"},
+		{"pipe delimiter", "id|name\n1|ann\n", CSVOptions{Delimiter: '|'}, "id,name | 1,ann"},
+		{"comments", "# export\nid,name\n# note\n1,ann\n", CSVOptions{Comment: '#'}, "id,name | 1,ann"},
+		{"no header", "1,ann\n2,bo\n", CSVOptions{NoHeader: true, Columns: []string{"id", "name"}}, "id,name | 1,ann | 2,bo"},
+		{"no header or columns", "1,ann\n", CSVOptions{NoHeader: true}, " | column1=1,column2=ann"},
+		{"columns rename the header", "a,b,c\n1,2,3\n", CSVOptions{Columns: []string{"x", "y"}}, "x,y,c | 1,2,3"},
+		{"rows longer than the named columns", "1,ann,extra\n2\n", CSVOptions{NoHeader: true, Columns: []string{"id", "name"}}, "id,name | 1,ann,column3=extra | 2"},
+		{"lazy quotes", "id,note\n1,say \"hi\"\n", CSVOptions{LazyQuotes: true}, "id,note | 1,say \"hi\""},
+		{"trim space", "id , name\n 1 ,  ann \n", CSVOptions{TrimSpace: true}, "id,name | 1,ann"},
+		{"CRLF line endings", "id,name\r\n1,ann\r\n", CSVOptions{}, "id,name | 1,ann"},
+		{"UTF-8 byte order mark", "\uFEFFid,name\n1,ann\n", CSVOptions{}, "id,name | 1,ann"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := readCSVText(t, []byte(test.content), test.opts); got != test.want {
+				t.Errorf("got  %q\nwant %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCSVEncodings(t *testing.T) {
+	text := "id,city\n1,Zürich\n2,Besançon\n"
+	want := "id,city | 1,Zürich | 2,Besançon"
+	utf16 := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM)
+	tests := []struct {
+		name    string
+		content []byte
+		enc     encoding.Encoding
+	}{
+		{"latin1", encode(t, charmap.ISO8859_1, text), charmap.ISO8859_1},
+		{"windows-1252", encode(t, charmap.Windows1252, text), charmap.Windows1252},
+		{"utf-16 with a byte order mark", encode(t, utf16, text), utf16},
+		{"big endian utf-16 detected from its byte order mark", encode(t, unicode.UTF16(unicode.BigEndian, unicode.UseBOM), text), utf16},
+		{"utf-16le with a byte order mark", encode(t, utf16, text), unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)},
+		{"utf-16be without a byte order mark", encode(t, unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM), text), unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := readCSVText(t, test.content, CSVOptions{Encoding: test.enc}); got != want {
+				t.Errorf("got  %q\nwant %q", got, want)
+			}
+		})
+	}
+}
+
+func TestCSVMissingHeader(t *testing.T) {
+	_, err := OpenCSVWithOptions(writeTestFile(t, "in.csv", nil), CSVOptions{})
+	if err == nil || err.Error() != "missing header row" {
+		t.Errorf("got error %v, want missing header row", err)
+	}
+}
+
+func TestCSVRowErrors(t *testing.T) {
+	content := "id,name\n1,ann\n2,bo,extra\n3,a\"b\n4,\"cy\n5,\"d\"x\n6,ed\n"
+	reader, err := OpenCSVWithOptions(writeTestFile(t, "in.csv", []byte(content)), CSVOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	// Each bad row is reported with its line, and reading goes on after it
+	var got []string
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			rowErr, ok := err.(*RowError)
+			if !ok {
+				t.Fatalf("got %T error %v, want a *RowError", err, err)
+			}
+			got = append(got, "error: "+rowErr.Error())
+			continue
+		}
+		got = append(got, strings.Join(row, ","))
+	}
+	want := []string{
+		"1,ann",
+		"error: line 3: expected 2 fields, got 3",
+		`error: line 4, column 4: bare " in non-quoted-field`,
+		`error: line 5: extraneous or missing " in quoted-field at line 6, column 3`,
+		"6,ed",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
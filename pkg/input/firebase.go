@@ -2,19 +2,88 @@ package input
 
 import (
 	"context"
+	"fmt"
+	"io"
 
-	"cloud.google.com/go/firestore"
+	"github.com/avii09/hookit/pkg/store"
 )
 
-func ReadFirebase(client *firestore.Client, collection string) ([]map[string]interface{}, error) {
+// FirebaseOperators lists the comparison operators of Firestore where clauses.
+var FirebaseOperators = store.Operators
+
+// FirebaseQuery narrows down and orders the documents a FirebaseReader reads.
+// The zero value reads every document of the collection.
+type FirebaseQuery = store.Query
+
+// FirebaseFilter is a where clause, such as status == "shipped".
+type FirebaseFilter = store.Filter
+
+// FirebaseOrder orders the documents by a field.
+type FirebaseOrder = store.Order
+
+// ParseFirebaseOrder parses an order such as "created_at" or "created_at desc".
+func ParseFirebaseOrder(s string) (FirebaseOrder, error) {
+	return store.ParseOrder(s)
+}
+
+// FirebaseReader reads the documents of a Firestore query one at a time.
+type FirebaseReader struct {
+	iter store.DocumentIterator
+}
+
+// OpenFirebase starts reading the documents of a Firestore collection, or of
+// a subcollection given by its path, such as "orders/o1/items".
+func OpenFirebase(ctx context.Context, s store.DocumentStore, collection string) *FirebaseReader {
+	return &FirebaseReader{iter: s.List(ctx, collection)}
+}
+
+// OpenFirebaseQuery starts reading the documents of a Firestore collection,
+// or collection group, that match the query.
+func OpenFirebaseQuery(ctx context.Context, s store.DocumentStore, collection string, q FirebaseQuery) *FirebaseReader {
+	return &FirebaseReader{iter: s.Query(ctx, collection, q)}
+}
+
+// ReadDocument returns the next document, or io.EOF after the last one.
+func (r *FirebaseReader) ReadDocument() (*store.Document, error) {
+	doc, err := r.iter.Next()
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading Firestore documents: %w", err)
+	}
+	return doc, nil
+}
+
+// Read returns the data of the next document, or io.EOF after the last one.
+func (r *FirebaseReader) Read() (map[string]interface{}, error) {
+	doc, err := r.ReadDocument()
+	if err != nil {
+		return nil, err
+	}
+	return doc.Data, nil
+}
+
+// Close stops the query.
+func (r *FirebaseReader) Close() error {
+	r.iter.Stop()
+	return nil
+}
+
+// ReadFirebase reads every document of a Firestore collection.
+func ReadFirebase(s store.DocumentStore, collection string) ([]map[string]interface{}, error) {
+	reader := OpenFirebase(context.Background(), s, collection)
+	defer reader.Close()
+
 	var data []map[string]interface{}
-	iter := client.Collection(collection).Documents(context.Background())
 	for {
-		doc, err := iter.Next()
+		doc, err := reader.Read()
+		if err == io.EOF {
+			return data, nil
+		}
 		if err != nil {
-			break
+			return nil, err
 		}
-		data = append(data, doc.Data())
+		data = append(data, doc)
 	}
-	return data, nil
 }
@@ -1,12 +1,14 @@
 package input
 
 import (
+	"bufio"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"strconv"
 
+	"github.com/avii09/hookit/pkg/record"
 	"github.com/avii09/hookit/pkg/transform"
 )
 
@@ -19,19 +21,65 @@ func ReadJSON(filePath string) ([]map[string]interface{}, error) {
 	}
 	defer file.Close()
 
-	// Read the file content.
-	dataBytes, err := io.ReadAll(file)
+	// Parse the JSON data straight from the file.
+	var data []map[string]interface{}
+	if err := json.NewDecoder(bufio.NewReader(file)).Decode(&data); err != nil {
+		return nil, fmt.Errorf("error parsing JSON: %v", err)
+	}
+
+	return data, nil
+}
+
+// JSONReader reads the records of a JSON file one at a time, keeping field
+// order and the types of their values: integers stay integers and tagged
+// timestamps, bytes, geopoints and references are restored.
+type JSONReader struct {
+	file    *os.File
+	decoder *record.Decoder
+}
+
+// OpenJSON opens a JSON file holding an array of objects or a stream of objects.
+func OpenJSON(filePath string) (*JSONReader, error) {
+	file, err := os.Open(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("error reading JSON file: %v", err)
+		return nil, fmt.Errorf("error opening JSON file: %v", err)
 	}
+	return &JSONReader{file: file, decoder: record.NewDecoder(bufio.NewReader(file))}, nil
+}
 
-	// Parse the JSON data.
-	var data []map[string]interface{}
-	if err := json.Unmarshal(dataBytes, &data); err != nil {
+// Read returns the next record, or io.EOF after the last one.
+func (r *JSONReader) Read() (*record.Record, error) {
+	rec, err := r.decoder.Next()
+	if err != nil && err != io.EOF {
 		return nil, fmt.Errorf("error parsing JSON: %v", err)
 	}
+	return rec, err
+}
 
-	return data, nil
+// Close closes the file.
+func (r *JSONReader) Close() error {
+	return r.file.Close()
+}
+
+// ReadJSONRecords reads every record of the input JSON file.
+func ReadJSONRecords(filePath string) ([]*record.Record, error) {
+	reader, err := OpenJSON(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	var records []*record.Record
+	for {
+		rec, err := reader.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
 }
 
 // ConvertMapToStringMap converts a slice of maps with interface{} values to a slice of maps with string values.
@@ -58,8 +106,8 @@ func ConvertMapToStringMap(data []map[string]interface{}) ([]map[string]string,
                 // Handle nil values explicitly as an empty string.
                 stringRow[key] = ""
             default:
-                // Return an error for unsupported types.
-                return nil, fmt.Errorf("unsupported value type for key '%s': %T", key, v)
+                // Format timestamps, nested objects and arrays as text.
+                stringRow[key] = record.Format(v)
             }
         }
         stringData = append(stringData, stringRow)
@@ -82,7 +130,5 @@ func ProcessJSONInput(filePath string, rules transform.TransformationRules) ([]m
 	}
 
 	// Apply transformations to the data.
-	transformedData := transform.ApplyTransformations(stringData, rules)
-
-	return transformedData, nil
+	return transform.ApplyTransformations(stringData, rules)
 }
@@ -0,0 +1,58 @@
+package input
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/avii09/hookit/pkg/record"
+)
+
+// JSONLReader reads a JSON Lines file, one object per line, one record at a
+// time. Blank lines are skipped.
+type JSONLReader struct {
+	file   *os.File
+	reader *bufio.Reader
+	line   int
+}
+
+// OpenJSONL opens a JSON Lines file.
+func OpenJSONL(filePath string) (*JSONLReader, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening JSON Lines file: %v", err)
+	}
+	return &JSONLReader{file: file, reader: bufio.NewReader(file)}, nil
+}
+
+// Read returns the record on the next non-blank line, or io.EOF after the
+// last one. Parse errors give the line number.
+func (r *JSONLReader) Read() (*record.Record, error) {
+	for {
+		line, err := r.reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("error reading JSON Lines file: %v", err)
+		}
+		if len(line) == 0 && err == io.EOF {
+			return nil, io.EOF
+		}
+		r.line++
+
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		rec := record.New()
+		if err := rec.UnmarshalJSON(line); err != nil {
+			return nil, fmt.Errorf("error parsing JSON Lines: line %d: %v", r.line, err)
+		}
+		return rec, nil
+	}
+}
+
+// Close closes the file.
+func (r *JSONLReader) Close() error {
+	return r.file.Close()
+}
@@ -0,0 +1,116 @@
+package input
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Helper function to write a file in a test directory and return its path
+func writeTestFile(t *testing.T, name string, content []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// Helper function to read every record of a JSON Lines file as JSON lines,
+// along with the error that ended the reading
+func readJSONL(t *testing.T, content string) (string, error) {
+	t.Helper()
+	reader, err := OpenJSONL(writeTestFile(t, "in.jsonl", []byte(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	var lines []string
+	for {
+		r, err := reader.Read()
+		if err == io.EOF {
+			return strings.Join(lines, "\n"), nil
+		}
+		if err != nil {
+			return strings.Join(lines, "\n"), err
+		}
+		b, err := r.MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, string(b))
+	}
+}
+
+func TestJSONLReader(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		err     string
+	}{
+		{
+			"one object per line",
+			"{\"id\":1,\"name\":\"ann\"}\n{\"id\":2,\"tags\":[\"a\"],\"at\":null}\n",
+			`{"id":1,"name":"ann"}` + "\n" + `{"id":2,"tags":["a"],"at":null}`,
+			"",
+		},
+		{
+			"blank lines, CRLF and no final newline",
+			"\n{\"id\":1}\r\n   \r\n\t{\"id\":2}",
+			`{"id":1}` + "\n" + `{"id":2}`,
+			"",
+		},
+		{
+			"empty file",
+			"",
+			"",
+			"",
+		},
+		{
+			"parse error gives the line number, counting blank lines",
+			"{\"id\":1}\n\n{\"id\":2,}\n{\"id\":3}\n",
+			`{"id":1}`,
+			"error parsing JSON Lines: line 3: ",
+		},
+		{
+			"a line that is not an object",
+			"{\"id\":1}\n[1,2]\n",
+			`{"id":1}`,
+			"error parsing JSON Lines: line 2: ",
+		},
+		{
+			"an object split across lines",
+			"{\"id\":\n1}\n",
+			``,
+			"error parsing JSON Lines: line 1: ",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := readJSONL(t, test.content)
+			if test.err == "" && err != nil {
+				t.Fatal(err)
+			}
+			if test.err != "" && (err == nil || !strings.HasPrefix(err.Error(), test.err)) {
+				t.Errorf("got error %v, want %s...", err, test.err)
+			}
+			if got != test.want {
+				t.Errorf("got\n%s\nwant\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestJSONLReaderKeepsTypes(t *testing.T) {
+	got, err := readJSONL(t, `{"n":9007199254740993,"f":1.0,"b":true,"o":{"x":[1,"2"]}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"n":9007199254740993,"f":1.0,"b":true,"o":{"x":[1,"2"]}}`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
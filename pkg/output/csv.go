@@ -2,11 +2,280 @@ package output
 
 import (
 	"encoding/csv"
+	"io"
 	"os"
+	"path/filepath"
+	"sort"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/transform"
 )
 
-// WriteCSV writes the data to a CSV file.
+// CSVOptions describes the dialect of a CSV file to write. The zero value
+// writes comma-separated UTF-8 with a header row.
+type CSVOptions struct {
+	Delimiter rune // field delimiter, ',' when zero
+	NoHeader  bool // leave out the header row
+	Encoding  encoding.Encoding
+	BOM       bool // start the file with a byte order mark
+}
+
+// CSVWriter writes the rows of a CSV file one at a time.
+type CSVWriter struct {
+	file     *os.File
+	encoder  io.WriteCloser // encodes the text when an encoding is set
+	writer   *csv.Writer
+	noHeader bool
+	target   string // the path the file is moved to on Close, if it is temporary
+}
+
+// CreateCSV creates a comma-separated UTF-8 file, truncating it if it exists.
+func CreateCSV(filePath string) (*CSVWriter, error) {
+	return CreateCSVWithOptions(filePath, CSVOptions{})
+}
+
+// CreateCSVWithOptions creates a CSV file written in the given dialect,
+// truncating it if it exists.
+func CreateCSVWithOptions(filePath string, opts CSVOptions) (*CSVWriter, error) {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return newCSVWriter(file, opts)
+}
+
+// CreateCSVReplacing starts a CSV file written in the given dialect. The rows
+// go to a temporary file next to filePath, which Close moves to filePath and
+// Discard removes, so an existing file at filePath is only replaced once the
+// new one is complete.
+func CreateCSVReplacing(filePath string, opts CSVOptions) (*CSVWriter, error) {
+	file, err := os.CreateTemp(filepath.Dir(filePath), "."+filepath.Base(filePath)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	// Keep the mode of the file being replaced
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(filePath); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := file.Chmod(mode); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	w, err := newCSVWriter(file, opts)
+	if err != nil {
+		os.Remove(file.Name())
+		return nil, err
+	}
+	w.target = filePath
+	return w, nil
+}
+
+// Helper function to start writing CSV in the given dialect to a file
+func newCSVWriter(file *os.File, opts CSVOptions) (*CSVWriter, error) {
+	w := &CSVWriter{file: file, noHeader: opts.NoHeader}
+	var dst io.Writer = file
+	if opts.Encoding != nil {
+		w.encoder = transform.NewWriter(file, opts.Encoding.NewEncoder())
+		dst = w.encoder
+	}
+	if opts.BOM {
+		if _, err := io.WriteString(dst, "\uFEFF"); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	w.writer = csv.NewWriter(dst)
+	if opts.Delimiter != 0 {
+		w.writer.Comma = opts.Delimiter
+	}
+	return w, nil
+}
+
+// WriteHeader writes the header row, unless the dialect has none.
+func (w *CSVWriter) WriteHeader(headers []string) error {
+	if w.noHeader {
+		return nil
+	}
+	return w.writer.Write(headers)
+}
+
+// Write writes one row.
+func (w *CSVWriter) Write(row []string) error {
+	return w.writer.Write(row)
+}
+
+// Close flushes the buffered rows and closes the file. A temporary file is
+// then moved into place, or removed if writing it failed.
+func (w *CSVWriter) Close() error {
+	w.writer.Flush()
+	err := w.writer.Error()
+	if w.encoder != nil {
+		if closeErr := w.encoder.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	if w.target == "" {
+		return err
+	}
+	if err == nil {
+		err = os.Rename(w.file.Name(), w.target)
+	}
+	if err != nil {
+		os.Remove(w.file.Name())
+	}
+	return err
+}
+
+// Discard closes the file without writing the buffered rows. A temporary file
+// is removed, leaving any existing file at the target path as it was.
+func (w *CSVWriter) Discard() error {
+	err := w.file.Close()
+	if w.target != "" {
+		if removeErr := os.Remove(w.file.Name()); err == nil {
+			err = removeErr
+		}
+	}
+	return err
+}
+
+// CSVTable writes a CSV file whose columns are only known once every row has
+// been seen: the union of the columns of all rows, in the order they first
+// appear. Rows are spooled to a temporary file next to the output, so memory
+// use does not grow with the number of rows, and Close writes the output with
+// the final header, filling in the columns a row does not have. The output
+// replaces an existing file only once it is complete.
+type CSVTable struct {
+	filePath string
+	opts     CSVOptions
+	missing  string
+
+	spool   *os.File
+	writer  *csv.Writer
+	columns []string
+	index   map[string]int
+}
+
+// CreateCSVTable starts a CSV file written in the given dialect, with missing
+// as the value of the columns a row does not have.
+func CreateCSVTable(filePath string, opts CSVOptions, missing string) (*CSVTable, error) {
+	spool, err := os.CreateTemp(filepath.Dir(filePath), "."+filepath.Base(filePath)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	return &CSVTable{
+		filePath: filePath,
+		opts:     opts,
+		missing:  missing,
+		spool:    spool,
+		writer:   csv.NewWriter(spool),
+		index:    make(map[string]int),
+	}, nil
+}
+
+// Write adds a row given as column names and their values, in order. Columns
+// not seen before are added after the known ones.
+func (t *CSVTable) Write(columns, values []string) error {
+	for _, column := range columns {
+		if _, ok := t.index[column]; !ok {
+			t.index[column] = len(t.columns)
+			t.columns = append(t.columns, column)
+		}
+	}
+	row := make([]string, len(t.columns))
+	for i := range row {
+		row[i] = t.missing
+	}
+	for i, column := range columns {
+		row[t.index[column]] = values[i]
+	}
+	return t.writer.Write(row)
+}
+
+// Close writes the output file from the spooled rows and removes the spool.
+func (t *CSVTable) Close() (err error) {
+	defer os.Remove(t.spool.Name())
+	defer t.spool.Close()
+
+	t.writer.Flush()
+	if err := t.writer.Error(); err != nil {
+		return err
+	}
+	if _, err := t.spool.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	out, err := CreateCSVReplacing(t.filePath, t.opts)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			out.Discard()
+		} else {
+			err = out.Close()
+		}
+	}()
+	if len(t.columns) == 0 {
+		return nil
+	}
+	if err := out.WriteHeader(t.columns); err != nil {
+		return err
+	}
+
+	reader := csv.NewReader(t.spool)
+	reader.FieldsPerRecord = -1 // rows written before a column appeared are shorter
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for len(row) < len(t.columns) {
+			row = append(row, t.missing)
+		}
+		if err := out.Write(row); err != nil {
+			return err
+		}
+	}
+}
+
+// Discard removes the spooled rows without writing the output, leaving any
+// existing file at the output path as it was.
+func (t *CSVTable) Discard() error {
+	err := t.spool.Close()
+	if removeErr := os.Remove(t.spool.Name()); err == nil {
+		err = removeErr
+	}
+	return err
+}
+
+// WriteCSV writes the data to a CSV file. The columns are those of every row,
+// sorted by name.
 func WriteCSV(filePath string, data []map[string]string) error {
+    seen := make(map[string]bool)
+    var headers []string
+    for _, row := range data {
+        for key := range row {
+            if !seen[key] {
+                seen[key] = true
+                headers = append(headers, key)
+            }
+        }
+    }
+    sort.Strings(headers)
+    return WriteCSVWithHeader(filePath, headers, data)
+}
+
+// WriteCSVWithHeader writes the data to a CSV file with the given columns, in order.
+func WriteCSVWithHeader(filePath string, headers []string, data []map[string]string) error {
     file, err := os.Create(filePath)
     if err != nil {
         return err
@@ -18,10 +287,6 @@ func WriteCSV(filePath string, data []map[string]string) error {
 
     // Write header row
     if len(data) > 0 {
-        var headers []string
-        for key := range data[0] {
-            headers = append(headers, key)
-        }
         if err := writer.Write(headers); err != nil {
             return err
         }
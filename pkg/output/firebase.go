@@ -3,14 +3,101 @@ package output
 import (
 	"context"
 
-	"cloud.google.com/go/firestore"
+	"github.com/avii09/hookit/pkg/store"
+	"golang.org/x/time/rate"
 )
 
+// Write modes of a FirestoreWriter.
+const (
+	WriteModeAdd    = store.WriteAdd    // add a document with a generated ID
+	WriteModeCreate = store.WriteCreate // create a document, failing if it exists
+	WriteModeSet    = store.WriteSet    // create or replace a document
+	WriteModeMerge  = store.WriteMerge  // create a document or merge the fields into it
+	WriteModeUpdate = store.WriteUpdate // update the fields of a document, failing if it does not exist
+)
+
+// WriteModes lists the write modes in the order they are documented.
+var WriteModes = store.WriteModes
+
+// MaxFirestoreBatchSize is the largest number of writes Firestore commits atomically.
+const MaxFirestoreBatchSize = store.MaxBatchSize
+
+// FirestoreDocument is a document to write. An empty ID gives the document a
+// generated one.
+type FirestoreDocument struct {
+	ID   string
+	Data map[string]interface{}
+}
+
+// FirestoreWriteOptions controls how a FirestoreWriter writes documents.
+type FirestoreWriteOptions struct {
+	Mode      string  // one of the write modes; add when empty
+	Atomic    bool    // commit each call to WriteAll in one transaction instead of with a BulkWriter
+	RateLimit float64 // the most documents written per second, or 0 for no limit
+}
+
+// FirestoreWriter writes documents to a collection of a document store,
+// either with a BulkWriter, which sends the writes in parallel and retries
+// them one by one, or in atomic batches.
+type FirestoreWriter struct {
+	store      store.DocumentStore
+	collection string
+	opts       FirestoreWriteOptions
+	limiter    *rate.Limiter
+}
+
+// NewFirestoreWriter returns a writer for the collection.
+func NewFirestoreWriter(s store.DocumentStore, collection string, opts FirestoreWriteOptions) *FirestoreWriter {
+	w := &FirestoreWriter{store: s, collection: collection, opts: opts}
+	if opts.Mode == "" {
+		w.opts.Mode = WriteModeAdd
+	}
+	if opts.RateLimit > 0 {
+		w.limiter = rate.NewLimiter(rate.Limit(opts.RateLimit), 1)
+	}
+	return w
+}
+
+// WriteAll writes the documents and returns the error of each, in order, or
+// nil when every write succeeded. In atomic mode the documents are written
+// together or not at all, so they all share the error.
+func (w *FirestoreWriter) WriteAll(ctx context.Context, docs []FirestoreDocument) []error {
+	if w.limiter != nil {
+		for n := len(docs); n > 0; n-- {
+			if err := w.limiter.Wait(ctx); err != nil {
+				return repeatError(err, len(docs))
+			}
+		}
+	}
+	writes := make([]store.Write, len(docs))
+	for i, doc := range docs {
+		writes[i] = store.Write{Mode: w.opts.Mode, ID: doc.ID, Data: doc.Data}
+	}
+	return w.store.WriteBatch(ctx, w.collection, writes, w.opts.Atomic)
+}
+
+// Helper function to report the same error for every document of a batch
+func repeatError(err error, n int) []error {
+	errs := make([]error, n)
+	for i := range errs {
+		errs[i] = err
+	}
+	return errs
+}
+
+// AddFirebaseDocument adds one document to a Firebase collection.
+func AddFirebaseDocument(ctx context.Context, s store.DocumentStore, collection string, data map[string]interface{}) error {
+	errs := s.WriteBatch(ctx, collection, []store.Write{{Mode: WriteModeAdd, Data: data}}, false)
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
 // WriteFirebase writes the transformed data to a Firebase collection.
-func WriteFirebase(client *firestore.Client, collection string, data []map[string]interface{}) error {
+func WriteFirebase(s store.DocumentStore, collection string, data []map[string]interface{}) error {
 	for _, row := range data {
-		_, _, err := client.Collection(collection).Add(context.Background(), row)
-		if err != nil {
+		if err := AddFirebaseDocument(context.Background(), s, collection, row); err != nil {
 			return err
 		}
 	}
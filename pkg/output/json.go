@@ -1,13 +1,67 @@
 package output
 
 import (
+	"bufio"
 	"encoding/json"
 	"fmt"
 	"os"
 )
 
-// WriteJSON writes the transformed data to a JSON output file.
-func WriteJSON(filePath string, data []map[string]string) error {
+// JSONWriter writes a JSON array to a file one element at a time, formatted
+// like WriteJSON.
+type JSONWriter struct {
+	file  *os.File
+	buf   *bufio.Writer
+	count int
+}
+
+// CreateJSON creates a JSON file, truncating it if it exists.
+func CreateJSON(filePath string) (*JSONWriter, error) {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error creating JSON file: %w", err)
+	}
+	return &JSONWriter{file: file, buf: bufio.NewWriter(file)}, nil
+}
+
+// Write appends an element to the array.
+func (w *JSONWriter) Write(v interface{}) error {
+	dataBytes, err := json.MarshalIndent(v, "  ", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling data to JSON: %w", err)
+	}
+	sep := ",\n  "
+	if w.count == 0 {
+		sep = "[\n  "
+	}
+	w.count++
+	if _, err := w.buf.WriteString(sep); err != nil {
+		return fmt.Errorf("error writing JSON to file: %w", err)
+	}
+	if _, err := w.buf.Write(dataBytes); err != nil {
+		return fmt.Errorf("error writing JSON to file: %w", err)
+	}
+	return nil
+}
+
+// Close ends the array and closes the file.
+func (w *JSONWriter) Close() error {
+	end := "\n]"
+	if w.count == 0 {
+		end = "[]"
+	}
+	w.buf.WriteString(end)
+	if err := w.buf.Flush(); err != nil {
+		w.file.Close()
+		return fmt.Errorf("error writing JSON to file: %w", err)
+	}
+	return w.file.Close()
+}
+
+// WriteJSON writes the transformed data to a JSON output file. The data may be
+// any value encoding/json can marshal, such as []map[string]string or a slice
+// of records.
+func WriteJSON(filePath string, data interface{}) error {
 	// Marshal the data into JSON format with indentation.
 	dataBytes, err := json.MarshalIndent(data, "", "  ")
 	if err != nil {
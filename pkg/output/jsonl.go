@@ -0,0 +1,50 @@
+package output
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// JSONLWriter writes a JSON Lines file: one compact JSON object per line.
+type JSONLWriter struct {
+	file *os.File
+	buf  *bufio.Writer
+}
+
+// CreateJSONL creates a JSON Lines file, or opens it for appending when
+// appendToFile is set so that runs can add to an existing log.
+func CreateJSONL(filePath string, appendToFile bool) (*JSONLWriter, error) {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if appendToFile {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	file, err := os.OpenFile(filePath, flags, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("error creating JSON Lines file: %w", err)
+	}
+	return &JSONLWriter{file: file, buf: bufio.NewWriter(file)}, nil
+}
+
+// Write writes one value as a line.
+func (w *JSONLWriter) Write(v interface{}) error {
+	dataBytes, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("error marshaling data to JSON: %w", err)
+	}
+	dataBytes = append(dataBytes, '\n')
+	if _, err := w.buf.Write(dataBytes); err != nil {
+		return fmt.Errorf("error writing JSON Lines to file: %w", err)
+	}
+	return nil
+}
+
+// Close flushes the buffered lines and closes the file.
+func (w *JSONLWriter) Close() error {
+	if err := w.buf.Flush(); err != nil {
+		w.file.Close()
+		return fmt.Errorf("error writing JSON Lines to file: %w", err)
+	}
+	return w.file.Close()
+}
@@ -0,0 +1,137 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/avii09/hookit/pkg/record"
+)
+
+// DeadLetter writes the records that fail a transformation stage or cannot be
+// written to the output to a sink of their own, so that a partial failure does
+// not stop the pipeline and the failed records can be replayed later. Each
+// failed record is wrapped in a record of its own:
+//
+//	{"stage": "output", "error": "...", "failed_at": {"$timestamp": "..."}, "record": {...}}
+//
+// An error writing to the dead letter sink itself stops the pipeline.
+type DeadLetter struct {
+	Sink Sink
+
+	records chan *record.Record
+	stopped chan struct{}
+	err     error // set by the writer goroutine before stopped is closed
+
+	mu    sync.Mutex
+	count int
+}
+
+// deadLetterKey is the context key under which Run makes the dead letter
+// available to the stages.
+type deadLetterKey struct{}
+
+// Helper function to start writing to the dead letter sink
+func (d *DeadLetter) start(ctx context.Context) context.Context {
+	d.records = make(chan *record.Record)
+	d.stopped = make(chan struct{})
+	go func() {
+		defer close(d.stopped)
+		d.err = d.Sink.Write(ctx, IteratorFunc(func(ctx context.Context) (*record.Record, error) {
+			select {
+			case r, ok := <-d.records:
+				if !ok {
+					return nil, io.EOF
+				}
+				return r, nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}))
+	}()
+	return context.WithValue(ctx, deadLetterKey{}, d)
+}
+
+// Helper function to hand a failed record to the dead letter sink
+func (d *DeadLetter) send(ctx context.Context, stage string, r *record.Record, cause error) error {
+	entry := record.New()
+	entry.Set("stage", stage)
+	entry.Set("error", cause.Error())
+	entry.Set("failed_at", time.Now().UTC())
+	entry.Set("record", r)
+
+	select {
+	case d.records <- entry:
+		d.mu.Lock()
+		d.count++
+		d.mu.Unlock()
+		return nil
+	case <-d.stopped:
+		if d.err == nil {
+			return fmt.Errorf("error writing dead letter: sink stopped")
+		}
+		return fmt.Errorf("error writing dead letter: %w", d.err)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Helper function to let the dead letter sink write the records it has been
+// sent and return its error
+func (d *DeadLetter) finish() error {
+	close(d.records)
+	<-d.stopped
+	if d.err != nil {
+		return fmt.Errorf("error writing dead letter: %w", d.err)
+	}
+	return nil
+}
+
+// Count returns the number of records sent to the dead letter sink.
+func (d *DeadLetter) Count() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.count
+}
+
+// Helper function to handle a record that failed a stage. With a dead letter
+// sink, the record goes there and the pipeline goes on; without one, the error
+// is returned and stops the pipeline.
+func failRecord(ctx context.Context, stage string, r *record.Record, err error) error {
+	d, _ := ctx.Value(deadLetterKey{}).(*DeadLetter)
+	if d == nil {
+		return err
+	}
+	return d.send(ctx, stage, r, err)
+}
+
+// Helper function to apply a record transformer, handing a record that fails
+// to failRecord. A transformer that fails leaves the record as it was, so the
+// dead letter sink gets the record as it came into the stage.
+func applyRecord(ctx context.Context, t RecordTransformer, r *record.Record) (*record.Record, error) {
+	out, err := t.TransformRecord(ctx, r)
+	if err != nil {
+		return nil, failRecord(ctx, stageName(t), r, err)
+	}
+	return out, nil
+}
+
+// Helper function to name a transformation stage, such as "mapping", from its description
+func stageName(t Transformer) string {
+	name, _, _ := strings.Cut(describe(t), ":")
+	return name
+}
+
+// Helper function to check if an error writing a record is caused by the
+// record itself, such as a value JSON cannot represent, rather than the output
+func isRecordError(err error) bool {
+	var unsupportedValue *json.UnsupportedValueError
+	var unsupportedType *json.UnsupportedTypeError
+	var marshaler *json.MarshalerError
+	return errors.As(err, &unsupportedValue) || errors.As(err, &unsupportedType) || errors.As(err, &marshaler)
+}
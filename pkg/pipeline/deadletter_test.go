@@ -0,0 +1,120 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/avii09/hookit/pkg/record"
+	"github.com/avii09/hookit/pkg/transform"
+)
+
+func TestDeadLetterPayload(t *testing.T) {
+	set, err := newSetTransformer(transform.TransformationRules{Set: []transform.SetRule{
+		{Field: "total", Expr: "amount * qty"},
+		{Field: "meta.key", Template: "{region}-{id}"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, parallelism := range []int{1, 4} {
+		t.Run(fmt.Sprintf("parallelism %d", parallelism), func(t *testing.T) {
+			sink, deadLetter := &collectSink{}, &collectSink{}
+			p := &Pipeline{
+				Source: sliceSource(testRecords(t,
+					`{"id": 1, "region": "east", "amount": 2, "qty": 3}`,
+					`{"id": 2, "amount": 5, "qty": 1, "meta": {"source": "api"}}`,
+					`{"id": 3, "region": "west", "amount": 1, "qty": 1}`,
+				)),
+				Transformers:  []Transformer{set},
+				Sink:          sink,
+				DeadLetter:    &DeadLetter{Sink: deadLetter},
+				Parallelism:   parallelism,
+				PreserveOrder: true,
+			}
+			start := time.Now().UTC()
+			if err := p.Run(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			want := `{"id":1,"region":"east","amount":2,"qty":3,"total":6,"meta":{"key":"east-1"}}
+{"id":3,"region":"west","amount":1,"qty":1,"total":1,"meta":{"key":"west-3"}}`
+			if got := recordsJSON(t, sink.records, false); got != want {
+				t.Errorf("output:\n%s\nwant:\n%s", got, want)
+			}
+			if n := p.DeadLetter.Count(); n != 1 || len(deadLetter.records) != 1 {
+				t.Fatalf("got %d dead letter records (count %d), want 1", len(deadLetter.records), n)
+			}
+
+			// The failed record is sent as it came into the stage, without the
+			// total that the first set rule gave it
+			entry := deadLetter.records[0]
+			if got, want := entry.Keys(), []string{"stage", "error", "failed_at", "record"}; fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("got fields %v, want %v", got, want)
+			}
+			if stage, _ := entry.Get("stage"); stage != "set" {
+				t.Errorf("got stage %v, want set", stage)
+			}
+			if msg, _ := entry.Get("error"); msg != `template "{region}-{id}": field "region" not found` {
+				t.Errorf("got error %v", msg)
+			}
+			if at, _ := entry.Get("failed_at"); !isBetween(at, start, time.Now().UTC()) {
+				t.Errorf("got failed_at %v, want a time during the run", at)
+			}
+			original, _ := entry.Get("record")
+			r, ok := original.(*record.Record)
+			if !ok {
+				t.Fatalf("got record %T, want a record", original)
+			}
+			if got, want := recordsJSON(t, []*record.Record{r}, false), `{"id":2,"amount":5,"qty":1,"meta":{"source":"api"}}`; got != want {
+				t.Errorf("got record %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+// Helper function to check that a value is a time between start and end
+func isBetween(v interface{}, start, end time.Time) bool {
+	at, ok := v.(time.Time)
+	return ok && !at.Before(start) && !at.After(end)
+}
+
+func TestDeadLetterMappingFailure(t *testing.T) {
+	mapping, err := newMappingTransformer(transform.TransformationRules{Mapping: transform.MappingRules{
+		CustomMapping: []transform.FieldMapping{{From: "id", To: "key"}, {From: "region", To: "meta.region"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink, deadLetter := &collectSink{}, &collectSink{}
+	p := &Pipeline{
+		Source: sliceSource(testRecords(t,
+			`{"id": 1, "region": "east"}`,
+			`{"id": 2, "region": "west", "meta": "api"}`,
+		)),
+		Transformers: []Transformer{mapping},
+		Sink:         sink,
+		DeadLetter:   &DeadLetter{Sink: deadLetter},
+	}
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := recordsJSON(t, sink.records, false), `{"key":1,"meta":{"region":"east"}}`; got != want {
+		t.Errorf("output: got %s, want %s", got, want)
+	}
+	if len(deadLetter.records) != 1 {
+		t.Fatalf("got %d dead letter records, want 1", len(deadLetter.records))
+	}
+	entry := deadLetter.records[0]
+	if msg, _ := entry.Get("error"); msg != `error renaming "region" to "meta.region": cannot set "meta.region": field "region" is inside a string value` {
+		t.Errorf("got error %v", msg)
+	}
+	// The record is sent without the rename of id that came before the failure
+	original, _ := entry.Get("record")
+	if got, want := recordsJSON(t, []*record.Record{original.(*record.Record)}, false), `{"id":2,"region":"west","meta":"api"}`; got != want {
+		t.Errorf("got record %s, want %s", got, want)
+	}
+}
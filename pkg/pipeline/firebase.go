@@ -0,0 +1,79 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"cloud.google.com/go/firestore"
+	firebase "firebase.google.com/go"
+	"github.com/avii09/hookit/pkg/config"
+	"github.com/avii09/hookit/pkg/record"
+	"github.com/avii09/hookit/pkg/store"
+	"google.golang.org/api/option"
+)
+
+// defaultCredentialsFile is the Firebase service account key used when the
+// input or output config does not set credentials.
+const defaultCredentialsFile = "firebase-adminsdk.json"
+
+// emulatorHostEnv names the environment variable that points the Firestore
+// client at a local emulator, such as "localhost:8080".
+const emulatorHostEnv = "FIRESTORE_EMULATOR_HOST"
+
+// newFirestoreClient returns a Firestore client for the project of the config.
+// When FIRESTORE_EMULATOR_HOST is set, the client talks to the emulator and
+// needs no credentials. Otherwise the Firebase app is initialized with the
+// service account key file, and with project_id when set, which overrides the
+// project of the key.
+func newFirestoreClient(ctx context.Context, cfg config.EndpointConfig) (*firestore.Client, error) {
+	if os.Getenv(emulatorHostEnv) != "" {
+		projectID := cfg.ProjectID
+		if projectID == "" {
+			projectID = firestore.DetectProjectID
+		}
+		client, err := firestore.NewClient(ctx, projectID)
+		if err != nil {
+			return nil, fmt.Errorf("error connecting to the Firestore emulator: %w", err)
+		}
+		return client, nil
+	}
+
+	credentialsFile := cfg.Credentials
+	if credentialsFile == "" {
+		credentialsFile = defaultCredentialsFile
+	}
+
+	// Initialize Firebase app
+	var appConfig *firebase.Config
+	if cfg.ProjectID != "" {
+		appConfig = &firebase.Config{ProjectID: cfg.ProjectID}
+	}
+	opt := option.WithCredentialsFile(credentialsFile)
+	app, err := firebase.NewApp(ctx, appConfig, opt)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing Firebase app: %w", err)
+	}
+
+	// Initialize Firestore client
+	client, err := app.Firestore(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing Firestore client: %w", err)
+	}
+
+	return client, nil
+}
+
+// newDocumentStore returns the Firestore document store of the config.
+func newDocumentStore(ctx context.Context, cfg config.EndpointConfig) (store.DocumentStore, error) {
+	client, err := newFirestoreClient(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return store.NewFirestore(client), nil
+}
+
+// Helper function to convert document data to a record with sorted fields
+func fromDocument(data map[string]interface{}) *record.Record {
+	return record.Normalize(data).(*record.Record)
+}
@@ -0,0 +1,528 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/avii09/hookit/pkg/config"
+	"github.com/avii09/hookit/pkg/input"
+	"github.com/avii09/hookit/pkg/output"
+	"github.com/avii09/hookit/pkg/record"
+	"github.com/avii09/hookit/pkg/store"
+)
+
+// The Firestore tests run against the in-memory document store and, when
+// FIRESTORE_EMULATOR_HOST is set, against the Firestore emulator too:
+//
+//	gcloud emulators firestore start --host-port=localhost:8080
+//	FIRESTORE_EMULATOR_HOST=localhost:8080 go test ./pkg/pipeline -run Firestore
+
+// emulatorProject is the project the tests use in the emulator.
+const emulatorProject = "demo-hookit"
+
+// unclosedStore keeps a store open when the pipeline using it is closed, so
+// that a test can run several pipelines on it.
+type unclosedStore struct {
+	store.DocumentStore
+}
+
+func (unclosedStore) Close() error {
+	return nil
+}
+
+// Helper function to run a test against each document store, with an
+// endpoint config for a collection of its own
+func forEachStore(t *testing.T, test func(t *testing.T, s store.DocumentStore, cfg config.EndpointConfig)) {
+	t.Run("memory", func(t *testing.T) {
+		test(t, store.NewMemory(), config.EndpointConfig{Collection: "test"})
+	})
+	t.Run("emulator", func(t *testing.T) {
+		if os.Getenv(emulatorHostEnv) == "" {
+			t.Skip(emulatorHostEnv + " is not set")
+		}
+		name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+		cfg := config.EndpointConfig{
+			ProjectID:  emulatorProject,
+			Collection: fmt.Sprintf("%s_%d", name, time.Now().UnixNano()),
+		}
+		s, err := newDocumentStore(context.Background(), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { s.Close() })
+		test(t, unclosedStore{s}, cfg)
+	})
+}
+
+// sliceSource is a source of records held in memory.
+type sliceSource []*record.Record
+
+func (s sliceSource) Read(ctx context.Context) (Iterator, error) {
+	return SliceIterator(s), nil
+}
+
+// collectSink keeps the records written to it.
+type collectSink struct {
+	records []*record.Record
+}
+
+func (s *collectSink) Write(ctx context.Context, in Iterator) error {
+	records, err := Collect(ctx, in)
+	s.records = append(s.records, records...)
+	return err
+}
+
+// Helper function to build records from JSON objects
+func testRecords(t *testing.T, objects ...string) []*record.Record {
+	t.Helper()
+	records, err := record.DecodeJSON(strings.NewReader(strings.Join(objects, "\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+// Helper function to write records to a document store through a firebase sink
+func writeFirestore(t *testing.T, s store.DocumentStore, cfg config.EndpointConfig, deadLetter Sink, records ...*record.Record) {
+	t.Helper()
+	sink, err := newStoreSink(s, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Pipeline{Source: sliceSource(records), Sink: sink}
+	if deadLetter != nil {
+		p.DeadLetter = &DeadLetter{Sink: deadLetter}
+	}
+	defer p.Close()
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Helper function to read records from a document store through a firebase source
+func readFirestore(t *testing.T, s store.DocumentStore, cfg config.EndpointConfig) []*record.Record {
+	t.Helper()
+	source, err := newStoreSource(s, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := &collectSink{}
+	p := &Pipeline{Source: source, Sink: sink}
+	defer p.Close()
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return sink.records
+}
+
+// Helper function to encode records as JSON lines, sorted when unordered, for comparison
+func recordsJSON(t *testing.T, records []*record.Record, sorted bool) string {
+	t.Helper()
+	lines := make([]string, len(records))
+	for i, r := range records {
+		b, err := r.MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines[i] = string(b)
+	}
+	if sorted {
+		sort.Strings(lines)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestFirestoreWriteAndRead(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s store.DocumentStore, cfg config.EndpointConfig) {
+		writeFirestore(t, s, cfg, nil, testRecords(t,
+			`{"name": "Ann", "age": 31, "score": 9.5, "active": true, "tags": ["a", "b"], "address": {"city": "Pune"}}`,
+			`{"name": "Bob", "joined": {"$timestamp": "2024-05-01T12:00:00Z"}, "home": {"$geopoint": {"latitude": 52.37, "longitude": 4.89}}}`,
+			`{"name": "Cy", "manager": {"$ref": "users/ann"}}`,
+		)...)
+
+		got := readFirestore(t, s, cfg)
+		want := testRecords(t,
+			`{"active": true, "address": {"city": "Pune"}, "age": 31, "name": "Ann", "score": 9.5, "tags": ["a", "b"]}`,
+			`{"home": {"$geopoint": {"latitude": 52.37, "longitude": 4.89}}, "joined": {"$timestamp": "2024-05-01T12:00:00Z"}, "name": "Bob"}`,
+			`{"manager": {"$ref": "users/ann"}, "name": "Cy"}`,
+		)
+		if g, w := recordsJSON(t, got, true), recordsJSON(t, want, true); g != w {
+			t.Errorf("read back:\n%s\nwant:\n%s", g, w)
+		}
+	})
+}
+
+func TestFirestoreUpsert(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s store.DocumentStore, cfg config.EndpointConfig) {
+		for _, batchSize := range []int{0, 2} {
+			t.Run(fmt.Sprintf("batch_size=%d", batchSize), func(t *testing.T) {
+				cfg := cfg
+				cfg.Collection += fmt.Sprintf("_%d", batchSize)
+				cfg.Firestore = config.FirestoreOptions{WriteMode: output.WriteModeSet, IDField: "id", BatchSize: batchSize}
+				writeFirestore(t, s, cfg, nil, testRecords(t,
+					`{"id": "a", "n": 1, "old": true}`,
+					`{"id": "b", "n": 2}`,
+					`{"id": "c", "n": 3}`,
+				)...)
+
+				// Writing again under the same IDs replaces the documents instead of adding new ones
+				writeFirestore(t, s, cfg, nil, testRecords(t, `{"id": "a", "n": 10}`)...)
+
+				cfg.Firestore.WriteMode = output.WriteModeMerge
+				writeFirestore(t, s, cfg, nil, testRecords(t, `{"id": "b", "extra": "x"}`)...)
+
+				cfg.Firestore.WriteMode = output.WriteModeUpdate
+				writeFirestore(t, s, cfg, nil, testRecords(t, `{"id": "c", "n": 30}`)...)
+
+				read := cfg
+				read.Firestore = config.FirestoreOptions{OrderBy: []string{"id"}}
+				got := recordsJSON(t, readFirestore(t, s, read), false)
+				want := recordsJSON(t, testRecords(t,
+					`{"id": "a", "n": 10}`,
+					`{"extra": "x", "id": "b", "n": 2}`,
+					`{"id": "c", "n": 30}`,
+				), false)
+				if got != want {
+					t.Errorf("documents:\n%s\nwant:\n%s", got, want)
+				}
+			})
+		}
+	})
+}
+
+func TestFirestoreDocumentIDs(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s store.DocumentStore, cfg config.EndpointConfig) {
+		tests := []struct {
+			name    string
+			options config.FirestoreOptions
+			want    string
+			failed  string
+		}{
+			{
+				"template",
+				config.FirestoreOptions{WriteMode: output.WriteModeSet, IDTemplate: "{region}-{n}"},
+				"east-1, east-2",
+				"output 3",
+			},
+			{
+				"nested field",
+				config.FirestoreOptions{WriteMode: output.WriteModeCreate, IDField: "meta.key"},
+				"k1, k2",
+				"output 3",
+			},
+			{
+				"number field",
+				config.FirestoreOptions{WriteMode: output.WriteModeCreate, IDField: "n"},
+				"1, 2, 3",
+				"",
+			},
+		}
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				cfg := cfg
+				cfg.Collection += "_" + strings.ReplaceAll(test.name, " ", "_")
+				cfg.Firestore = test.options
+
+				// A record without the fields of its ID goes to the dead letter sink
+				deadLetter := &collectSink{}
+				writeFirestore(t, s, cfg, deadLetter, testRecords(t,
+					`{"region": "east", "n": 1, "meta": {"key": "k1"}}`,
+					`{"region": "east", "n": 2, "meta": {"key": "k2"}}`,
+					`{"n": 3, "meta": {"key": ""}}`,
+				)...)
+
+				read := config.EndpointConfig{ProjectID: cfg.ProjectID, Collection: cfg.Collection}
+				read.Firestore.IDField = "_id"
+				var ids []string
+				for _, r := range readFirestore(t, s, read) {
+					id, _ := r.Get("_id")
+					ids = append(ids, fmt.Sprint(id))
+				}
+				sort.Strings(ids)
+				if got := strings.Join(ids, ", "); got != test.want {
+					t.Errorf("document IDs: %s, want %s", got, test.want)
+				}
+
+				var failed []string
+				for _, r := range deadLetter.records {
+					stage, _ := r.Get("stage")
+					n, _ := r.Lookup("record.n")
+					failed = append(failed, fmt.Sprintf("%v %v", stage, n))
+				}
+				if got := strings.Join(failed, ", "); got != test.failed {
+					t.Errorf("dead letter records: %s, want %s", got, test.failed)
+				}
+			})
+		}
+	})
+}
+
+func TestFirestoreAtomicBatches(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s store.DocumentStore, cfg config.EndpointConfig) {
+		cfg.Firestore = config.FirestoreOptions{WriteMode: output.WriteModeCreate, IDField: "id"}
+		writeFirestore(t, s, cfg, nil, testRecords(t, `{"id": "b", "n": 0}`)...)
+
+		// The batch holding the existing document fails as a whole, the
+		// batch after it is still written
+		cfg.Firestore.BatchSize = 2
+		deadLetter := &collectSink{}
+		writeFirestore(t, s, cfg, deadLetter, testRecords(t,
+			`{"id": "a", "n": 1}`,
+			`{"id": "b", "n": 2}`,
+			`{"id": "c", "n": 3}`,
+		)...)
+
+		var failed []string
+		for _, r := range deadLetter.records {
+			n, _ := r.Lookup("record.n")
+			failed = append(failed, fmt.Sprint(n))
+		}
+		if got, want := strings.Join(failed, ", "), "1, 2"; got != want {
+			t.Errorf("dead letter records: %s, want %s", got, want)
+		}
+
+		read := config.EndpointConfig{ProjectID: cfg.ProjectID, Collection: cfg.Collection}
+		read.Firestore.OrderBy = []string{"id"}
+		got := recordsJSON(t, readFirestore(t, s, read), false)
+		if want := recordsJSON(t, testRecords(t, `{"id": "b", "n": 0}`, `{"id": "c", "n": 3}`), false); got != want {
+			t.Errorf("documents:\n%s\nwant:\n%s", got, want)
+		}
+	})
+}
+
+func TestFirestoreRateLimit(t *testing.T) {
+	s := store.NewMemory()
+	cfg := config.EndpointConfig{Collection: "test"}
+	cfg.Firestore.RateLimit = 20
+
+	// The first document is written at once, the other four wait 50ms each
+	start := time.Now()
+	writeFirestore(t, s, cfg, nil, testRecords(t, `{"n": 1}`, `{"n": 2}`, `{"n": 3}`, `{"n": 4}`, `{"n": 5}`)...)
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("wrote 5 documents at 20 per second in %v", elapsed)
+	}
+	if got := len(readFirestore(t, s, cfg)); got != 5 {
+		t.Errorf("got %d documents, want 5", got)
+	}
+}
+
+func TestFirestoreFailedWritesGoToDeadLetter(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s store.DocumentStore, cfg config.EndpointConfig) {
+		cfg.Firestore = config.FirestoreOptions{WriteMode: output.WriteModeCreate, IDField: "id"}
+		writeFirestore(t, s, cfg, nil, testRecords(t, `{"id": "a", "n": 1}`)...)
+
+		// Creating an existing document, updating a missing one and a record
+		// without an ID all fail without stopping the pipeline
+		deadLetter := &collectSink{}
+		writeFirestore(t, s, cfg, deadLetter, testRecords(t, `{"id": "a", "n": 2}`, `{"id": "b", "n": 3}`, `{"n": 4}`)...)
+		cfg.Firestore.WriteMode = output.WriteModeUpdate
+		writeFirestore(t, s, cfg, deadLetter, testRecords(t, `{"id": "missing", "n": 5}`)...)
+
+		var failed []string
+		for _, r := range deadLetter.records {
+			stage, _ := r.Get("stage")
+			n, _ := r.Lookup("record.n")
+			failed = append(failed, fmt.Sprintf("%v %v", stage, n))
+		}
+		sort.Strings(failed)
+		if got, want := strings.Join(failed, ", "), "output 2, output 4, output 5"; got != want {
+			t.Errorf("dead letter records: %s, want %s", got, want)
+		}
+
+		got := recordsJSON(t, readFirestore(t, s, config.EndpointConfig{ProjectID: cfg.ProjectID, Collection: cfg.Collection}), true)
+		if want := recordsJSON(t, testRecords(t, `{"id": "a", "n": 1}`, `{"id": "b", "n": 3}`), true); got != want {
+			t.Errorf("documents:\n%s\nwant:\n%s", got, want)
+		}
+	})
+}
+
+func TestFirestoreQuery(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s store.DocumentStore, cfg config.EndpointConfig) {
+		var seed []*record.Record
+		for i := 0; i < 10; i++ {
+			status := "even"
+			if i%2 == 1 {
+				status = "odd"
+			}
+			seed = append(seed, testRecords(t, fmt.Sprintf(`{"id": "doc%d", "n": %d, "status": %q, "note": "x"}`, i, i, status))...)
+		}
+		write := cfg
+		write.Firestore = config.FirestoreOptions{WriteMode: output.WriteModeCreate, IDField: "id"}
+		writeFirestore(t, s, write, nil, seed...)
+
+		cfg.Firestore = config.FirestoreOptions{
+			Where:    []config.FirestoreFilter{{Field: "status", Op: "==", Value: "even"}, {Field: "n", Op: ">", Value: 0}},
+			OrderBy:  []string{"n desc"},
+			Limit:    3,
+			Select:   []string{"n"},
+			PageSize: 2,
+			IDField:  "_id",
+		}
+		got := recordsJSON(t, readFirestore(t, s, cfg), false)
+		want := recordsJSON(t, testRecords(t,
+			`{"_id": "doc8", "n": 8}`,
+			`{"_id": "doc6", "n": 6}`,
+			`{"_id": "doc4", "n": 4}`,
+		), false)
+		if got != want {
+			t.Errorf("query results:\n%s\nwant:\n%s", got, want)
+		}
+	})
+}
+
+func TestFirestoreCollectionGroup(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s store.DocumentStore, cfg config.EndpointConfig) {
+		// Collections with the same name, at the top and under two documents
+		name := cfg.Collection
+		for i, collection := range []string{name, "parents/p1/" + name, "parents/p2/" + name} {
+			write := cfg
+			write.Collection = collection
+			write.Firestore = config.FirestoreOptions{WriteMode: output.WriteModeCreate, IDField: "id"}
+			writeFirestore(t, s, write, nil, testRecords(t, fmt.Sprintf(`{"id": "d%d", "n": %d}`, i, i))...)
+		}
+
+		cfg.Firestore = config.FirestoreOptions{CollectionGroup: true, OrderBy: []string{"n"}, IDField: "_id", PathField: "_path"}
+		got := recordsJSON(t, readFirestore(t, s, cfg), false)
+		want := recordsJSON(t, testRecords(t,
+			fmt.Sprintf(`{"_id": "d0", "_path": "%s/d0", "id": "d0", "n": 0}`, name),
+			fmt.Sprintf(`{"_id": "d1", "_path": "parents/p1/%s/d1", "id": "d1", "n": 1}`, name),
+			fmt.Sprintf(`{"_id": "d2", "_path": "parents/p2/%s/d2", "id": "d2", "n": 2}`, name),
+		), false)
+		if got != want {
+			t.Errorf("collection group:\n%s\nwant:\n%s", got, want)
+		}
+
+		// Without collection_group only the top collection is read
+		cfg.Firestore.CollectionGroup = false
+		if got := len(readFirestore(t, s, cfg)); got != 1 {
+			t.Errorf("collection: got %d documents, want 1", got)
+		}
+	})
+}
+
+func TestFirestoreReadErrors(t *testing.T) {
+	// Query errors stop the pipeline instead of ending the input early
+	tests := []struct {
+		name string
+		cfg  config.EndpointConfig
+		err  string
+	}{
+		{
+			"invalid operator",
+			config.EndpointConfig{Collection: "test", Firestore: config.FirestoreOptions{Where: []config.FirestoreFilter{{Field: "n", Op: "~", Value: 1}}}},
+			`error reading Firestore documents: invalid operator "~"`,
+		},
+		{
+			"invalid collection",
+			config.EndpointConfig{Collection: "a/b"},
+			`error reading Firestore documents: invalid collection path "a/b"`,
+		},
+		{
+			"invalid collection group",
+			config.EndpointConfig{Collection: "a/b/c", Firestore: config.FirestoreOptions{CollectionGroup: true}},
+			`error reading Firestore documents: invalid collection group "a/b/c"`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := store.NewMemory()
+			writeFirestore(t, s, config.EndpointConfig{Collection: "test"}, nil, testRecords(t, `{"n": 1}`)...)
+			source, err := newStoreSource(s, test.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := &Pipeline{Source: source, Sink: &collectSink{}}
+			defer p.Close()
+			if err := p.Run(context.Background()); err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("got error %v, want %s", err, test.err)
+			}
+		})
+	}
+}
+
+func TestFirestoreSubcollectionsAndReferences(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s store.DocumentStore, cfg config.EndpointConfig) {
+		ctx := context.Background()
+		customers := cfg.Collection + "_customers"
+		docs := map[string]map[string]interface{}{
+			customers + "/ann":                    {"name": "Ann"},
+			cfg.Collection + "/o1":                {"total": 30, "customer": record.Reference{Path: customers + "/ann"}},
+			cfg.Collection + "/o1/items/i1":       {"sku": "A", "qty": 1},
+			cfg.Collection + "/o1/items/i2":       {"sku": "B", "qty": 2},
+			cfg.Collection + "/o2":                {"total": 0, "customer": record.Reference{Path: customers + "/gone"}},
+			cfg.Collection + "/o1/items/i2/x/ign": {"ignored": true},
+		}
+		for path, data := range docs {
+			if err := s.Set(ctx, path, data); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		cfg.Firestore = config.FirestoreOptions{
+			IDField:           "_id",
+			OrderBy:           []string{"total desc"},
+			Subcollections:    []string{"items"},
+			ResolveReferences: []string{"customer"},
+		}
+		got := recordsJSON(t, readFirestore(t, s, cfg), false)
+		want := recordsJSON(t, testRecords(t,
+			`{"_id": "o1", "customer": {"_id": "ann", "name": "Ann"}, "total": 30, "items": [{"_id": "i1", "qty": 1, "sku": "A"}, {"_id": "i2", "qty": 2, "sku": "B"}]}`,
+			`{"_id": "o2", "customer": null, "total": 0, "items": []}`,
+		), false)
+		if got != want {
+			t.Errorf("nested:\n%s\nwant:\n%s", got, want)
+		}
+
+		cfg.Firestore.SubcollectionMode = config.SubcollectionsFlatten
+		got = recordsJSON(t, readFirestore(t, s, cfg), false)
+		want = recordsJSON(t, testRecords(t,
+			`{"_id": "o1", "customer": {"_id": "ann", "name": "Ann"}, "total": 30}`,
+			fmt.Sprintf(`{"%s_id": "o1", "_id": "i1", "qty": 1, "sku": "A"}`, cfg.Collection),
+			fmt.Sprintf(`{"%s_id": "o1", "_id": "i2", "qty": 2, "sku": "B"}`, cfg.Collection),
+			`{"_id": "o2", "customer": null, "total": 0}`,
+		), false)
+		if got != want {
+			t.Errorf("flattened:\n%s\nwant:\n%s", got, want)
+		}
+
+		// A path of names reads a subcollection of a subcollection
+		cfg.Firestore.Subcollections = []string{"items", "items/x"}
+		got = recordsJSON(t, readFirestore(t, s, cfg), false)
+		want = recordsJSON(t, testRecords(t,
+			`{"_id": "o1", "customer": {"_id": "ann", "name": "Ann"}, "total": 30}`,
+			fmt.Sprintf(`{"%s_id": "o1", "_id": "i1", "qty": 1, "sku": "A"}`, cfg.Collection),
+			fmt.Sprintf(`{"%s_id": "o1", "_id": "i2", "qty": 2, "sku": "B"}`, cfg.Collection),
+			fmt.Sprintf(`{"%s_id": "o1", "items_id": "i2", "_id": "ign", "ignored": true}`, cfg.Collection),
+			`{"_id": "o2", "customer": null, "total": 0}`,
+		), false)
+		if got != want {
+			t.Errorf("nested subcollection paths:\n%s\nwant:\n%s", got, want)
+		}
+	})
+}
+
+func TestFirestoreReadWriteFunctions(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s store.DocumentStore, cfg config.EndpointConfig) {
+		data := []map[string]interface{}{{"name": "Ann"}, {"name": "Bob"}}
+		if err := output.WriteFirebase(s, cfg.Collection, data); err != nil {
+			t.Fatal(err)
+		}
+		docs, err := input.ReadFirebase(s, cfg.Collection)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, doc := range docs {
+			names = append(names, fmt.Sprint(doc["name"]))
+		}
+		sort.Strings(names)
+		if got := strings.Join(names, ","); got != "Ann,Bob" {
+			t.Errorf("read %s, want Ann,Bob", got)
+		}
+	})
+}
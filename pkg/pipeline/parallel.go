@@ -0,0 +1,204 @@
+package pipeline
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/avii09/hookit/pkg/record"
+)
+
+// RecordTransformer is a stateless transformer that handles each record on
+// its own. TransformRecord returns the transformed record, or nil to drop it.
+// When it fails, it must leave the record as it was.
+// Consecutive record transformers run together on a worker pool when the
+// pipeline's parallelism is above 1, so TransformRecord must be safe to call
+// from several goroutines.
+type RecordTransformer interface {
+	Transformer
+	TransformRecord(ctx context.Context, r *record.Record) (*record.Record, error)
+}
+
+// batch is a run of consecutive records, numbered so that the results can be
+// put back in input order.
+type batch struct {
+	seq     int
+	records []*record.Record
+	err     error
+}
+
+// parallelIterator fans batches of records out to worker goroutines that
+// apply a chain of record transformers, and merges their results.
+type parallelIterator struct {
+	cancel  context.CancelFunc
+	results chan batch
+	slots   chan struct{} // limits the batches in flight
+	ordered bool
+
+	pending map[int]batch // results that arrived ahead of their turn
+	nextSeq int
+	current []*record.Record
+	err     error
+}
+
+// Helper function to run a chain of record transformers on a worker pool.
+// The first error cancels every worker and ends the stream.
+func parallelRecords(ctx context.Context, in Iterator, chain []RecordTransformer, workers, batchSize int, ordered bool) *parallelIterator {
+	ctx, cancel := context.WithCancel(ctx)
+	it := &parallelIterator{
+		cancel:  cancel,
+		results: make(chan batch, workers),
+		slots:   make(chan struct{}, 2*workers),
+		ordered: ordered,
+		pending: make(map[int]batch),
+	}
+
+	// The results close once the reader and every worker have returned, so
+	// that Close leaves no goroutine behind.
+	var wg sync.WaitGroup
+	jobs := make(chan batch, workers)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		it.readBatches(ctx, in, jobs, batchSize)
+	}()
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				result := transformBatch(ctx, job, chain)
+				select {
+				case it.results <- result:
+				case <-ctx.Done():
+					return
+				}
+				if result.err != nil {
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(it.results)
+	}()
+	return it
+}
+
+// Helper function to read batches from the input and hand them to the workers
+func (it *parallelIterator) readBatches(ctx context.Context, in Iterator, jobs chan<- batch, batchSize int) {
+	defer close(jobs)
+	for seq := 0; ; seq++ {
+		select {
+		case it.slots <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+
+		job := batch{seq: seq}
+		for len(job.records) < batchSize {
+			r, err := in.Next(ctx)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				job.err = err
+				break
+			}
+			job.records = append(job.records, r)
+		}
+		if len(job.records) == 0 && job.err == nil {
+			return
+		}
+
+		select {
+		case jobs <- job:
+		case <-ctx.Done():
+			return
+		}
+		if job.err != nil || len(job.records) < batchSize {
+			return
+		}
+	}
+}
+
+// Helper function to apply a chain of record transformers to a batch. A read
+// error in the batch is passed on after the records read before it.
+func transformBatch(ctx context.Context, job batch, chain []RecordTransformer) batch {
+	out := batch{seq: job.seq, records: job.records[:0]}
+	for _, r := range job.records {
+		var err error
+		for _, t := range chain {
+			if r, err = applyRecord(ctx, t, r); err != nil || r == nil {
+				break
+			}
+		}
+		if err != nil {
+			out.err = err
+			return out
+		}
+		if r != nil {
+			out.records = append(out.records, r)
+		}
+	}
+	out.err = job.err
+	return out
+}
+
+// Next returns the next transformed record.
+func (it *parallelIterator) Next(ctx context.Context) (*record.Record, error) {
+	for len(it.current) == 0 {
+		if it.err != nil {
+			return nil, it.err
+		}
+		next, err := it.nextBatch(ctx)
+		if err != nil {
+			it.err = err
+			it.cancel()
+			return nil, err
+		}
+		it.current = next.records
+		<-it.slots
+		if next.err != nil {
+			// Emit the records before the error first.
+			it.err = next.err
+			it.cancel()
+		}
+	}
+	r := it.current[0]
+	it.current = it.current[1:]
+	return r, nil
+}
+
+// Helper function to receive the next batch, in input order when ordered
+func (it *parallelIterator) nextBatch(ctx context.Context) (batch, error) {
+	for {
+		if b, ok := it.pending[it.nextSeq]; ok {
+			delete(it.pending, it.nextSeq)
+			it.nextSeq++
+			return b, nil
+		}
+		select {
+		case b, ok := <-it.results:
+			if !ok {
+				return batch{}, io.EOF
+			}
+			if b.err != nil || !it.ordered {
+				// Errors stop the stream at once; unordered results go out as they arrive.
+				return b, nil
+			}
+			it.pending[b.seq] = b
+		case <-ctx.Done():
+			return batch{}, ctx.Err()
+		}
+	}
+}
+
+// Close stops the workers and waits for them to finish.
+func (it *parallelIterator) Close() error {
+	it.cancel()
+	for range it.results {
+	}
+	return nil
+}
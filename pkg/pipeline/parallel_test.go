@@ -0,0 +1,180 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/avii09/hookit/pkg/record"
+)
+
+// funcTransformer is a record transformer that calls a function.
+type funcTransformer func(r *record.Record) (*record.Record, error)
+
+func (f funcTransformer) Transform(ctx context.Context, in Iterator) Iterator {
+	return transformRecords(ctx, in, f)
+}
+
+func (f funcTransformer) TransformRecord(ctx context.Context, r *record.Record) (*record.Record, error) {
+	return f(r)
+}
+
+// Helper function to return an iterator over records numbered from 0, without
+// end when n is negative
+func numberedRecords(n int) Iterator {
+	i := 0
+	return IteratorFunc(func(ctx context.Context) (*record.Record, error) {
+		if i == n {
+			return nil, io.EOF
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		r := record.New()
+		r.Set("n", int64(i))
+		i++
+		return r, nil
+	})
+}
+
+// Helper function to wait for the number of goroutines to fall back to want
+func waitForGoroutines(t *testing.T, want int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > want {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<16)
+			t.Fatalf("%d goroutines left, want %d:\n%s", runtime.NumGoroutine(), want, buf[:runtime.Stack(buf, true)])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestParallelKeepsOrder(t *testing.T) {
+	// Later batches finish first, and every third record is dropped
+	chain := []RecordTransformer{funcTransformer(func(r *record.Record) (*record.Record, error) {
+		n, _ := r.Get("n")
+		if n.(int64)%3 == 2 {
+			return nil, nil
+		}
+		time.Sleep(time.Duration(50-n.(int64)%50) * time.Microsecond)
+		r.Set("seen", true)
+		return r, nil
+	})}
+	it := parallelRecords(context.Background(), numberedRecords(1000), chain, 4, 7, true)
+	defer it.Close()
+
+	records, err := Collect(context.Background(), it)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want int64
+	for _, r := range records {
+		if want%3 == 2 {
+			want++
+		}
+		if n, _ := r.Get("n"); n != want {
+			t.Fatalf("got record %v, want %d", n, want)
+		}
+		if seen, _ := r.Get("seen"); seen != true {
+			t.Fatalf("record %d was not transformed", want)
+		}
+		want++
+	}
+	if want != 1000 {
+		t.Errorf("got records up to %d, want 1000", want)
+	}
+}
+
+func TestParallelErrorCancelsWorkers(t *testing.T) {
+	before := runtime.NumGoroutine()
+	failure := errors.New("bad record")
+	var calls atomic.Int64
+	chain := []RecordTransformer{funcTransformer(func(r *record.Record) (*record.Record, error) {
+		calls.Add(1)
+		if n, _ := r.Get("n"); n == int64(50) {
+			return nil, failure
+		}
+		return r, nil
+	})}
+
+	// The input never ends, so the workers only stop if the error stops them
+	it := parallelRecords(context.Background(), numberedRecords(-1), chain, 4, 10, true)
+	_, err := Collect(context.Background(), it)
+	if !errors.Is(err, failure) {
+		t.Errorf("got error %v, want %v", err, failure)
+	}
+	it.Close()
+	waitForGoroutines(t, before)
+
+	// At most the batches in flight are transformed after the failing one
+	if n := calls.Load(); n > 60+2*4*10 {
+		t.Errorf("transformed %d records; the error should have stopped the workers", n)
+	}
+}
+
+func TestParallelContextCancel(t *testing.T) {
+	before := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+	chain := []RecordTransformer{funcTransformer(func(r *record.Record) (*record.Record, error) {
+		return r, nil
+	})}
+	it := parallelRecords(ctx, numberedRecords(-1), chain, 4, 10, false)
+
+	for i := 0; i < 100; i++ {
+		if _, err := it.Next(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cancel()
+	var err error
+	for i := 0; err == nil; i++ {
+		if i > 1000 {
+			t.Fatal("the stream did not stop after the context was cancelled")
+		}
+		_, err = it.Next(ctx)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+	it.Close()
+	waitForGoroutines(t, before)
+}
+
+func TestParallelPipeline(t *testing.T) {
+	// Parallel runs give the same output as sequential ones
+	var data []string
+	for i := 0; i < 500; i++ {
+		data = append(data, fmt.Sprintf(`{"n": %d}`, i))
+	}
+	double := funcTransformer(func(r *record.Record) (*record.Record, error) {
+		n, _ := r.Get("n")
+		r.Set("double", n.(int64)*2)
+		return r, nil
+	})
+
+	var outputs []string
+	for _, parallelism := range []int{1, 8} {
+		sink := &collectSink{}
+		p := &Pipeline{
+			Source:        sliceSource(testRecords(t, data...)),
+			Transformers:  []Transformer{double},
+			Sink:          sink,
+			Parallelism:   parallelism,
+			BatchSize:     16,
+			PreserveOrder: true,
+		}
+		if err := p.Run(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		outputs = append(outputs, recordsJSON(t, sink.records, false))
+	}
+	if outputs[0] != outputs[1] {
+		t.Error("parallel output differs from sequential output")
+	}
+}
@@ -0,0 +1,211 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/avii09/hookit/pkg/config"
+	"github.com/avii09/hookit/pkg/transform"
+)
+
+// Source reads records from a pipeline input such as a file or a Firestore
+// collection. Read opens the input and returns an iterator over its records;
+// an iterator that holds resources such as an open file implements io.Closer.
+type Source interface {
+	Read(ctx context.Context) (Iterator, error)
+}
+
+// Transformer applies one transformation stage to a stream of records,
+// returning the stream of transformed records.
+type Transformer interface {
+	Transform(ctx context.Context, in Iterator) Iterator
+}
+
+// Sink writes a stream of records to a pipeline output.
+type Sink interface {
+	Write(ctx context.Context, in Iterator) error
+}
+
+// Pipeline connects a source, an ordered list of transformers and a sink.
+//
+// With a Parallelism above 1, consecutive record transformers run together on
+// that many worker goroutines, each handling BatchSize records at a time. The
+// results keep their input order when PreserveOrder is set.
+//
+// With a DeadLetter, records that fail a transformation stage or cannot be
+// written are sent there instead of stopping the pipeline.
+type Pipeline struct {
+	Source       Source
+	Transformers []Transformer
+	Sink         Sink
+	DeadLetter   *DeadLetter
+
+	Parallelism   int
+	BatchSize     int
+	PreserveOrder bool
+}
+
+// New builds a pipeline from the configuration, looking up the input, output
+// and transformation types in the registry.
+func New(cfg config.Config) (*Pipeline, error) {
+	source, err := NewSource(cfg.Pipeline.Input.Type, cfg.Pipeline.Input.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	transformers, err := newTransformers(cfg)
+	if err != nil {
+		closeIfCloser(source)
+		return nil, err
+	}
+
+	sink, err := NewSink(cfg.Pipeline.Output.Type, cfg.Pipeline.Output.Config)
+	if err != nil {
+		closeIfCloser(source)
+		return nil, err
+	}
+
+	var deadLetter *DeadLetter
+	if cfg.Pipeline.DeadLetter != nil {
+		deadLetterSink, err := NewSink(cfg.Pipeline.DeadLetter.Type, cfg.Pipeline.DeadLetter.Config)
+		if err != nil {
+			closeIfCloser(source)
+			closeIfCloser(sink)
+			return nil, fmt.Errorf("error creating dead letter output: %w", err)
+		}
+		deadLetter = &DeadLetter{Sink: deadLetterSink}
+	}
+
+	return &Pipeline{
+		Source:        source,
+		Transformers:  transformers,
+		Sink:          sink,
+		DeadLetter:    deadLetter,
+		Parallelism:   cfg.Pipeline.Parallelism,
+		BatchSize:     cfg.Pipeline.BatchSizeOrDefault(),
+		PreserveOrder: cfg.Pipeline.PreserveOrderOrDefault(),
+	}, nil
+}
+
+// newTransformers creates the configured transformation stages in order.
+func newTransformers(cfg config.Config) ([]Transformer, error) {
+	var transformers []Transformer
+	for _, step := range stages(cfg.Pipeline.Transformations) {
+		transformer, err := NewTransformer(step.Type, step.Rules)
+		if err != nil {
+			return nil, err
+		}
+		// A nil transformer means the stage is not configured.
+		if transformer != nil {
+			transformers = append(transformers, transformer)
+		}
+	}
+	return transformers, nil
+}
+
+// Helper function to list the transformation stages of the config with the
+// rules of each: the steps in the order they are listed, or else every stage
+// in the default order
+func stages(transformations transform.Transformations) []transform.Step {
+	if transformations.Steps != nil {
+		return transformations.Steps
+	}
+	steps := make([]transform.Step, len(transform.Stages))
+	for i, stage := range transform.Stages {
+		steps[i] = transform.Step{Type: stage, Rules: transformations.TransformationRules}
+	}
+	return steps
+}
+
+// Run streams the records of the source through the transformers in order and
+// into the sink. Records are processed one at a time, so memory use does not
+// grow with the size of the input except in blocking stages such as
+// aggregations. Cancelling the context stops the pipeline.
+func (p *Pipeline) Run(ctx context.Context) (err error) {
+	if p.DeadLetter != nil {
+		ctx = p.DeadLetter.start(ctx)
+		defer func() {
+			if dlErr := p.DeadLetter.finish(); err == nil {
+				err = dlErr
+			}
+			if n := p.DeadLetter.Count(); n > 0 {
+				log.Printf("%d failed records written to the dead letter output", n)
+			}
+		}()
+	}
+
+	source, err := p.Source.Read(ctx)
+	if err != nil {
+		return fmt.Errorf("error reading input: %w", err)
+	}
+	defer closeIfCloser(source)
+
+	it := stageIterator(source, "reading input")
+	for i := 0; i < len(p.Transformers); {
+		if chain := recordChain(p.Transformers[i:]); p.Parallelism > 1 && len(chain) > 0 {
+			workers := parallelRecords(ctx, it, chain, p.Parallelism, p.batchSize(), p.PreserveOrder)
+			defer workers.Close()
+			it = stageIterator(workers, "applying transformations")
+			i += len(chain)
+			continue
+		}
+		it = stageIterator(p.Transformers[i].Transform(ctx, it), "applying transformations")
+		i++
+	}
+
+	if err := p.Sink.Write(ctx, it); err != nil {
+		var stageErr *stageError
+		if errors.As(err, &stageErr) || errors.Is(err, ctx.Err()) {
+			return err
+		}
+		return fmt.Errorf("error writing output: %w", err)
+	}
+
+	return nil
+}
+
+// Helper function to return the batch size, with a default for pipelines built in code
+func (p *Pipeline) batchSize() int {
+	if p.BatchSize <= 0 {
+		return config.Execution{}.BatchSizeOrDefault()
+	}
+	return p.BatchSize
+}
+
+// Helper function to return the record transformers at the start of a list of stages
+func recordChain(transformers []Transformer) []RecordTransformer {
+	var chain []RecordTransformer
+	for _, t := range transformers {
+		rt, ok := t.(RecordTransformer)
+		if !ok {
+			break
+		}
+		chain = append(chain, rt)
+	}
+	return chain
+}
+
+// Close releases any resources held by the source and sinks.
+func (p *Pipeline) Close() error {
+	errs := []error{closeIfCloser(p.Source), closeIfCloser(p.Sink)}
+	if p.DeadLetter != nil {
+		errs = append(errs, closeIfCloser(p.DeadLetter.Sink))
+	}
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Helper function to close a component if it holds resources
+func closeIfCloser(v interface{}) error {
+	if closer, ok := v.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
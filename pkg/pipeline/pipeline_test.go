@@ -0,0 +1,361 @@
+package pipeline
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/avii09/hookit/pkg/config"
+	"github.com/avii09/hookit/pkg/record"
+	"github.com/avii09/hookit/pkg/transform"
+)
+
+// registered names the plugins the tests have registered, so that running a
+// test again does not register them twice. The plugins report what they saw
+// through the variables below it, since their closures outlive the first run.
+var registered = make(map[string]bool)
+
+var (
+	testSink      *collectSink
+	testStepRules transform.TransformationRules
+)
+
+// Helper function to register test plugins the first time a test runs
+func registerOnce(name string, register func()) {
+	if !registered[name] {
+		registered[name] = true
+		register()
+	}
+}
+
+// Helper function to load a config from YAML text
+func loadTestConfig(t *testing.T, content string) config.Config {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "pipeline.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestTransformationSteps(t *testing.T) {
+	cfg := loadTestConfig(t, `
+pipeline:
+  input:
+    type: json
+    config:
+      filePath: in.json
+  transformations:
+    - type: mapping
+      custom_mapping:
+        - from: amt
+          to: amount
+    - type: filter
+      condition: "amount > 6"
+    - type: set
+      field: total
+      expr: "amount * qty"
+    - type: aggregation
+      group_by: [region]
+      aggregation:
+        - operation: sum
+          column: total
+          as: total
+    - type: filter
+      condition: "total > 50"
+  output:
+    type: json
+    config:
+      filePath: out.json
+`)
+	transformers, err := newTransformers(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transformers) != 5 {
+		t.Fatalf("got %d stages, want 5", len(transformers))
+	}
+
+	source := sliceSource(testRecords(t,
+		`{"region": "east", "amt": 10, "qty": 2}`,
+		`{"region": "west", "amt": 5, "qty": 1}`,
+		`{"region": "east", "amt": 7, "qty": 3}`,
+		`{"region": "west", "amt": 100, "qty": 1}`,
+	))
+	sink := &collectSink{}
+	p := &Pipeline{Source: source, Transformers: transformers, Sink: sink}
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := recordsJSON(t, sink.records, false), `{"region":"west","total":100}`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestTransformationRulesKeepDefaultOrder(t *testing.T) {
+	cfg := loadTestConfig(t, `
+pipeline:
+  input:
+    type: json
+    config:
+      filePath: in.json
+  transformations:
+    unflatten: true
+    filter:
+      - column: age
+        condition: "> 30"
+    mapping:
+      dynamic_mapping: true
+  output:
+    type: json
+    config:
+      filePath: out.json
+`)
+	plan, err := NewPlan(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"filter: keep records where age > 30", "mapping: lowercase keys", `unflatten: split field names at "." into nested objects`}
+	if len(plan.Stages) != len(want) {
+		t.Fatalf("got stages %q, want %q", plan.Stages, want)
+	}
+	for i := range want {
+		if plan.Stages[i] != want[i] {
+			t.Errorf("stage %d: got %q, want %q", i+1, plan.Stages[i], want[i])
+		}
+	}
+}
+
+func TestPlanString(t *testing.T) {
+	cfg := loadTestConfig(t, `
+pipeline:
+  input:
+    type: firebase
+    config:
+      collection: orders
+      collection_group: true
+      where:
+        - field: total
+          op: ">"
+          value: 10
+        - field: region
+          op: "=="
+          value: east
+      order_by: [total desc]
+      limit: 5
+  transformations:
+    filter:
+      - column: total
+        condition: "> 30"
+  output:
+    type: csv
+    config:
+      filePath: out.csv
+  dead_letter:
+    type: jsonl
+    config:
+      filePath: rejects.jsonl
+  parallelism: 4
+  batch_size: 50
+  preserve_order: false
+`)
+	plan, err := NewPlan(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `1. read firebase collection group "orders" where total > 10 and region == east ordered by total desc limited to 5 documents
+2. filter: keep records where total > 30
+3. write csv file "out.csv"
+records that fail a stage are written to jsonl file "rejects.jsonl"
+filters, mappings, set rules and flattening run on 4 workers in batches of 50 records, unordered
+`
+	if got := plan.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cfg := loadTestConfig(t, `
+pipeline:
+  input:
+    type: xml
+    config:
+      filePath: in.xml
+  output:
+    type: parquet
+    config:
+      filePath: out.parquet
+  dead_letter:
+    type: queue
+`)
+	// The errors start with the path of the config file
+	var got []string
+	for _, err := range Validate(cfg) {
+		got = append(got, filepath.Base(err.Error()))
+	}
+	want := []string{
+		`pipeline.yaml:4:5: unsupported input type "xml" (supported: ` + strings.Join(SourceTypes(), ", ") + `)`,
+		`pipeline.yaml:8:5: unsupported output type "parquet" (supported: ` + strings.Join(SinkTypes(), ", ") + `)`,
+		`pipeline.yaml:12:5: unsupported dead_letter type "queue" (supported: ` + strings.Join(SinkTypes(), ", ") + `)`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// A plan is not built for an invalid config
+	if _, err := NewPlan(cfg); err == nil || filepath.Base(err.Error()) != want[0] {
+		t.Errorf("NewPlan: got error %v, want %s", err, want[0])
+	}
+}
+
+func TestRegisteredTransformationStep(t *testing.T) {
+	// A registered stage can be a step, with the transformation rules as its options
+	registerOnce("test-step", func() {
+		RegisterTransformer("test-step", func(rules transform.TransformationRules) (Transformer, error) {
+			testStepRules = rules
+			return nil, nil
+		})
+	})
+	cfg := loadTestConfig(t, `
+pipeline:
+  input:
+    type: json
+    config:
+      filePath: in.json
+  transformations:
+    - type: test-step
+      group_by: [region]
+  output:
+    type: json
+    config:
+      filePath: out.json
+`)
+	if _, err := newTransformers(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if got := testStepRules; len(got.GroupBy) != 1 || got.GroupBy[0] != "region" {
+		t.Errorf("got rules %+v, want group_by [region]", got)
+	}
+	if err := transform.ValidateStepType("test-step"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	// Plugins are looked up by the types named in the config
+	registerOnce("test-source", func() {
+		RegisterSource("test-source", func(cfg config.EndpointConfig) (Source, error) {
+			first, second := record.New(), record.New()
+			first.Set("id", int64(1))
+			first.Set("path", cfg.FilePath)
+			second.Set("id", int64(2))
+			return sliceSource([]*record.Record{first, second}), nil
+		})
+		RegisterSink("test-sink", func(cfg config.EndpointConfig) (Sink, error) {
+			testSink = &collectSink{}
+			return testSink, nil
+		})
+		RegisterTransformer("test-double", func(rules transform.TransformationRules) (Transformer, error) {
+			return funcTransformer(func(r *record.Record) (*record.Record, error) {
+				id, _ := r.Get("id")
+				r.Set("id", id.(int64)*2)
+				return r, nil
+			}), nil
+		})
+	})
+
+	cfg := loadTestConfig(t, `
+pipeline:
+  input:
+    type: test-source
+    config:
+      filePath: in.test
+  transformations:
+    - type: test-double
+  output:
+    type: test-sink
+`)
+	p, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := recordsJSON(t, testSink.records, false), `{"id":2,"path":"in.test"}
+{"id":4}`; got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	for _, types := range [][]string{SourceTypes(), SinkTypes(), TransformerTypes()} {
+		if !sort.StringsAreSorted(types) {
+			t.Errorf("types %v are not sorted", types)
+		}
+	}
+	if !HasSource("csv") || !HasSink("firebase") || !HasTransformer("filter") || HasSource("test-sink") {
+		t.Error("Has reports the wrong types")
+	}
+
+	// Unknown types and second registrations fail
+	if _, err := NewSource("missing", config.EndpointConfig{}); err == nil || err.Error() != "unsupported input type: missing" {
+		t.Errorf("unknown source: got %v", err)
+	}
+	if _, err := NewSink("missing", config.EndpointConfig{}); err == nil || err.Error() != "unsupported output type: missing" {
+		t.Errorf("unknown sink: got %v", err)
+	}
+	if _, err := NewTransformer("missing", transform.TransformationRules{}); err == nil || err.Error() != "unsupported transformation: missing" {
+		t.Errorf("unknown transformer: got %v", err)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a source twice: expected a panic")
+		}
+	}()
+	RegisterSource("csv", nil)
+}
+
+func TestBuiltinPipeline(t *testing.T) {
+	dir := t.TempDir()
+	in, out := filepath.Join(dir, "in.json"), filepath.Join(dir, "out.csv")
+	if err := os.WriteFile(in, []byte(`[{"Name":"ann","Age":31},{"Name":"bo","Age":25}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := loadTestConfig(t, `
+pipeline:
+  input:
+    type: json
+    config:
+      filePath: `+in+`
+  transformations:
+    filter:
+      - column: Age
+        condition: "> 30"
+    mapping:
+      dynamic_mapping: true
+  output:
+    type: csv
+    config:
+      filePath: `+out+`
+`)
+	p, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), "name,age\nann,31\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
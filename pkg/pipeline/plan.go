@@ -0,0 +1,130 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/avii09/hookit/pkg/config"
+	"github.com/avii09/hookit/pkg/transform"
+)
+
+// Plan is the resolved execution plan of a pipeline config. Building a plan
+// does not open files or connect to Firestore.
+type Plan struct {
+	Input      config.Endpoint
+	Stages     []string
+	Output     config.Endpoint
+	DeadLetter *config.Endpoint
+	Execution  config.Execution
+}
+
+// NewPlan resolves the input, transformation stages and output of the config.
+func NewPlan(cfg config.Config) (*Plan, error) {
+	if errs := Validate(cfg); len(errs) > 0 {
+		return nil, errs[0]
+	}
+
+	transformers, err := newTransformers(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{
+		Input:      cfg.Pipeline.Input,
+		Output:     cfg.Pipeline.Output,
+		DeadLetter: cfg.Pipeline.DeadLetter,
+		Execution:  cfg.Pipeline.Execution,
+	}
+	for _, transformer := range transformers {
+		plan.Stages = append(plan.Stages, describe(transformer))
+	}
+	return plan, nil
+}
+
+// String formats the plan as a numbered list of steps.
+func (p *Plan) String() string {
+	var b strings.Builder
+	step := 1
+	fmt.Fprintf(&b, "%d. read %s\n", step, describeEndpoint(p.Input))
+	for _, stage := range p.Stages {
+		step++
+		fmt.Fprintf(&b, "%d. %s\n", step, stage)
+	}
+	fmt.Fprintf(&b, "%d. write %s\n", step+1, describeEndpoint(p.Output))
+	if p.DeadLetter != nil {
+		fmt.Fprintf(&b, "records that fail a stage are written to %s\n", describeEndpoint(*p.DeadLetter))
+	}
+	if p.Execution.Parallelism > 1 {
+		order := "input order preserved"
+		if !p.Execution.PreserveOrderOrDefault() {
+			order = "unordered"
+		}
+		fmt.Fprintf(&b, "filters, mappings, set rules and flattening run on %d workers in batches of %d records, %s\n",
+			p.Execution.Parallelism, p.Execution.BatchSizeOrDefault(), order)
+	}
+	return b.String()
+}
+
+// Validate checks that the input, output and transformation step types of the
+// config are registered and returns every problem found. The settings and
+// transformation rules are checked by config.LoadConfig.
+func Validate(cfg config.Config) []error {
+	var errs []error
+	// Missing types are already reported by config.LoadConfig.
+	if inputType := cfg.Pipeline.Input.Type; inputType != "" && !HasSource(inputType) {
+		errs = append(errs, cfg.Errorf("pipeline.input.type", "unsupported input type %q (supported: %s)", inputType, strings.Join(SourceTypes(), ", ")))
+	}
+	if outputType := cfg.Pipeline.Output.Type; outputType != "" && !HasSink(outputType) {
+		errs = append(errs, cfg.Errorf("pipeline.output.type", "unsupported output type %q (supported: %s)", outputType, strings.Join(SinkTypes(), ", ")))
+	}
+	if deadLetter := cfg.Pipeline.DeadLetter; deadLetter != nil && deadLetter.Type != "" && !HasSink(deadLetter.Type) {
+		errs = append(errs, cfg.Errorf("pipeline.dead_letter.type", "unsupported dead_letter type %q (supported: %s)", deadLetter.Type, strings.Join(SinkTypes(), ", ")))
+	}
+	for i, step := range cfg.Pipeline.Transformations.Steps {
+		// Step types that transform does not support are reported by config.LoadConfig
+		if transform.ValidateStepType(step.Type) == nil && !HasTransformer(step.Type) {
+			errs = append(errs, cfg.Errorf(fmt.Sprintf("pipeline.transformations.%d.type", i), "unsupported transformation type %q (supported: %s)", step.Type, strings.Join(TransformerTypes(), ", ")))
+		}
+	}
+	return errs
+}
+
+// Helper function to describe a transformation stage
+func describe(transformer Transformer) string {
+	if s, ok := transformer.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf("%T", transformer)
+}
+
+// Helper function to describe an input or output endpoint
+func describeEndpoint(endpoint config.Endpoint) string {
+	if endpoint.Type == "firebase" || endpoint.Config.FilePath == "" {
+		return describeCollection(endpoint)
+	}
+	return fmt.Sprintf("%s file %q", endpoint.Type, endpoint.Config.FilePath)
+}
+
+// Helper function to describe a Firestore collection and the query on it
+func describeCollection(endpoint config.Endpoint) string {
+	opts := endpoint.Config.Firestore
+	kind := "collection"
+	if opts.CollectionGroup {
+		kind = "collection group"
+	}
+	s := fmt.Sprintf("%s %s %q", endpoint.Type, kind, endpoint.Config.Collection)
+	var conditions []string
+	for _, filter := range opts.Where {
+		conditions = append(conditions, fmt.Sprintf("%s %s %v", filter.Field, filter.Op, filter.Value))
+	}
+	if len(conditions) > 0 {
+		s += " where " + strings.Join(conditions, " and ")
+	}
+	if len(opts.OrderBy) > 0 {
+		s += " ordered by " + strings.Join(opts.OrderBy, ", ")
+	}
+	if opts.Limit > 0 {
+		s += fmt.Sprintf(" limited to %d documents", opts.Limit)
+	}
+	return s
+}
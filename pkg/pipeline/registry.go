@@ -0,0 +1,150 @@
+package pipeline
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/avii09/hookit/pkg/config"
+	"github.com/avii09/hookit/pkg/transform"
+)
+
+// SourceFactory creates a source from the input configuration.
+type SourceFactory func(cfg config.EndpointConfig) (Source, error)
+
+// SinkFactory creates a sink from the output configuration.
+type SinkFactory func(cfg config.EndpointConfig) (Sink, error)
+
+// TransformerFactory creates a transformer from the transformation rules. It
+// returns a nil Transformer when the rules do not configure that stage.
+type TransformerFactory func(rules transform.TransformationRules) (Transformer, error)
+
+var (
+	registryMu   sync.RWMutex
+	sources      = make(map[string]SourceFactory)
+	sinks        = make(map[string]SinkFactory)
+	transformers = make(map[string]TransformerFactory)
+)
+
+// RegisterSource makes a source available under the given input type.
+// It panics if the type is registered twice.
+func RegisterSource(name string, factory SourceFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, dup := sources[name]; dup {
+		panic("pipeline: RegisterSource called twice for type " + name)
+	}
+	sources[name] = factory
+}
+
+// RegisterSink makes a sink available under the given output type.
+// It panics if the type is registered twice.
+func RegisterSink(name string, factory SinkFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, dup := sinks[name]; dup {
+		panic("pipeline: RegisterSink called twice for type " + name)
+	}
+	sinks[name] = factory
+}
+
+// RegisterTransformer makes a transformation stage available under the given name.
+// It panics if the name is registered twice.
+func RegisterTransformer(name string, factory TransformerFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, dup := transformers[name]; dup {
+		panic("pipeline: RegisterTransformer called twice for stage " + name)
+	}
+	transformers[name] = factory
+	// Let configs name the stage as a transformation step type
+	transform.RegisterStepType(name)
+}
+
+// NewSource creates the source registered for the input type.
+func NewSource(name string, cfg config.EndpointConfig) (Source, error) {
+	registryMu.RLock()
+	factory, ok := sources[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unsupported input type: %v", name)
+	}
+	return factory(cfg)
+}
+
+// NewSink creates the sink registered for the output type.
+func NewSink(name string, cfg config.EndpointConfig) (Sink, error) {
+	registryMu.RLock()
+	factory, ok := sinks[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unsupported output type: %v", name)
+	}
+	return factory(cfg)
+}
+
+// NewTransformer creates the transformation stage registered under the name.
+func NewTransformer(name string, rules transform.TransformationRules) (Transformer, error) {
+	registryMu.RLock()
+	factory, ok := transformers[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unsupported transformation: %v", name)
+	}
+	return factory(rules)
+}
+
+// HasSource reports whether a source is registered for the input type.
+func HasSource(name string) bool {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	_, ok := sources[name]
+	return ok
+}
+
+// HasSink reports whether a sink is registered for the output type.
+func HasSink(name string) bool {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	_, ok := sinks[name]
+	return ok
+}
+
+// HasTransformer reports whether a transformation stage is registered under the name.
+func HasTransformer(name string) bool {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	_, ok := transformers[name]
+	return ok
+}
+
+// SourceTypes returns the registered input types in sorted order.
+func SourceTypes() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return sortedKeys(sources)
+}
+
+// SinkTypes returns the registered output types in sorted order.
+func SinkTypes() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return sortedKeys(sinks)
+}
+
+// TransformerTypes returns the registered transformation stages in sorted order.
+func TransformerTypes() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return sortedKeys(transformers)
+}
+
+// Helper function to return the sorted keys of a registry map
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
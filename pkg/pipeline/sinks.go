@@ -0,0 +1,345 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/avii09/hookit/pkg/charset"
+	"github.com/avii09/hookit/pkg/config"
+	"github.com/avii09/hookit/pkg/output"
+	"github.com/avii09/hookit/pkg/record"
+	"github.com/avii09/hookit/pkg/store"
+	"github.com/avii09/hookit/pkg/transform"
+)
+
+func init() {
+	RegisterSink("csv", newCSVSink)
+	RegisterSink("json", newJSONSink)
+	RegisterSink("jsonl", newJSONLSink)
+	RegisterSink("firebase", newFirebaseSink)
+}
+
+// csvSink writes records to a CSV file. The columns are the configured ones
+// or, by default, the fields of every record in the order they first appear,
+// so that columns only some records have are kept.
+type csvSink struct {
+	filePath string
+	options  output.CSVOptions
+	columns  []string
+	missing  string
+}
+
+func newCSVSink(cfg config.EndpointConfig) (Sink, error) {
+	options, err := csvOutputOptions(cfg.CSV)
+	if err != nil {
+		return nil, err
+	}
+	return &csvSink{
+		filePath: cfg.FilePath,
+		options:  options,
+		columns:  cfg.CSV.Columns,
+		missing:  cfg.CSV.MissingValue,
+	}, nil
+}
+
+// Helper function to convert the configured CSV dialect into writer options
+func csvOutputOptions(cfg config.CSVOptions) (output.CSVOptions, error) {
+	delimiter, err := cfg.DelimiterRune()
+	if err != nil {
+		return output.CSVOptions{}, err
+	}
+	opts := output.CSVOptions{
+		Delimiter: delimiter,
+		NoHeader:  !cfg.HasHeader(),
+		// An encoding such as "utf-16" writes its own byte order mark
+		BOM: cfg.BOM && !charset.WritesBOM(cfg.Encoding),
+	}
+	if cfg.Encoding != "" {
+		if opts.Encoding, err = charset.Lookup(cfg.Encoding); err != nil {
+			return output.CSVOptions{}, err
+		}
+	}
+	return opts, nil
+}
+
+func (s *csvSink) Write(ctx context.Context, in Iterator) error {
+	if len(s.columns) > 0 {
+		return s.writeColumns(ctx, in)
+	}
+
+	table, err := output.CreateCSVTable(s.filePath, s.options, s.missing)
+	if err != nil {
+		return err
+	}
+	err = forEachRecord(ctx, in, func(r *record.Record) error {
+		keys := r.Keys()
+		values := make([]string, len(keys))
+		for i, key := range keys {
+			value, _ := r.Get(key)
+			values[i] = record.Format(value)
+		}
+		return table.Write(keys, values)
+	})
+	if err != nil {
+		// Keep the previous output rather than replace it with part of the table
+		table.Discard()
+		return err
+	}
+	return table.Close()
+}
+
+// Helper function to write the configured columns, streaming each record to
+// a temporary file that replaces the output once every record is written
+func (s *csvSink) writeColumns(ctx context.Context, in Iterator) error {
+	writer, err := output.CreateCSVReplacing(s.filePath, s.options)
+	if err != nil {
+		return err
+	}
+	err = writer.WriteHeader(s.columns)
+	if err == nil {
+		err = forEachRecord(ctx, in, func(r *record.Record) error {
+			row := make([]string, len(s.columns))
+			for i, column := range s.columns {
+				if value, ok := r.Lookup(column); ok {
+					row[i] = record.Format(value)
+				} else {
+					row[i] = s.missing
+				}
+			}
+			return writer.Write(row)
+		})
+	}
+	if err != nil {
+		// Keep the previous output rather than replace it with part of the rows
+		writer.Discard()
+		return err
+	}
+	return writer.Close()
+}
+
+// Helper function to call fn for each record of a stream
+func forEachRecord(ctx context.Context, in Iterator, fn func(r *record.Record) error) error {
+	for {
+		r, err := in.Next(ctx)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+}
+
+// jsonSink writes records to a JSON array file, keeping their field order and
+// value types.
+type jsonSink struct {
+	filePath string
+}
+
+func newJSONSink(cfg config.EndpointConfig) (Sink, error) {
+	return &jsonSink{filePath: cfg.FilePath}, nil
+}
+
+func (s *jsonSink) Write(ctx context.Context, in Iterator) (err error) {
+	writer, err := output.CreateJSON(s.filePath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := writer.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	for {
+		r, err := in.Next(ctx)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := writer.Write(r); err != nil {
+			if !isRecordError(err) {
+				return err
+			}
+			if err := failRecord(ctx, "output", r, err); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// jsonlSink writes records to a JSON Lines file, one compact object per line.
+type jsonlSink struct {
+	filePath string
+	append   bool
+}
+
+func newJSONLSink(cfg config.EndpointConfig) (Sink, error) {
+	return &jsonlSink{filePath: cfg.FilePath, append: cfg.Append}, nil
+}
+
+func (s *jsonlSink) Write(ctx context.Context, in Iterator) (err error) {
+	writer, err := output.CreateJSONL(s.filePath, s.append)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := writer.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	for {
+		r, err := in.Next(ctx)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := writer.Write(r); err != nil {
+			if !isRecordError(err) {
+				return err
+			}
+			if err := failRecord(ctx, "output", r, err); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// firebaseSink writes records as documents of a Firestore collection, with a
+// BulkWriter or in atomic batches. Records that cannot be written go to the
+// dead letter sink, if there is one.
+type firebaseSink struct {
+	store      store.DocumentStore
+	collection string
+	options    output.FirestoreWriteOptions
+	batchSize  int
+	idField    string
+	idTemplate *transform.Template
+}
+
+// defaultFirestoreChunk is the number of documents handed to the BulkWriter
+// before waiting for their results.
+const defaultFirestoreChunk = 500
+
+func newFirebaseSink(cfg config.EndpointConfig) (Sink, error) {
+	s, err := newDocumentStore(context.Background(), cfg)
+	if err != nil {
+		return nil, err
+	}
+	sink, err := newStoreSink(s, cfg)
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	return sink, nil
+}
+
+// Helper function to return a sink that writes to a document store
+func newStoreSink(st store.DocumentStore, cfg config.EndpointConfig) (*firebaseSink, error) {
+	opts := cfg.Firestore
+	s := &firebaseSink{
+		store:      st,
+		collection: cfg.Collection,
+		options: output.FirestoreWriteOptions{
+			Mode:      opts.WriteMode,
+			Atomic:    opts.BatchSize > 0,
+			RateLimit: opts.RateLimit,
+		},
+		batchSize: opts.BatchSize,
+		idField:   opts.IDField,
+	}
+	if s.batchSize == 0 {
+		s.batchSize = defaultFirestoreChunk
+	}
+	if opts.IDTemplate != "" {
+		template, err := transform.CompileTemplate(opts.IDTemplate)
+		if err != nil {
+			return nil, err
+		}
+		s.idTemplate = template
+	}
+	return s, nil
+}
+
+func (s *firebaseSink) Write(ctx context.Context, in Iterator) error {
+	writer := output.NewFirestoreWriter(s.store, s.collection, s.options)
+
+	var records []*record.Record
+	var docs []output.FirestoreDocument
+	flush := func() error {
+		errs := writer.WriteAll(ctx, docs)
+		for i, err := range errs {
+			if err == nil {
+				continue
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err := failRecord(ctx, "output", records[i], err); err != nil {
+				return err
+			}
+		}
+		records, docs = records[:0], docs[:0]
+		return nil
+	}
+
+	for {
+		r, err := in.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		id, err := s.documentID(r)
+		if err != nil {
+			if err := failRecord(ctx, "output", r, err); err != nil {
+				return err
+			}
+			continue
+		}
+		records = append(records, r)
+		docs = append(docs, output.FirestoreDocument{ID: id, Data: r.ToMap()})
+		if len(docs) == s.batchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if len(docs) > 0 {
+		return flush()
+	}
+	return nil
+}
+
+// Helper function to return the document ID of a record, or "" to generate one
+func (s *firebaseSink) documentID(r *record.Record) (string, error) {
+	switch {
+	case s.idTemplate != nil:
+		id, err := s.idTemplate.Expand(r)
+		if err == nil && id == "" {
+			err = fmt.Errorf("document ID template %q gives an empty ID", s.idTemplate)
+		}
+		return id, err
+	case s.idField != "":
+		value, ok := r.Lookup(s.idField)
+		if !ok || value == nil || record.Format(value) == "" {
+			return "", fmt.Errorf("document ID field %q is missing or empty", s.idField)
+		}
+		return record.Format(value), nil
+	}
+	return "", nil
+}
+
+func (s *firebaseSink) Close() error {
+	return s.store.Close()
+}
@@ -0,0 +1,167 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/avii09/hookit/pkg/config"
+	"github.com/avii09/hookit/pkg/record"
+)
+
+// Helper function to write records through a CSV sink and return the file
+func writeCSVSink(t *testing.T, cfg config.EndpointConfig, in Iterator) (string, error) {
+	t.Helper()
+	if cfg.FilePath == "" {
+		cfg.FilePath = filepath.Join(t.TempDir(), "out.csv")
+	}
+	sink, err := newCSVSink(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sink.Write(context.Background(), in)
+	b, _ := os.ReadFile(cfg.FilePath)
+	return string(b), err
+}
+
+func TestCSVSinkColumns(t *testing.T) {
+	records := `{"id":1,"name":"ann"}
+{"id":2,"address":{"city":"Pune"}}
+{"name":"bo","id":3,"tags":["a","b"]}`
+	tests := []struct {
+		name string
+		csv  config.CSVOptions
+		want string
+	}{
+		{
+			"union of the fields of every record",
+			config.CSVOptions{},
+			"id,name,address,tags\n1,ann,,\n2,,\"{\"\"city\"\":\"\"Pune\"\"}\",\n3,bo,,\"[\"\"a\"\",\"\"b\"\"]\"\n",
+		},
+		{
+			"missing value",
+			config.CSVOptions{MissingValue: "NA"},
+			"id,name,address,tags\n1,ann,NA,NA\n2,NA,\"{\"\"city\"\":\"\"Pune\"\"}\",NA\n3,bo,NA,\"[\"\"a\"\",\"\"b\"\"]\"\n",
+		},
+		{
+			"fixed columns with nested paths",
+			config.CSVOptions{Columns: []string{"id", "address.city", "tags[1]"}, MissingValue: "-"},
+			"id,address.city,tags[1]\n1,-,-\n2,Pune,-\n3,-,b\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := writeCSVSink(t, config.EndpointConfig{CSV: test.csv}, SliceIterator(testRecords(t, records)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("got\n%s\nwant\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCSVSinkKeepsOutputOnError(t *testing.T) {
+	for _, columns := range [][]string{nil, {"id"}} {
+		t.Run(fmt.Sprintf("columns %v", columns), func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "out.csv")
+			if err := os.WriteFile(path, []byte("id\n1\n2\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			// The stream fails after one record
+			records := testRecords(t, `{"id":9}`)
+			failure := errors.New("source failed")
+			in := IteratorFunc(func(ctx context.Context) (*record.Record, error) {
+				if len(records) == 0 {
+					return nil, failure
+				}
+				r := records[0]
+				records = records[1:]
+				return r, nil
+			})
+			cfg := config.EndpointConfig{FilePath: path, CSV: config.CSVOptions{Columns: columns}}
+			got, err := writeCSVSink(t, cfg, in)
+			if !errors.Is(err, failure) {
+				t.Fatalf("got error %v, want %v", err, failure)
+			}
+			if got != "id\n1\n2\n" {
+				t.Errorf("the output was replaced: %q", got)
+			}
+
+			// The rows written so far are removed
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 {
+				t.Errorf("got %d files in the output directory, want 1", len(entries))
+			}
+		})
+	}
+}
+
+func TestCSVSinkReplacesOutput(t *testing.T) {
+	for _, columns := range [][]string{nil, {"id"}} {
+		t.Run(fmt.Sprintf("columns %v", columns), func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "out.csv")
+			if err := os.WriteFile(path, []byte("id\n1\n2\n"), 0640); err != nil {
+				t.Fatal(err)
+			}
+			cfg := config.EndpointConfig{FilePath: path, CSV: config.CSVOptions{Columns: columns}}
+			got, err := writeCSVSink(t, cfg, SliceIterator(testRecords(t, `{"id":9}`)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != "id\n9\n" {
+				t.Errorf("got %q, want %q", got, "id\n9\n")
+			}
+
+			// The new output keeps the mode of the file it replaced
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if mode := info.Mode().Perm(); mode != 0640 {
+				t.Errorf("got mode %v, want %v", mode, os.FileMode(0640))
+			}
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 {
+				t.Errorf("got %d files in the output directory, want 1", len(entries))
+			}
+		})
+	}
+}
+
+func TestJSONLSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.jsonl")
+	for _, appendToFile := range []bool{false, true} {
+		sink, err := newJSONLSink(config.EndpointConfig{FilePath: path, Append: appendToFile})
+		if err != nil {
+			t.Fatal(err)
+		}
+		records := testRecords(t, `{"id":1,"at":{"x":1.0}}`, `{"id":2,"tags":["a"]}`)
+		if err := sink.Write(context.Background(), SliceIterator(records)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The second run adds its lines after those of the first
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := "{\"id\":1,\"at\":{\"x\":1.0}}\n{\"id\":2,\"tags\":[\"a\"]}\n"
+	if got := string(b); got != line+line {
+		t.Errorf("got\n%s\nwant\n%s", got, line+line)
+	}
+}
@@ -0,0 +1,531 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/avii09/hookit/pkg/charset"
+	"github.com/avii09/hookit/pkg/config"
+	"github.com/avii09/hookit/pkg/input"
+	"github.com/avii09/hookit/pkg/output"
+	"github.com/avii09/hookit/pkg/record"
+	"github.com/avii09/hookit/pkg/store"
+)
+
+func init() {
+	RegisterSource("csv", newCSVSource)
+	RegisterSource("json", newJSONSource)
+	RegisterSource("jsonl", newJSONLSource)
+	RegisterSource("firebase", newFirebaseSource)
+}
+
+// csvSource reads records from a CSV file, keeping the header column order.
+// Rows that cannot be parsed stop the pipeline, or are skipped or written to
+// a rejects file, as set by the on_error policy.
+type csvSource struct {
+	filePath    string
+	options     input.CSVOptions
+	onError     string
+	rejectsFile string
+}
+
+func newCSVSource(cfg config.EndpointConfig) (Source, error) {
+	options, err := csvInputOptions(cfg.CSV)
+	if err != nil {
+		return nil, err
+	}
+	return &csvSource{
+		filePath:    cfg.FilePath,
+		options:     options,
+		onError:     cfg.CSV.OnErrorOrDefault(),
+		rejectsFile: cfg.CSV.RejectsFile,
+	}, nil
+}
+
+// Helper function to convert the configured CSV dialect into reader options
+func csvInputOptions(cfg config.CSVOptions) (input.CSVOptions, error) {
+	delimiter, err := cfg.DelimiterRune()
+	if err != nil {
+		return input.CSVOptions{}, err
+	}
+	comment, err := cfg.CommentRune()
+	if err != nil {
+		return input.CSVOptions{}, err
+	}
+	opts := input.CSVOptions{
+		Delimiter:  delimiter,
+		Comment:    comment,
+		NoHeader:   !cfg.HasHeader(),
+		Columns:    cfg.Columns,
+		LazyQuotes: cfg.LazyQuotes,
+		TrimSpace:  cfg.TrimSpace,
+	}
+	if cfg.Encoding != "" {
+		if opts.Encoding, err = charset.Lookup(cfg.Encoding); err != nil {
+			return input.CSVOptions{}, err
+		}
+	}
+	return opts, nil
+}
+
+func (s *csvSource) Read(ctx context.Context) (Iterator, error) {
+	reader, err := input.OpenCSVWithOptions(s.filePath, s.options)
+	if err != nil {
+		return nil, err
+	}
+	it := &csvIterator{reader: reader, filePath: s.filePath, onError: s.onError}
+	if s.onError == config.OnErrorReject {
+		// Rejected rows keep the input's dialect, after their line number and error
+		it.rejects, err = output.CreateCSVWithOptions(s.rejectsFile, output.CSVOptions{
+			Delimiter: s.options.Delimiter,
+			Encoding:  s.options.Encoding,
+		})
+		if err != nil {
+			reader.Close()
+			return nil, fmt.Errorf("error creating rejects file: %w", err)
+		}
+		it.rejectsFile = s.rejectsFile
+		header := append([]string{"line", "error"}, reader.Headers()...)
+		if err := it.rejects.WriteHeader(header); err != nil {
+			it.Close()
+			return nil, fmt.Errorf("error writing rejects file: %w", err)
+		}
+	}
+	return it, nil
+}
+
+// csvIterator turns the rows of a CSV file into records, applying the
+// on_error policy to rows that cannot be parsed.
+type csvIterator struct {
+	reader   *input.CSVReader
+	filePath string
+	onError  string
+
+	rejects     *output.CSVWriter
+	rejectsFile string
+
+	rows int // rows read, good or bad
+	bad  int // rows skipped or rejected
+}
+
+func (it *csvIterator) Next(ctx context.Context) (*record.Record, error) {
+	for {
+		row, err := it.reader.Read()
+		var rowErr *input.RowError
+		if errors.As(err, &rowErr) {
+			it.rows++
+			if err := it.badRow(rowErr); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		it.rows++
+
+		r := record.New()
+		for i, value := range row {
+			r.Set(it.reader.Column(i), value)
+		}
+		return r, nil
+	}
+}
+
+// Helper function to apply the on_error policy to a row that cannot be parsed
+func (it *csvIterator) badRow(rowErr *input.RowError) error {
+	switch it.onError {
+	case config.OnErrorSkip:
+		log.Printf("skipping %s %v", it.filePath, rowErr)
+	case config.OnErrorReject:
+		reject := append([]string{strconv.Itoa(rowErr.Line), rowErr.Err.Error()}, rowErr.Fields...)
+		if err := it.rejects.Write(reject); err != nil {
+			return fmt.Errorf("error writing rejects file: %w", err)
+		}
+	default:
+		return fmt.Errorf("%s %w", it.filePath, rowErr)
+	}
+	it.bad++
+	return nil
+}
+
+// Close closes the file and the rejects file, and logs how many rows were
+// skipped or rejected.
+func (it *csvIterator) Close() error {
+	err := it.reader.Close()
+	if it.rejects != nil {
+		if closeErr := it.rejects.Close(); err == nil && closeErr != nil {
+			err = fmt.Errorf("error writing rejects file: %w", closeErr)
+		}
+	}
+	switch it.onError {
+	case config.OnErrorSkip:
+		log.Printf("read %d rows from %s, skipped %d bad rows", it.rows, it.filePath, it.bad)
+	case config.OnErrorReject:
+		log.Printf("read %d rows from %s, rejected %d bad rows to %s", it.rows, it.filePath, it.bad, it.rejectsFile)
+	}
+	return err
+}
+
+// jsonSource reads records from a JSON array file, keeping field order and
+// the types of their values.
+type jsonSource struct {
+	filePath string
+}
+
+func newJSONSource(cfg config.EndpointConfig) (Source, error) {
+	return &jsonSource{filePath: cfg.FilePath}, nil
+}
+
+func (s *jsonSource) Read(ctx context.Context) (Iterator, error) {
+	reader, err := input.OpenJSON(s.filePath)
+	if err != nil {
+		return nil, err
+	}
+	return &jsonIterator{reader: reader}, nil
+}
+
+// jsonIterator reads the records of a JSON file.
+type jsonIterator struct {
+	reader *input.JSONReader
+}
+
+func (it *jsonIterator) Next(ctx context.Context) (*record.Record, error) {
+	return it.reader.Read()
+}
+
+func (it *jsonIterator) Close() error {
+	return it.reader.Close()
+}
+
+// jsonlSource reads records from a JSON Lines file, one object per line.
+type jsonlSource struct {
+	filePath string
+}
+
+func newJSONLSource(cfg config.EndpointConfig) (Source, error) {
+	return &jsonlSource{filePath: cfg.FilePath}, nil
+}
+
+func (s *jsonlSource) Read(ctx context.Context) (Iterator, error) {
+	reader, err := input.OpenJSONL(s.filePath)
+	if err != nil {
+		return nil, err
+	}
+	return &jsonlIterator{reader: reader}, nil
+}
+
+// jsonlIterator reads the records of a JSON Lines file.
+type jsonlIterator struct {
+	reader *input.JSONLReader
+}
+
+func (it *jsonlIterator) Next(ctx context.Context) (*record.Record, error) {
+	return it.reader.Read()
+}
+
+func (it *jsonlIterator) Close() error {
+	return it.reader.Close()
+}
+
+// firebaseSource reads the documents of a Firestore collection, or collection
+// group, that match the configured query. It can also read the named
+// subcollections of each document and replace reference fields with the
+// documents they point to.
+type firebaseSource struct {
+	store      store.DocumentStore
+	collection string
+	query      input.FirebaseQuery
+	options    config.FirestoreOptions
+}
+
+func newFirebaseSource(cfg config.EndpointConfig) (Source, error) {
+	s, err := newDocumentStore(context.Background(), cfg)
+	if err != nil {
+		return nil, err
+	}
+	source, err := newStoreSource(s, cfg)
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	return source, nil
+}
+
+// Helper function to return a source that reads from a document store
+func newStoreSource(s store.DocumentStore, cfg config.EndpointConfig) (*firebaseSource, error) {
+	query, err := firebaseQuery(cfg.Firestore)
+	if err != nil {
+		return nil, err
+	}
+	return &firebaseSource{
+		store:      s,
+		collection: cfg.Collection,
+		query:      query,
+		options:    cfg.Firestore,
+	}, nil
+}
+
+// Helper function to convert the configured query settings into a query
+func firebaseQuery(cfg config.FirestoreOptions) (input.FirebaseQuery, error) {
+	q := input.FirebaseQuery{
+		CollectionGroup: cfg.CollectionGroup,
+		Limit:           cfg.Limit,
+		Select:          cfg.Select,
+		PageSize:        cfg.PageSize,
+	}
+	for _, filter := range cfg.Where {
+		q.Where = append(q.Where, input.FirebaseFilter{Field: filter.Field, Op: filter.Op, Value: filter.Value})
+	}
+	for _, s := range cfg.OrderBy {
+		order, err := input.ParseFirebaseOrder(s)
+		if err != nil {
+			return input.FirebaseQuery{}, err
+		}
+		q.OrderBy = append(q.OrderBy, order)
+	}
+	return q, nil
+}
+
+func (s *firebaseSource) Read(ctx context.Context) (Iterator, error) {
+	return &firebaseIterator{
+		reader:     input.OpenFirebaseQuery(ctx, s.store, s.collection, s.query),
+		store:      s.store,
+		options:    s.options,
+		references: make(map[string]*record.Record),
+	}, nil
+}
+
+// firebaseIterator turns the documents of a Firestore query into records,
+// adding the document ID and path as fields when configured.
+type firebaseIterator struct {
+	reader  *input.FirebaseReader
+	store   store.DocumentStore
+	options config.FirestoreOptions
+
+	pending    []*record.Record          // flattened subcollection documents still to return
+	references map[string]*record.Record // documents already read to resolve references
+}
+
+// maxCachedReferences bounds the referenced documents kept for reuse.
+const maxCachedReferences = 1000
+
+// parentID is the ID of a document above a subcollection document, given to
+// the records of flattened subcollections as a field such as "orders_id".
+type parentID struct {
+	field string
+	id    string
+}
+
+func (it *firebaseIterator) Next(ctx context.Context) (*record.Record, error) {
+	if len(it.pending) > 0 {
+		r := it.pending[0]
+		it.pending[0] = nil
+		it.pending = it.pending[1:]
+		return r, nil
+	}
+	doc, err := it.reader.ReadDocument()
+	if err != nil {
+		return nil, err
+	}
+	r, descendants, err := it.load(ctx, doc, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	it.pending = descendants
+	return r, nil
+}
+
+// Helper function to turn a document into a record, along with the records of
+// its flattened subcollections, in depth-first order. at is the path of
+// subcollection names the document was read from, such as "items", or "" for
+// a document of the queried collection.
+func (it *firebaseIterator) load(ctx context.Context, doc *store.Document, parents []parentID, at string) (*record.Record, []*record.Record, error) {
+	r, err := it.toRecord(ctx, doc, parents)
+	if err != nil {
+		return nil, nil, err
+	}
+	names := subcollectionsUnder(it.options.Subcollections, at)
+	if len(names) == 0 {
+		return r, nil, nil
+	}
+
+	flatten := it.options.SubcollectionModeOrDefault() == config.SubcollectionsFlatten
+	// Nested documents sit inside their parents and need no parent IDs
+	var childParents []parentID
+	if flatten {
+		childParents = append(parents[:len(parents):len(parents)], parentID{field: doc.CollectionID() + "_id", id: doc.ID()})
+	}
+	var descendants []*record.Record
+	for _, name := range names {
+		children := []interface{}{}
+		err := it.readSubcollection(ctx, doc.Path, name, func(child *store.Document) error {
+			c, more, err := it.load(ctx, child, childParents, path.Join(at, name))
+			if err != nil {
+				return err
+			}
+			if flatten {
+				descendants = append(append(descendants, c), more...)
+			} else {
+				children = append(children, c)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		if !flatten {
+			r.Set(name, children)
+		}
+	}
+	return r, descendants, nil
+}
+
+// Helper function to list the names of the configured subcollections directly
+// under the subcollection path at, so that "items/reviews" is read under the
+// documents of "items" only
+func subcollectionsUnder(configured []string, at string) []string {
+	var names []string
+	for _, sub := range configured {
+		rest := sub
+		if at != "" {
+			if !strings.HasPrefix(sub, at+"/") {
+				continue
+			}
+			rest = sub[len(at)+1:]
+		}
+		if !strings.Contains(rest, "/") {
+			names = append(names, rest)
+		}
+	}
+	return names
+}
+
+// Helper function to call fn for each document of a subcollection
+func (it *firebaseIterator) readSubcollection(ctx context.Context, parent, name string, fn func(doc *store.Document) error) error {
+	reader := input.OpenFirebase(ctx, it.store, parent+"/"+name)
+	defer reader.Close()
+	for {
+		doc, err := reader.ReadDocument()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error reading subcollection %s of %s: %w", name, parent, err)
+		}
+		if err := fn(doc); err != nil {
+			return err
+		}
+	}
+}
+
+// Helper function to convert a document to a record, with its references resolved
+func (it *firebaseIterator) toRecord(ctx context.Context, doc *store.Document, parents []parentID) (*record.Record, error) {
+	data := fromDocument(doc.Data)
+	if err := it.resolveReferences(ctx, data); err != nil {
+		return nil, err
+	}
+	return it.withMetadata(doc, parents, data), nil
+}
+
+// Helper function to put the IDs of a document's parents and its own ID and
+// path before the fields of its data
+func (it *firebaseIterator) withMetadata(doc *store.Document, parents []parentID, data *record.Record) *record.Record {
+	if len(parents) == 0 && it.options.IDField == "" && it.options.PathField == "" {
+		return data
+	}
+
+	// The metadata fields come first and win over document fields of the same name
+	r := record.New()
+	for _, parent := range parents {
+		r.Set(parent.field, parent.id)
+	}
+	if it.options.IDField != "" {
+		r.Set(it.options.IDField, doc.ID())
+	}
+	if it.options.PathField != "" {
+		r.Set(it.options.PathField, doc.Path)
+	}
+	for _, key := range data.Keys() {
+		if !r.Has(key) {
+			value, _ := data.Get(key)
+			r.Set(key, value)
+		}
+	}
+	return r
+}
+
+// Helper function to replace the configured reference fields, or arrays of
+// references, with the documents they point to. A reference to a document
+// that does not exist becomes null.
+func (it *firebaseIterator) resolveReferences(ctx context.Context, r *record.Record) error {
+	for _, field := range it.options.ResolveReferences {
+		value, ok := r.Lookup(field)
+		if !ok {
+			continue
+		}
+		switch v := value.(type) {
+		case record.Reference:
+			doc, err := it.referencedDocument(ctx, v)
+			if err != nil {
+				return err
+			}
+			if err := r.SetPath(field, doc); err != nil {
+				return err
+			}
+		case []interface{}:
+			for i, item := range v {
+				if ref, ok := item.(record.Reference); ok {
+					doc, err := it.referencedDocument(ctx, ref)
+					if err != nil {
+						return err
+					}
+					v[i] = doc
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Helper function to read the document a reference points to, as a record
+// with its ID and path when configured. The references of the document are
+// left as they are, so that documents referring to each other do not loop.
+func (it *firebaseIterator) referencedDocument(ctx context.Context, ref record.Reference) (interface{}, error) {
+	if cached, ok := it.references[ref.Path]; ok {
+		if cached == nil {
+			return nil, nil
+		}
+		return record.CopyValue(cached), nil
+	}
+	doc, err := it.store.Get(ctx, ref.Path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading Firestore document %s: %w", ref.Path, err)
+	}
+	var r *record.Record
+	if doc != nil {
+		r = it.withMetadata(doc, nil, fromDocument(doc.Data))
+	}
+	if len(it.references) >= maxCachedReferences {
+		it.references = make(map[string]*record.Record)
+	}
+	it.references[ref.Path] = r
+	if r == nil {
+		return nil, nil
+	}
+	return record.CopyValue(r), nil
+}
+
+func (it *firebaseIterator) Close() error {
+	return it.reader.Close()
+}
+
+func (s *firebaseSource) Close() error {
+	return s.store.Close()
+}
@@ -0,0 +1,155 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/avii09/hookit/pkg/config"
+)
+
+// Helper function to read every record of a source
+func readSource(t *testing.T, source Source) (string, error) {
+	t.Helper()
+	it, err := source.Read(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if closer, ok := it.(interface{ Close() error }); ok {
+		defer closer.Close()
+	}
+	records, err := Collect(context.Background(), it)
+	return recordsJSON(t, records, false), err
+}
+
+func TestCSVSourceDialect(t *testing.T) {
+	noHeader := false
+	path := filepath.Join(t.TempDir(), "export.txt")
+	// Latin-1, tab-separated, without a header row
+	content := "# exported\n1\tZ\xfcrich\t8000\n2\tBesan\xe7on\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	source, err := newCSVSource(config.EndpointConfig{FilePath: path, CSV: config.CSVOptions{
+		Delimiter: "tab",
+		Header:    &noHeader,
+		Columns:   []string{"id", "city"},
+		Comment:   "#",
+		Encoding:  "latin1",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := readSource(t, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"id":"1","city":"Zürich","column3":"8000"}
+{"id":"2","city":"Besançon"}`
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCSVDialectRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.csv")
+	options := config.CSVOptions{Delimiter: "semicolon", Encoding: "utf-16", BOM: true}
+	sink, err := newCSVSink(config.EndpointConfig{FilePath: path, CSV: options})
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := `{"id":"1","city":"Zürich"}
+{"id":"2","city":"a;b"}`
+	if err := sink.Write(context.Background(), SliceIterator(testRecords(t, records))); err != nil {
+		t.Fatal(err)
+	}
+
+	// "utf-16" writes its own byte order mark, so bom adds no second one
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(b, []byte("\xff\xfei\x00")) {
+		t.Errorf("file starts with % x, want one little endian byte order mark", b[:min(len(b), 8)])
+	}
+
+	options.BOM = false
+	source, err := newCSVSource(config.EndpointConfig{FilePath: path, CSV: options})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := readSource(t, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != records {
+		t.Errorf("got\n%s\nwant\n%s", got, records)
+	}
+}
+
+func TestCSVSourceOnError(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "in.csv")
+	content := "id;name\n1;ann\n2;bo;extra\n3;c\"y\n4;di\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rejects := filepath.Join(dir, "rejects.csv")
+	good := `{"id":"1","name":"ann"}
+{"id":"4","name":"di"}`
+
+	tests := []struct {
+		onError string
+		want    string
+		err     string
+		log     string
+	}{
+		{config.OnErrorFail, "", path + " line 3: expected 2 fields, got 3", ""},
+		{config.OnErrorSkip, good, "", "read 4 rows from " + path + ", skipped 2 bad rows"},
+		{config.OnErrorReject, good, "", "read 4 rows from " + path + ", rejected 2 bad rows to " + rejects},
+	}
+	for _, test := range tests {
+		t.Run(test.onError, func(t *testing.T) {
+			var logged bytes.Buffer
+			log.SetOutput(&logged)
+			defer log.SetOutput(os.Stderr)
+
+			options := config.CSVOptions{Delimiter: ";", OnError: test.onError}
+			if test.onError == config.OnErrorReject {
+				options.RejectsFile = rejects
+			}
+			source, err := newCSVSource(config.EndpointConfig{FilePath: path, CSV: options})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := readSource(t, source)
+			if test.err == "" && err != nil {
+				t.Fatal(err)
+			}
+			if test.err != "" && (err == nil || err.Error() != test.err) {
+				t.Errorf("got error %v, want %s", err, test.err)
+			}
+			if got != test.want {
+				t.Errorf("got\n%s\nwant\n%s", got, test.want)
+			}
+			if !strings.Contains(logged.String(), test.log) {
+				t.Errorf("log %q does not contain %q", logged.String(), test.log)
+			}
+		})
+	}
+
+	// Rejected rows keep the input's dialect, after their line and error, with
+	// the fields read before the error
+	b, err := os.ReadFile(rejects)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "line;error;id;name\n3;expected 2 fields, got 3;2;bo;extra\n4;\"bare \"\" in non-quoted-field\";3\n"
+	if string(b) != want {
+		t.Errorf("rejects file:\n%s\nwant\n%s", b, want)
+	}
+}
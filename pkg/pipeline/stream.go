@@ -0,0 +1,128 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/avii09/hookit/pkg/record"
+)
+
+// Iterator yields the records of a stream one at a time. Next returns io.EOF
+// after the last record; any other error ends the stream.
+//
+// Records flow through a pipeline as a stream, so that only the record being
+// processed is held in memory. Stages that need every record, such as
+// aggregations, buffer their input with Collect.
+type Iterator interface {
+	Next(ctx context.Context) (*record.Record, error)
+}
+
+// IteratorFunc adapts a function to the Iterator interface.
+type IteratorFunc func(ctx context.Context) (*record.Record, error)
+
+// Next calls f.
+func (f IteratorFunc) Next(ctx context.Context) (*record.Record, error) {
+	return f(ctx)
+}
+
+// SliceIterator returns an iterator over records held in memory.
+func SliceIterator(data []*record.Record) Iterator {
+	i := 0
+	return IteratorFunc(func(ctx context.Context) (*record.Record, error) {
+		if i >= len(data) {
+			return nil, io.EOF
+		}
+		r := data[i]
+		data[i] = nil // let the record be collected once it has moved on
+		i++
+		return r, nil
+	})
+}
+
+// Collect reads the remaining records of an iterator into a slice.
+func Collect(ctx context.Context, it Iterator) ([]*record.Record, error) {
+	var data []*record.Record
+	for {
+		r, err := it.Next(ctx)
+		if err == io.EOF {
+			return data, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, r)
+	}
+}
+
+// MapRecords returns an iterator that applies fn to each record of in. fn
+// returns the record to pass on, or nil to drop the record.
+func MapRecords(in Iterator, fn func(r *record.Record) (*record.Record, error)) Iterator {
+	return IteratorFunc(func(ctx context.Context) (*record.Record, error) {
+		for {
+			r, err := in.Next(ctx)
+			if err != nil {
+				return nil, err
+			}
+			out, err := fn(r)
+			if err != nil {
+				return nil, err
+			}
+			if out != nil {
+				return out, nil
+			}
+		}
+	})
+}
+
+// BufferRecords returns an iterator for a blocking stage: on the first call
+// to Next it collects every record of in, passes them to fn and then yields
+// the records fn returns.
+func BufferRecords(in Iterator, fn func(data []*record.Record) ([]*record.Record, error)) Iterator {
+	var out Iterator
+	return IteratorFunc(func(ctx context.Context) (*record.Record, error) {
+		if out == nil {
+			data, err := Collect(ctx, in)
+			if err != nil {
+				return nil, err
+			}
+			result, err := fn(data)
+			if err != nil {
+				return nil, err
+			}
+			out = SliceIterator(result)
+		}
+		return out.Next(ctx)
+	})
+}
+
+// stageError marks an error that has already been attributed to a pipeline
+// stage, so that later stages pass it on unchanged.
+type stageError struct {
+	stage string
+	err   error
+}
+
+func (e *stageError) Error() string {
+	return "error " + e.stage + ": " + e.err.Error()
+}
+
+func (e *stageError) Unwrap() error {
+	return e.err
+}
+
+// Helper function to attribute the errors of an iterator to a pipeline stage
+// and stop the stream when the context is cancelled
+func stageIterator(in Iterator, stage string) Iterator {
+	return IteratorFunc(func(ctx context.Context) (*record.Record, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		r, err := in.Next(ctx)
+		var stageErr *stageError
+		if err != nil && err != io.EOF && !errors.As(err, &stageErr) && !errors.Is(err, ctx.Err()) {
+			err = &stageError{stage: stage, err: err}
+		}
+		return r, err
+	})
+}
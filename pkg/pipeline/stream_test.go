@@ -0,0 +1,108 @@
+package pipeline
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/avii09/hookit/pkg/config"
+	"github.com/avii09/hookit/pkg/transform"
+)
+
+// BenchmarkStreamCSV runs a CSV to JSON pipeline with a filter and a mapping
+// over inputs of increasing size. The peak-heap-MB metric should stay flat as
+// the number of rows grows, since records are streamed one at a time.
+//
+//	go test ./pkg/pipeline -run '^$' -bench StreamCSV -benchtime 1x
+func BenchmarkStreamCSV(b *testing.B) {
+	for _, rows := range []int{10000, 100000, 1000000} {
+		b.Run(fmt.Sprintf("rows=%d", rows), func(b *testing.B) {
+			dir := b.TempDir()
+			in := filepath.Join(dir, "in.csv")
+			writeBenchCSV(b, in, rows)
+
+			rules := transform.TransformationRules{
+				Filter: []transform.FilterRule{{Column: "age", Condition: ">= 30"}},
+				Mapping: transform.MappingRules{
+					CustomMapping: []transform.FieldMapping{{From: "name", To: "full_name"}},
+					Drop:          []string{"email"},
+				},
+			}
+			filter, err := newFilterTransformer(rules)
+			if err != nil {
+				b.Fatal(err)
+			}
+			mapping, err := newMappingTransformer(rules)
+			if err != nil {
+				b.Fatal(err)
+			}
+			source, _ := newCSVSource(config.EndpointConfig{FilePath: in})
+			sink, _ := newJSONSink(config.EndpointConfig{FilePath: filepath.Join(dir, "out.json")})
+			p := &Pipeline{Source: source, Transformers: []Transformer{filter, mapping}, Sink: sink}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			var peak uint64
+			for i := 0; i < b.N; i++ {
+				runtime.GC()
+				stop := sampleHeap(&peak)
+				if err := p.Run(context.Background()); err != nil {
+					b.Fatal(err)
+				}
+				stop()
+			}
+			b.ReportMetric(float64(peak)/(1<<20), "peak-heap-MB")
+		})
+	}
+}
+
+// Helper function to write a CSV file with the given number of rows
+func writeBenchCSV(b *testing.B, path string, rows int) {
+	file, err := os.Create(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer file.Close()
+	w := bufio.NewWriter(file)
+	fmt.Fprintln(w, "id,name,email,age,city")
+	for i := 0; i < rows; i++ {
+		fmt.Fprintf(w, "%d,User %d,user%d@example.com,%d,City %d\n", i, i, i, 18+i%50, i%100)
+	}
+	if err := w.Flush(); err != nil {
+		b.Fatal(err)
+	}
+}
+
+// Helper function to record the peak heap size until the returned function is called
+func sampleHeap(peak *uint64) func() {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(5 * time.Millisecond)
+		defer ticker.Stop()
+		var stats runtime.MemStats
+		for {
+			runtime.ReadMemStats(&stats)
+			if stats.HeapInuse > *peak {
+				*peak = stats.HeapInuse
+			}
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
@@ -0,0 +1,289 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/avii09/hookit/pkg/record"
+	"github.com/avii09/hookit/pkg/transform"
+)
+
+func init() {
+	RegisterTransformer("filter", newFilterTransformer)
+	RegisterTransformer("mapping", newMappingTransformer)
+	RegisterTransformer("set", newSetTransformer)
+	RegisterTransformer("aggregation", newAggregationTransformer)
+	RegisterTransformer("flatten", newFlattenTransformer)
+	RegisterTransformer("unflatten", newUnflattenTransformer)
+}
+
+// filterTransformer keeps the records that satisfy every filter rule.
+type filterTransformer struct {
+	filters []*transform.Filter
+}
+
+func newFilterTransformer(rules transform.TransformationRules) (Transformer, error) {
+	if len(rules.Filter) == 0 {
+		return nil, nil
+	}
+	t := &filterTransformer{}
+	for _, rule := range rules.Filter {
+		filter, err := transform.CompileFilter(rule.Column, rule.Condition)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter condition %q: %w", rule.Condition, err)
+		}
+		t.filters = append(t.filters, filter)
+	}
+	return t, nil
+}
+
+func (t *filterTransformer) Transform(ctx context.Context, in Iterator) Iterator {
+	return transformRecords(ctx, in, t)
+}
+
+func (t *filterTransformer) TransformRecord(ctx context.Context, row *record.Record) (*record.Record, error) {
+	if matchesAll(row, t.filters) {
+		return row, nil
+	}
+	return nil, nil
+}
+
+func (t *filterTransformer) String() string {
+	conditions := make([]string, len(t.filters))
+	for i, filter := range t.filters {
+		conditions[i] = filter.String()
+		if len(t.filters) > 1 {
+			conditions[i] = "(" + conditions[i] + ")"
+		}
+	}
+	return "filter: keep records where " + strings.Join(conditions, " and ")
+}
+
+// Helper function to apply a record transformer to each record of a stream
+func transformRecords(ctx context.Context, in Iterator, t RecordTransformer) Iterator {
+	return MapRecords(in, func(r *record.Record) (*record.Record, error) {
+		return applyRecord(ctx, t, r)
+	})
+}
+
+// Helper function to check a record against every filter
+func matchesAll(row *record.Record, filters []*transform.Filter) bool {
+	for _, filter := range filters {
+		if !filter.Match(row) {
+			return false
+		}
+	}
+	return true
+}
+
+// mappingTransformer renames, copies, drops and reorders fields according to the mapping rules.
+type mappingTransformer struct {
+	mapping transform.MappingRules
+	// copy is set when a rule can fail, so that records are mapped as copies
+	// and a record that fails is left as it was
+	copy bool
+}
+
+func newMappingTransformer(rules transform.TransformationRules) (Transformer, error) {
+	if rules.Mapping.IsZero() {
+		return nil, nil
+	}
+	t := &mappingTransformer{mapping: rules.Mapping}
+	targets := append(append([]transform.FieldMapping(nil), rules.Mapping.CustomMapping...), rules.Mapping.Copy...)
+	for field := range rules.Mapping.AddField {
+		targets = append(targets, transform.FieldMapping{To: field})
+	}
+	for _, target := range targets {
+		// Only paths into nested values can fail to be set
+		if strings.ContainsAny(target.To, ".[") {
+			t.copy = true
+		}
+	}
+	return t, nil
+}
+
+func (t *mappingTransformer) Transform(ctx context.Context, in Iterator) Iterator {
+	return transformRecords(ctx, in, t)
+}
+
+func (t *mappingTransformer) TransformRecord(ctx context.Context, r *record.Record) (*record.Record, error) {
+	if t.copy {
+		r = record.CopyValue(r).(*record.Record)
+	}
+	return transform.MapRecord(r, t.mapping)
+}
+
+func (t *mappingTransformer) String() string {
+	m := t.mapping
+	var steps []string
+	if m.DynamicMapping {
+		steps = append(steps, "lowercase keys")
+	}
+	if m.Case != "" {
+		steps = append(steps, fmt.Sprintf("convert keys to %s case", m.Case))
+	}
+	for _, rename := range m.CustomMapping {
+		steps = append(steps, fmt.Sprintf("rename %s to %s", rename.From, rename.To))
+	}
+	for _, c := range m.Copy {
+		steps = append(steps, fmt.Sprintf("copy %s to %s", c.From, c.To))
+	}
+	if m.NameChange != "" {
+		steps = append(steps, fmt.Sprintf("set name to %q", m.NameChange))
+	}
+	keys := make([]string, 0, len(m.AddField))
+	for key := range m.AddField {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		steps = append(steps, fmt.Sprintf("add %s=%q", key, m.AddField[key]))
+	}
+	if len(m.Drop) > 0 {
+		steps = append(steps, "drop "+strings.Join(m.Drop, ", "))
+	}
+	if len(m.Keep) > 0 {
+		steps = append(steps, "keep only "+strings.Join(m.Keep, ", "))
+	}
+	if len(m.Order) > 0 {
+		steps = append(steps, "order "+strings.Join(m.Order, ", "))
+	}
+	return "mapping: " + strings.Join(steps, ", ")
+}
+
+// setTransformer assigns constant, templated or computed values to fields,
+// in the order the rules are listed.
+type setTransformer struct {
+	setters []*transform.Setter
+}
+
+func newSetTransformer(rules transform.TransformationRules) (Transformer, error) {
+	if len(rules.Set) == 0 {
+		return nil, nil
+	}
+	t := &setTransformer{}
+	for _, rule := range rules.Set {
+		setter, err := transform.CompileSet(rule)
+		if err != nil {
+			return nil, fmt.Errorf("invalid set rule for %q: %w", rule.Field, err)
+		}
+		t.setters = append(t.setters, setter)
+	}
+	return t, nil
+}
+
+func (t *setTransformer) Transform(ctx context.Context, in Iterator) Iterator {
+	return transformRecords(ctx, in, t)
+}
+
+func (t *setTransformer) TransformRecord(ctx context.Context, r *record.Record) (*record.Record, error) {
+	if err := transform.ApplySetters(r, t.setters); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (t *setTransformer) String() string {
+	steps := make([]string, len(t.setters))
+	for i, setter := range t.setters {
+		steps[i] = setter.String()
+	}
+	return "set: " + strings.Join(steps, ", ")
+}
+
+// aggregationTransformer aggregates records, either into one record per
+// group or by adding the results of each group to its records.
+type aggregationTransformer struct {
+	aggregations []transform.AggregationRule
+	groupBy      []string
+	mode         string
+}
+
+func newAggregationTransformer(rules transform.TransformationRules) (Transformer, error) {
+	if len(rules.Aggregation) == 0 && len(rules.GroupBy) == 0 {
+		return nil, nil
+	}
+	return &aggregationTransformer{
+		aggregations: rules.Aggregation,
+		groupBy:      rules.GroupBy,
+		mode:         rules.AggregationModeOrDefault(),
+	}, nil
+}
+
+// Transform buffers every record, since each group needs all of its members.
+func (t *aggregationTransformer) Transform(ctx context.Context, in Iterator) Iterator {
+	return BufferRecords(in, func(data []*record.Record) ([]*record.Record, error) {
+		return transform.Aggregate(data, t.groupBy, t.aggregations, t.mode)
+	})
+}
+
+func (t *aggregationTransformer) String() string {
+	var steps []string
+	for _, aggregation := range t.aggregations {
+		operation := aggregation.Operation
+		if operation == "percentile" && aggregation.Percentile != nil {
+			operation = fmt.Sprintf("p%v", *aggregation.Percentile)
+		}
+		steps = append(steps, fmt.Sprintf("%s(%s) as %s", operation, aggregation.Column, aggregation.As))
+	}
+	s := "aggregation: " + strings.Join(steps, ", ")
+	if len(t.groupBy) > 0 {
+		s += " grouped by " + strings.Join(t.groupBy, ", ")
+	}
+	if t.mode == transform.AggregationModeWindow {
+		s += " (added to every record)"
+	} else {
+		s += " (one record per group)"
+	}
+	return s
+}
+
+// flattenTransformer moves nested fields to the top level of each record.
+type flattenTransformer struct {
+	rules transform.FlattenRules
+}
+
+func newFlattenTransformer(rules transform.TransformationRules) (Transformer, error) {
+	if !rules.Flatten.Enabled {
+		return nil, nil
+	}
+	return &flattenTransformer{rules: rules.Flatten}, nil
+}
+
+func (t *flattenTransformer) Transform(ctx context.Context, in Iterator) Iterator {
+	return transformRecords(ctx, in, t)
+}
+
+func (t *flattenTransformer) TransformRecord(ctx context.Context, r *record.Record) (*record.Record, error) {
+	return transform.FlattenRecord(r, t.rules), nil
+}
+
+func (t *flattenTransformer) String() string {
+	return fmt.Sprintf("flatten: join nested field names with %q", t.rules.SeparatorOrDefault())
+}
+
+// unflattenTransformer nests fields whose names are paths, such as "address.city".
+type unflattenTransformer struct {
+	rules transform.FlattenRules
+}
+
+func newUnflattenTransformer(rules transform.TransformationRules) (Transformer, error) {
+	if !rules.Unflatten.Enabled {
+		return nil, nil
+	}
+	return &unflattenTransformer{rules: rules.Unflatten}, nil
+}
+
+func (t *unflattenTransformer) Transform(ctx context.Context, in Iterator) Iterator {
+	return transformRecords(ctx, in, t)
+}
+
+func (t *unflattenTransformer) TransformRecord(ctx context.Context, r *record.Record) (*record.Record, error) {
+	return transform.UnflattenRecord(r, t.rules), nil
+}
+
+func (t *unflattenTransformer) String() string {
+	return fmt.Sprintf("unflatten: split field names at %q into nested objects", t.rules.SeparatorOrDefault())
+}
@@ -0,0 +1,332 @@
+package record
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// JSON has no timestamp, bytes, geopoint or reference types, so values of
+// those kinds are written as single-key objects tagged with the type and
+// read back into the same kind:
+//
+//	{"$timestamp": "2024-05-01T12:00:00Z"}
+//	{"$bytes": "aGVsbG8="}
+//	{"$geopoint": {"latitude": 52.37, "longitude": 4.89}}
+//	{"$ref": "users/alice"}
+//
+// Integers and floats are kept apart: a JSON number without a fraction or
+// exponent is read as int64, any other number as float64.
+const (
+	timestampTag = "$timestamp"
+	bytesTag     = "$bytes"
+	geoPointTag  = "$geopoint"
+	referenceTag = "$ref"
+)
+
+// MarshalJSON encodes the record as a JSON object with its fields in order.
+func (r *Record) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range r.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		keyBytes, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		valueBytes, err := marshalValue(r.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(keyBytes)
+		buf.WriteByte(':')
+		buf.Write(valueBytes)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON decodes a JSON object into the record, keeping its fields in
+// the order they appear and restoring tagged values.
+func (r *Record) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	value, err := decodeValue(dec)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return err
+	}
+	decoded, ok := value.(*Record)
+	if !ok {
+		return fmt.Errorf("expected a JSON object, got %s", KindOf(value))
+	}
+	*r = *decoded
+	return nil
+}
+
+// MarshalJSON encodes the geopoint as a tagged object.
+func (g GeoPoint) MarshalJSON() ([]byte, error) {
+	type plain GeoPoint
+	point, err := json.Marshal(plain(g))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(`{"` + geoPointTag + `":` + string(point) + `}`), nil
+}
+
+// MarshalJSON encodes the reference as a tagged object.
+func (ref Reference) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]string{referenceTag: ref.Path})
+}
+
+// Decoder reads records one at a time from JSON input holding an array of
+// objects or a stream of objects, such as one object per line, so that large
+// files are never held in memory at once.
+type Decoder struct {
+	dec     *json.Decoder
+	inArray bool
+	index   int
+}
+
+// NewDecoder returns a decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	return &Decoder{dec: dec}
+}
+
+// Next returns the next record, or io.EOF when the input is exhausted.
+func (d *Decoder) Next() (*Record, error) {
+	for {
+		if d.inArray {
+			if !d.dec.More() {
+				// Consume the closing bracket; another top-level value may follow.
+				if _, err := d.dec.Token(); err == io.EOF {
+					return nil, io.ErrUnexpectedEOF
+				} else if err != nil {
+					return nil, err
+				}
+				d.inArray = false
+				continue
+			}
+			value, err := decodeNested(d.dec)
+			if err != nil {
+				return nil, err
+			}
+			rec, ok := value.(*Record)
+			if !ok {
+				return nil, fmt.Errorf("element %d: expected a JSON object, got %s", d.index, KindOf(value))
+			}
+			d.index++
+			return rec, nil
+		}
+
+		tok, err := d.dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		if tok == json.Delim('[') {
+			d.inArray = true
+			continue
+		}
+		value, err := decodeToken(d.dec, tok)
+		if err != nil {
+			return nil, err
+		}
+		rec, ok := value.(*Record)
+		if !ok {
+			return nil, fmt.Errorf("expected a JSON object or array, got %s", KindOf(value))
+		}
+		return rec, nil
+	}
+}
+
+// DecodeJSON reads every record from r, which holds an array of objects or a
+// stream of objects.
+func DecodeJSON(r io.Reader) ([]*Record, error) {
+	d := NewDecoder(r)
+	var records []*Record
+	for {
+		rec, err := d.Next()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+}
+
+// Helper function to encode a field value as JSON, tagging the kinds JSON cannot represent
+func marshalValue(v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case time.Time:
+		return json.Marshal(map[string]string{timestampTag: v.Format(time.RFC3339Nano)})
+	case []byte:
+		return json.Marshal(map[string]string{bytesTag: base64.StdEncoding.EncodeToString(v)})
+	case float64:
+		// Keep a fraction on whole floats so they are not read back as integers
+		b, err := json.Marshal(v)
+		if err == nil && !bytes.ContainsAny(b, ".eE") {
+			b = append(b, ".0"...)
+		}
+		return b, err
+	case []interface{}:
+		var buf bytes.Buffer
+		buf.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			b, err := marshalValue(item)
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(b)
+		}
+		buf.WriteByte(']')
+		return buf.Bytes(), nil
+	case map[string]interface{}:
+		return FromMap(v).MarshalJSON()
+	}
+	return json.Marshal(v)
+}
+
+// Helper function to decode the next JSON value from the decoder into a field value
+func decodeValue(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	return decodeToken(dec, tok)
+}
+
+// Helper function to decode a value inside an object or array, where the end
+// of the input is an error
+func decodeNested(dec *json.Decoder) (interface{}, error) {
+	value, err := decodeValue(dec)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return value, err
+}
+
+// Helper function to decode the value that starts with tok
+func decodeToken(dec *json.Decoder, tok json.Token) (interface{}, error) {
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			obj := New()
+			for dec.More() {
+				keyTok, err := dec.Token()
+				if err == io.EOF {
+					return nil, io.ErrUnexpectedEOF
+				}
+				if err != nil {
+					return nil, err
+				}
+				key, _ := keyTok.(string)
+				value, err := decodeNested(dec)
+				if err != nil {
+					return nil, err
+				}
+				obj.Set(key, value)
+			}
+			if _, err := dec.Token(); err == io.EOF {
+				return nil, io.ErrUnexpectedEOF
+			} else if err != nil {
+				return nil, err
+			}
+			return untag(obj)
+		case '[':
+			items := []interface{}{}
+			for dec.More() {
+				value, err := decodeNested(dec)
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, value)
+			}
+			if _, err := dec.Token(); err == io.EOF {
+				return nil, io.ErrUnexpectedEOF
+			} else if err != nil {
+				return nil, err
+			}
+			return items, nil
+		}
+		return nil, fmt.Errorf("unexpected %v", t)
+	case json.Number:
+		return parseNumber(string(t)), nil
+	}
+	// string, bool or nil
+	return tok, nil
+}
+
+// Helper function to turn a tagged object back into the value it encodes
+func untag(obj *Record) (interface{}, error) {
+	if obj.Len() != 1 {
+		return obj, nil
+	}
+	tag := obj.keys[0]
+	value := obj.values[tag]
+	switch tag {
+	case timestampTag:
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s must be a string", timestampTag)
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", timestampTag, err)
+		}
+		return t, nil
+	case bytesTag:
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s must be a string", bytesTag)
+		}
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", bytesTag, err)
+		}
+		return b, nil
+	case geoPointTag:
+		point, ok := value.(*Record)
+		if !ok {
+			return nil, fmt.Errorf("%s must be an object", geoPointTag)
+		}
+		lat, latOK := point.numberField("latitude")
+		lng, lngOK := point.numberField("longitude")
+		if !latOK || !lngOK {
+			return nil, fmt.Errorf("%s must have numeric latitude and longitude", geoPointTag)
+		}
+		return GeoPoint{Latitude: lat, Longitude: lng}, nil
+	case referenceTag:
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s must be a string", referenceTag)
+		}
+		return Reference{Path: s}, nil
+	}
+	return obj, nil
+}
+
+// Helper function to read a numeric field of a decoded object
+func (r *Record) numberField(key string) (float64, bool) {
+	switch v := r.values[key].(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
@@ -0,0 +1,234 @@
+package record
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 30, 0, 123456789, time.FixedZone("", 2*60*60))
+	r := New()
+	r.Set("int", int64(math.MaxInt64))
+	r.Set("negative", int64(-7))
+	r.Set("float", 2.5)
+	r.Set("whole_float", 3.0)
+	r.Set("small_float", 1e-7)
+	r.Set("bool", true)
+	r.Set("null", nil)
+	r.Set("string", "héllo \"quoted\" <tag>")
+	r.Set("time", at)
+	r.Set("bytes", []byte("hello\x00"))
+	r.Set("geo", GeoPoint{Latitude: 52.37, Longitude: 4.89})
+	r.Set("ref", Reference{Path: "users/alice"})
+	nested := New()
+	nested.Set("z", int64(1))
+	nested.Set("a", []interface{}{int64(1), 1.5, "x", nil, at, Reference{Path: "a/b"}})
+	r.Set("nested", nested)
+	r.Set("empty", New())
+	r.Set("none", []interface{}{})
+
+	b, err := r.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"int":9223372036854775807,"negative":-7,"float":2.5,"whole_float":3.0,"small_float":1e-7,"bool":true,"null":null,` +
+		`"string":"héllo \"quoted\" \u003ctag\u003e","time":{"$timestamp":"2024-05-01T12:30:00.123456789+02:00"},"bytes":{"$bytes":"aGVsbG8A"},` +
+		`"geo":{"$geopoint":{"latitude":52.37,"longitude":4.89}},"ref":{"$ref":"users/alice"},` +
+		`"nested":{"z":1,"a":[1,1.5,"x",null,{"$timestamp":"2024-05-01T12:30:00.123456789+02:00"},{"$ref":"a/b"}]},"empty":{},"none":[]}`
+	if string(b) != want {
+		t.Fatalf("got  %s\nwant %s", b, want)
+	}
+
+	decoded := New()
+	if err := decoded.UnmarshalJSON(b); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := decoded.Keys(), r.Keys(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got keys %v, want %v", got, want)
+	}
+	for _, key := range r.Keys() {
+		want, _ := r.Get(key)
+		got, _ := decoded.Get(key)
+		if KindOf(got) != KindOf(want) {
+			t.Errorf("%s: got %s, want %s", key, KindOf(got), KindOf(want))
+		}
+	}
+	if got, _ := decoded.Get("time"); !got.(time.Time).Equal(at) {
+		t.Errorf("time: got %v, want %v", got, at)
+	}
+	if got, _ := decoded.Get("bytes"); !bytes.Equal(got.([]byte), []byte("hello\x00")) {
+		t.Errorf("bytes: got %q", got)
+	}
+	if got, _ := decoded.Get("geo"); got != (GeoPoint{Latitude: 52.37, Longitude: 4.89}) {
+		t.Errorf("geo: got %v", got)
+	}
+	if got, _ := decoded.Lookup("nested.a[5]"); got != (Reference{Path: "a/b"}) {
+		t.Errorf("nested reference: got %v", got)
+	}
+
+	// Encoding again gives the same JSON
+	again, err := decoded.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) != string(b) {
+		t.Errorf("second encoding:\ngot  %s\nwant %s", again, b)
+	}
+}
+
+func TestJSONNumbers(t *testing.T) {
+	tests := []struct {
+		json string
+		want interface{}
+	}{
+		{"1", int64(1)},
+		{"-0", int64(0)},
+		{"1.0", 1.0},
+		{"1e2", 100.0},
+		{"1E-2", 0.01},
+		{"9223372036854775807", int64(math.MaxInt64)},
+		{"9223372036854775808", 9223372036854775808.0},
+		{"12345678901234567890123", 1.2345678901234568e22},
+	}
+	for _, test := range tests {
+		r := New()
+		if err := r.UnmarshalJSON([]byte(`{"n":` + test.json + `}`)); err != nil {
+			t.Errorf("%s: %v", test.json, err)
+			continue
+		}
+		if got, _ := r.Get("n"); got != test.want {
+			t.Errorf("%s: got %v (%T), want %v (%T)", test.json, got, got, test.want, test.want)
+		}
+	}
+}
+
+func TestJSONTags(t *testing.T) {
+	tests := []struct {
+		json string
+		want interface{}
+		err  string
+	}{
+		{`{"$geopoint":{"latitude":1,"longitude":-2}}`, GeoPoint{Latitude: 1, Longitude: -2}, ""},
+		{`{"$timestamp":"2024-05-01T12:00:00Z"}`, time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), ""},
+		// Objects that only look like tags are kept as objects
+		{`{"$ref":"a/b","extra":1}`, "object", ""},
+		{`{"$other":"x"}`, "object", ""},
+		{`{"$timestamp":"yesterday"}`, nil, "invalid $timestamp: "},
+		{`{"$timestamp":1}`, nil, "$timestamp must be a string"},
+		{`{"$bytes":"not base64!"}`, nil, "invalid $bytes: "},
+		{`{"$geopoint":{"latitude":"1","longitude":2}}`, nil, "$geopoint must have numeric latitude and longitude"},
+		{`{"$ref":null}`, nil, "$ref must be a string"},
+	}
+	for _, test := range tests {
+		r := New()
+		err := r.UnmarshalJSON([]byte(`{"v":` + test.json + `}`))
+		if test.err != "" {
+			if err == nil || !strings.HasPrefix(err.Error(), test.err) {
+				t.Errorf("%s: got error %v, want %s", test.json, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.json, err)
+			continue
+		}
+		got, _ := r.Get("v")
+		if test.want == "object" {
+			if KindOf(got) != KindObject {
+				t.Errorf("%s: got %s, want an object", test.json, KindOf(got))
+			}
+		} else if got != test.want {
+			t.Errorf("%s: got %v, want %v", test.json, got, test.want)
+		}
+	}
+}
+
+func TestMarshalUnsupportedValues(t *testing.T) {
+	r := New()
+	r.Set("nan", math.NaN())
+	if _, err := r.MarshalJSON(); err == nil {
+		t.Error("NaN: expected an error")
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+		err   string
+	}{
+		{`[{"a":1},{"b":2}]`, []string{`{"a":1}`, `{"b":2}`}, ""},
+		{"{\"a\":1}\n{\"b\":2}\n", []string{`{"a":1}`, `{"b":2}`}, ""},
+		{`[{"a":1}] [{"b":2}] {"c":3}`, []string{`{"a":1}`, `{"b":2}`, `{"c":3}`}, ""},
+		{`[]`, nil, ""},
+		{``, nil, ""},
+		{`[{"a":1},2]`, []string{`{"a":1}`}, "element 1: expected a JSON object, got int"},
+		{`"x"`, nil, "expected a JSON object or array, got string"},
+		{`[{"a":1}`, []string{`{"a":1}`}, "unexpected end of JSON input"},
+		{`{"a":[1,`, nil, "unexpected end of JSON input"},
+	}
+	for _, test := range tests {
+		d := NewDecoder(strings.NewReader(test.input))
+		var got []string
+		var err error
+		for {
+			var r *Record
+			r, err = d.Next()
+			if err != nil {
+				break
+			}
+			b, _ := r.MarshalJSON()
+			got = append(got, string(b))
+		}
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+		if (err == nil) != (test.err == "") || err != nil && err.Error() != test.err {
+			t.Errorf("%s: got error %v, want %q", test.input, err, test.err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.input, got, test.want)
+		}
+	}
+}
+
+func TestNormalizeAndFormat(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value  interface{}
+		kind   Kind
+		format string
+	}{
+		{nil, KindNull, ""},
+		{true, KindBool, "true"},
+		{int32(-3), KindInt, "-3"},
+		{uint64(math.MaxUint64), KindFloat, "18446744073709552000"},
+		{float32(0.5), KindFloat, "0.5"},
+		{1e21, KindFloat, "1000000000000000000000"},
+		{"x", KindString, "x"},
+		{at, KindTimestamp, "2024-05-01T12:00:00Z"},
+		{&at, KindTimestamp, "2024-05-01T12:00:00Z"},
+		{[]byte("hi"), KindBytes, "aGk="},
+		{[]string{"a", "b"}, KindArray, `["a","b"]`},
+		{map[string]interface{}{"b": 1, "a": []interface{}{1.0}}, KindObject, `{"a":[1.0],"b":1}`},
+		{map[string]string{"y": "2", "x": "1"}, KindObject, `{"x":"1","y":"2"}`},
+		{&GeoPoint{Latitude: 1.5, Longitude: -2}, KindGeoPoint, "1.5,-2"},
+		{Reference{Path: "users/alice"}, KindReference, "users/alice"},
+		{struct{ A int }{1}, KindString, "{1}"},
+	}
+	for _, test := range tests {
+		normalized := Normalize(test.value)
+		if kind := KindOf(normalized); kind != test.kind {
+			t.Errorf("Normalize(%#v) gives a %s, want %s", test.value, kind, test.kind)
+		}
+		if got := Format(test.value); got != test.format {
+			t.Errorf("Format(%#v) = %q, want %q", test.value, got, test.format)
+		}
+	}
+}
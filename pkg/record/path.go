@@ -0,0 +1,363 @@
+package record
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Paths address fields inside nested objects and arrays, as in "address.city"
+// or "items[0].sku". A leading "$." is allowed, JSONPath style, and names that
+// contain dots or brackets can be quoted: "meta['content.type']".
+//
+// A field whose name is the whole path, such as a CSV column named
+// "address.city", takes precedence over the nested field.
+
+// pathSegment is a field name or, when isIndex is set, an array index.
+type pathSegment struct {
+	name    string
+	index   int
+	isIndex bool
+}
+
+// ValidatePath checks the syntax of a path.
+func ValidatePath(path string) error {
+	_, err := parsePath(path, ".")
+	return err
+}
+
+// Lookup returns the value at a path and whether it exists.
+func (r *Record) Lookup(path string) (interface{}, bool) {
+	if value, ok := r.values[path]; ok {
+		return value, true
+	}
+	segments, err := parsePath(path, ".")
+	if err != nil {
+		return nil, false
+	}
+	var current interface{} = r
+	for _, seg := range segments {
+		switch container := current.(type) {
+		case *Record:
+			if seg.isIndex {
+				return nil, false
+			}
+			value, ok := container.values[seg.name]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		case []interface{}:
+			if !seg.isIndex || seg.index >= len(container) {
+				return nil, false
+			}
+			current = container[seg.index]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// SetPath sets the value at a path, creating the objects and arrays on the way
+// as needed. An array index may name an existing element or the next one
+// after the end. It fails when the path is invalid, crosses a value that is
+// not an object or array, or indexes past the end of an array.
+func (r *Record) SetPath(path string, value interface{}) error {
+	if r.Has(path) {
+		r.Set(path, value)
+		return nil
+	}
+	segments, err := parsePath(path, ".")
+	if err != nil {
+		return err
+	}
+	if _, err := setIn(r, segments, value); err != nil {
+		return fmt.Errorf("cannot set %q: %v", path, err)
+	}
+	return nil
+}
+
+// PathRoot returns the top-level field a path is in, such as "address" for
+// "address.city", or "" when the path is invalid.
+func (r *Record) PathRoot(path string) string {
+	if r.Has(path) {
+		return path
+	}
+	segments, err := parsePath(path, ".")
+	if err != nil {
+		return ""
+	}
+	return segments[0].name
+}
+
+// DeletePath removes the value at a path. Removing an array element shifts
+// the elements after it. It reports whether the value existed.
+func (r *Record) DeletePath(path string) bool {
+	if r.Has(path) {
+		r.Delete(path)
+		return true
+	}
+	segments, err := parsePath(path, ".")
+	if err != nil {
+		return false
+	}
+	_, ok := deleteIn(r, segments)
+	return ok
+}
+
+// RenamePath moves the value at one path to another. Renaming a top-level
+// field to a plain name keeps its position, like Rename. It reports whether
+// the value existed, and fails, leaving the record unchanged, when the value
+// cannot be set at the new path.
+func (r *Record) RenamePath(from, to string) (bool, error) {
+	if r.Has(from) && isPlainName(to) {
+		return r.Rename(from, to), nil
+	}
+	value, ok := r.Lookup(from)
+	if !ok {
+		return false, nil
+	}
+
+	// Keep the field that holds the value, to put it back on failure
+	keys := r.Keys()
+	root := r.PathRoot(from)
+	saved := r.values[root]
+	if root != from {
+		saved = CopyValue(saved)
+	}
+	r.DeletePath(from)
+	if err := r.SetPath(to, value); err != nil {
+		r.Set(root, saved)
+		r.Reorder(keys)
+		return true, err
+	}
+	return true, nil
+}
+
+// Flatten returns a record with the fields of nested objects moved to the top
+// level, their names joined with separator, and array elements named with
+// their index, as in "items[0]". Empty objects and arrays are kept as values.
+func Flatten(r *Record, separator string) *Record {
+	out := New()
+	for _, key := range r.keys {
+		flattenInto(out, key, r.values[key], separator)
+	}
+	return out
+}
+
+// Unflatten reverses Flatten, splitting field names at separator and array
+// indexes into nested objects and arrays. A field that conflicts with another
+// one, such as "a" and "a.b", keeps its flat name.
+func Unflatten(r *Record, separator string) *Record {
+	out := New()
+	for _, key := range r.keys {
+		value := r.values[key]
+		segments, err := parsePath(key, separator)
+		if err != nil || len(segments) == 1 {
+			out.Set(key, value)
+			continue
+		}
+		if _, err := setIn(out, segments, value); err != nil {
+			out.Set(key, value)
+		}
+	}
+	return out
+}
+
+// CopyValue returns a deep copy of a value, so that nested objects and
+// arrays are not shared between records.
+func CopyValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case *Record:
+		c := &Record{keys: v.Keys(), values: make(map[string]interface{}, len(v.values))}
+		for key, value := range v.values {
+			c.values[key] = CopyValue(value)
+		}
+		return c
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = CopyValue(item)
+		}
+		return items
+	case []byte:
+		return append([]byte(nil), v...)
+	}
+	return v
+}
+
+// Helper function to flatten a value into out under the given name
+func flattenInto(out *Record, name string, value interface{}, separator string) {
+	switch v := value.(type) {
+	case *Record:
+		if v.Len() == 0 {
+			out.Set(name, v)
+			return
+		}
+		for _, key := range v.keys {
+			flattenInto(out, name+separator+key, v.values[key], separator)
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			out.Set(name, v)
+			return
+		}
+		for i, item := range v {
+			flattenInto(out, fmt.Sprintf("%s[%d]", name, i), item, separator)
+		}
+	default:
+		out.Set(name, value)
+	}
+}
+
+// Helper function to set a value inside a container, returning the updated
+// container. Containers are only changed once the whole path has been set.
+func setIn(current interface{}, segments []pathSegment, value interface{}) (interface{}, error) {
+	if len(segments) == 0 {
+		return value, nil
+	}
+	seg := segments[0]
+	if seg.isIndex {
+		items, ok := current.([]interface{})
+		if !ok && current != nil {
+			return nil, fmt.Errorf("[%d] indexes a %s value", seg.index, KindOf(current))
+		}
+		// An index may append to an array but not leave a gap, so a path
+		// such as "x[999999999]" cannot allocate a huge array.
+		if seg.index > len(items) {
+			return nil, fmt.Errorf("[%d] is past the end of an array of %d items", seg.index, len(items))
+		}
+		if seg.index == len(items) {
+			items = append(items, nil)
+		}
+		child, err := setIn(items[seg.index], segments[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		items[seg.index] = child
+		return items, nil
+	}
+
+	obj, ok := current.(*Record)
+	if !ok {
+		if current != nil {
+			return nil, fmt.Errorf("field %q is inside a %s value", seg.name, KindOf(current))
+		}
+		obj = New()
+	}
+	child, err := setIn(obj.values[seg.name], segments[1:], value)
+	if err != nil {
+		return nil, err
+	}
+	obj.Set(seg.name, child)
+	return obj, nil
+}
+
+// Helper function to delete a value inside a container, returning the updated container
+func deleteIn(current interface{}, segments []pathSegment) (interface{}, bool) {
+	seg := segments[0]
+	switch container := current.(type) {
+	case *Record:
+		if seg.isIndex || !container.Has(seg.name) {
+			return current, false
+		}
+		if len(segments) == 1 {
+			container.Delete(seg.name)
+			return container, true
+		}
+		child, ok := deleteIn(container.values[seg.name], segments[1:])
+		container.values[seg.name] = child
+		return container, ok
+	case []interface{}:
+		if !seg.isIndex || seg.index >= len(container) {
+			return current, false
+		}
+		if len(segments) == 1 {
+			return append(container[:seg.index:seg.index], container[seg.index+1:]...), true
+		}
+		child, ok := deleteIn(container[seg.index], segments[1:])
+		container[seg.index] = child
+		return container, ok
+	}
+	return current, false
+}
+
+// Helper function to split a path into segments. Names are separated by
+// separator and may be followed by array indexes or quoted names in brackets.
+func parsePath(path, separator string) ([]pathSegment, error) {
+	if separator == "." {
+		if strings.HasPrefix(path, "$.") {
+			path = path[2:]
+		} else if strings.HasPrefix(path, "$[") {
+			path = path[1:]
+		}
+	}
+	if path == "" {
+		return nil, fmt.Errorf("path is empty")
+	}
+
+	var segments []pathSegment
+	expectName := true
+	for i := 0; i < len(path); {
+		switch {
+		case path[i] == '[':
+			seg, n, err := parseBracket(path[i:])
+			if err != nil {
+				return nil, fmt.Errorf("invalid path %q: %v", path, err)
+			}
+			if seg.isIndex && len(segments) == 0 {
+				return nil, fmt.Errorf("invalid path %q: must start with a field name", path)
+			}
+			segments = append(segments, seg)
+			i += n
+			expectName = false
+		case strings.HasPrefix(path[i:], separator) && !expectName:
+			i += len(separator)
+			expectName = true
+			if i == len(path) {
+				return nil, fmt.Errorf("invalid path %q: ends with %q", path, separator)
+			}
+		default:
+			if !expectName {
+				return nil, fmt.Errorf("invalid path %q: expected %q or \"[\" at offset %d", path, separator, i)
+			}
+			end := i
+			for end < len(path) && path[end] != '[' && !strings.HasPrefix(path[end:], separator) {
+				end++
+			}
+			if end == i {
+				return nil, fmt.Errorf("invalid path %q: empty field name at offset %d", path, i)
+			}
+			segments = append(segments, pathSegment{name: path[i:end]})
+			i = end
+			expectName = false
+		}
+	}
+	return segments, nil
+}
+
+// Helper function to parse a bracketed index or quoted name, returning its length
+func parseBracket(s string) (pathSegment, int, error) {
+	// A quoted name ends at the closing quote, so it may contain brackets
+	if len(s) > 1 && (s[1] == '\'' || s[1] == '"') {
+		if end := strings.Index(s[2:], string(s[1])+"]"); end >= 0 {
+			return pathSegment{name: s[2 : 2+end]}, end + 4, nil
+		}
+	}
+	end := strings.IndexByte(s, ']')
+	if end < 0 {
+		return pathSegment{}, 0, fmt.Errorf("unclosed \"[\"")
+	}
+	inner := s[1:end]
+	index, err := strconv.Atoi(inner)
+	if err != nil || index < 0 {
+		return pathSegment{}, 0, fmt.Errorf("array index %q is not a non-negative integer", inner)
+	}
+	return pathSegment{index: index, isIndex: true}, end + 1, nil
+}
+
+// Helper function to check if a path names a top-level field
+func isPlainName(path string) bool {
+	return !strings.ContainsAny(path, ".[") && !strings.HasPrefix(path, "$")
+}
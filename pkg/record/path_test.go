@@ -0,0 +1,259 @@
+package record
+
+import (
+	"strings"
+	"testing"
+)
+
+// Helper function to decode a record from JSON
+func testRecord(t *testing.T, object string) *Record {
+	t.Helper()
+	records, err := DecodeJSON(strings.NewReader(object))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return records[0]
+}
+
+// Helper function to encode a record as JSON
+func recordJSON(t *testing.T, r *Record) string {
+	t.Helper()
+	b, err := r.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestLookup(t *testing.T) {
+	r := testRecord(t, `{
+		"address": {"city": "Pune", "zip": null},
+		"items": [{"sku": "a1"}, {"sku": "b2", "tags": ["x", "y"]}],
+		"meta": {"content.type": "json", "a[0]": 1},
+		"flat.name": "top",
+		"flat": {"name": "nested"}
+	}`)
+	tests := []struct {
+		path string
+		want interface{}
+		ok   bool
+	}{
+		{"address.city", "Pune", true},
+		{"$.address.city", "Pune", true},
+		{"address.zip", nil, true},
+		{"address.street", nil, false},
+		{"items[1].sku", "b2", true},
+		{"$.items[0].sku", "a1", true},
+		{"items[1].tags[1]", "y", true},
+		{"items[2].sku", nil, false},
+		{"items.sku", nil, false},
+		{"address[0]", nil, false},
+		{"meta['content.type']", "json", true},
+		{`meta["content.type"]`, "json", true},
+		{"meta['a[0]']", int64(1), true},
+		{"meta.content.type", nil, false},
+		{"flat.name", "top", true},
+		{"flat['name']", "nested", true},
+		{"address.city.name", nil, false},
+		{"items[", nil, false},
+	}
+	for _, test := range tests {
+		got, ok := r.Lookup(test.path)
+		if ok != test.ok || got != test.want {
+			t.Errorf("Lookup(%q) = %v, %v; want %v, %v", test.path, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestValidatePath(t *testing.T) {
+	tests := []struct {
+		path string
+		err  string
+	}{
+		{"a.b[0].c", ""},
+		{"$.a", ""},
+		{"$['a.b']", ""},
+		{"a['x]y']", ""},
+		{"", "path is empty"},
+		{"a.", `invalid path "a.": ends with "."`},
+		{"a..b", `invalid path "a..b": empty field name at offset 2`},
+		{"[0]", `invalid path "[0]": must start with a field name`},
+		{"a[", `invalid path "a[": unclosed "["`},
+		{"a[-1]", `invalid path "a[-1]": array index "-1" is not a non-negative integer`},
+		{"a[x]", `invalid path "a[x]": array index "x" is not a non-negative integer`},
+		{"a[0]b", `invalid path "a[0]b": expected "." or "[" at offset 4`},
+	}
+	for _, test := range tests {
+		err := ValidatePath(test.path)
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != test.err {
+			t.Errorf("ValidatePath(%q) = %q, want %q", test.path, got, test.err)
+		}
+	}
+}
+
+func TestSetPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+		err  string
+	}{
+		{"name", `{"name":1,"a":{"b":"x"},"list":[1,2]}`, ""},
+		{"a.c", `{"name":"n","a":{"b":"x","c":1},"list":[1,2]}`, ""},
+		{"x.y.z", `{"name":"n","a":{"b":"x"},"list":[1,2],"x":{"y":{"z":1}}}`, ""},
+		{"list[0]", `{"name":"n","a":{"b":"x"},"list":[1,2]}`, ""},
+		{"list[2]", `{"name":"n","a":{"b":"x"},"list":[1,2,1]}`, ""},
+		{"new[0].id", `{"name":"n","a":{"b":"x"},"list":[1,2],"new":[{"id":1}]}`, ""},
+		{"a['b.c']", `{"name":"n","a":{"b":"x","b.c":1},"list":[1,2]}`, ""},
+		{"list[3]", "", `cannot set "list[3]": [3] is past the end of an array of 2 items`},
+		{"x[999999999]", "", `cannot set "x[999999999]": [999999999] is past the end of an array of 0 items`},
+		{"name.first", "", `cannot set "name.first": field "first" is inside a string value`},
+		{"a[0]", "", `cannot set "a[0]": [0] indexes a object value`},
+		{"a..b", "", `invalid path "a..b": empty field name at offset 2`},
+	}
+	for _, test := range tests {
+		r := testRecord(t, `{"name":"n","a":{"b":"x"},"list":[1,2]}`)
+		err := r.SetPath(test.path, int64(1))
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("SetPath(%q): got error %v, want %s", test.path, err, test.err)
+			}
+			if got := recordJSON(t, r); got != `{"name":"n","a":{"b":"x"},"list":[1,2]}` {
+				t.Errorf("SetPath(%q) changed the record on failure: %s", test.path, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("SetPath(%q): %v", test.path, err)
+			continue
+		}
+		if got := recordJSON(t, r); got != test.want {
+			t.Errorf("SetPath(%q): got %s, want %s", test.path, got, test.want)
+		}
+	}
+}
+
+func TestDeleteAndRenamePath(t *testing.T) {
+	r := testRecord(t, `{"id":1,"a":{"b":1,"c":2},"list":[1,2,3]}`)
+	if !r.DeletePath("a.b") || !r.DeletePath("list[1]") || r.DeletePath("a.missing") || r.DeletePath("list[5]") {
+		t.Error("DeletePath reported the wrong result")
+	}
+	if got, want := recordJSON(t, r), `{"id":1,"a":{"c":2},"list":[1,3]}`; got != want {
+		t.Errorf("after DeletePath: got %s, want %s", got, want)
+	}
+
+	// A top-level rename keeps the field's position
+	for _, rename := range [][2]string{{"id", "key"}, {"a.c", "c"}, {"list[0]", "first.value"}, {"missing", "x"}} {
+		ok, err := r.RenamePath(rename[0], rename[1])
+		if err != nil || ok != (rename[0] != "missing") {
+			t.Errorf("RenamePath(%q, %q) = %v, %v", rename[0], rename[1], ok, err)
+		}
+	}
+	if got, want := recordJSON(t, r), `{"key":1,"a":{},"list":[3],"c":2,"first":{"value":1}}`; got != want {
+		t.Errorf("after RenamePath: got %s, want %s", got, want)
+	}
+}
+
+func TestRenamePathFailure(t *testing.T) {
+	object := `{"a":1,"b":"x","items":[],"meta":{"id":2,"tags":["t"]}}`
+	tests := []struct {
+		from, to string
+		err      string
+	}{
+		{"a", "b.c", `cannot set "b.c": field "c" is inside a string value`},
+		{"a", "items[3]", `cannot set "items[3]": [3] is past the end of an array of 0 items`},
+		{"meta.id", "meta.tags.id", `cannot set "meta.tags.id": field "id" is inside a array value`},
+		{"meta.tags[0]", "meta.tags[1].x", `cannot set "meta.tags[1].x": [1] is past the end of an array of 0 items`},
+	}
+	for _, test := range tests {
+		// The value stays where it was
+		r := testRecord(t, object)
+		ok, err := r.RenamePath(test.from, test.to)
+		if !ok || err == nil || err.Error() != test.err {
+			t.Errorf("RenamePath(%q, %q) = %v, %v, want an error %s", test.from, test.to, ok, err, test.err)
+		}
+		if got := recordJSON(t, r); got != object {
+			t.Errorf("RenamePath(%q, %q) changed the record to %s", test.from, test.to, got)
+		}
+	}
+}
+
+func TestFlattenRoundTrip(t *testing.T) {
+	tests := []struct {
+		object    string
+		separator string
+		flat      string
+	}{
+		{
+			`{"id":1,"address":{"city":"Pune","geo":{"lat":1.5}},"items":[{"sku":"a"},{"sku":"b","tags":["x"]}]}`,
+			".",
+			`{"id":1,"address.city":"Pune","address.geo.lat":1.5,"items[0].sku":"a","items[1].sku":"b","items[1].tags[0]":"x"}`,
+		},
+		{
+			`{"user":{"name":"ann","roles":["admin","dev"]}}`,
+			"__",
+			`{"user__name":"ann","user__roles[0]":"admin","user__roles[1]":"dev"}`,
+		},
+		{
+			`{"empty":{},"none":[],"null":null,"nested":{"empty":[]}}`,
+			".",
+			`{"empty":{},"none":[],"null":null,"nested.empty":[]}`,
+		},
+		{
+			`{"matrix":[[1,2],[3]]}`,
+			".",
+			`{"matrix[0][0]":1,"matrix[0][1]":2,"matrix[1][0]":3}`,
+		},
+	}
+	for _, test := range tests {
+		r := testRecord(t, test.object)
+		flat := Flatten(r, test.separator)
+		if got := recordJSON(t, flat); got != test.flat {
+			t.Errorf("Flatten(%s):\ngot  %s\nwant %s", test.object, got, test.flat)
+		}
+		if got := recordJSON(t, Unflatten(flat, test.separator)); got != test.object {
+			t.Errorf("Unflatten(%s):\ngot  %s\nwant %s", test.flat, got, test.object)
+		}
+	}
+}
+
+func TestUnflatten(t *testing.T) {
+	tests := []struct {
+		flat string
+		want string
+	}{
+		// Quoted names keep their dots and brackets
+		{`{"meta['content.type']":"json","meta['a[0]']":1}`, `{"meta":{"content.type":"json","a[0]":1}}`},
+		// Conflicting fields keep their flat names
+		{`{"a":1,"a.b":2}`, `{"a":1,"a.b":2}`},
+		{`{"a.b":1,"a.b.c":2}`, `{"a":{"b":1},"a.b.c":2}`},
+		// Indexes that would leave gaps keep their flat names
+		{`{"x[999999999]":1,"y[1]":2,"y[0]":3}`, `{"x[999999999]":1,"y[1]":2,"y":[3]}`},
+		// Invalid paths keep their flat names
+		{`{"a..b":1,"[0]":2,"c.":3}`, `{"a..b":1,"[0]":2,"c.":3}`},
+	}
+	for _, test := range tests {
+		if got := recordJSON(t, Unflatten(testRecord(t, test.flat), ".")); got != test.want {
+			t.Errorf("Unflatten(%s):\ngot  %s\nwant %s", test.flat, got, test.want)
+		}
+	}
+}
+
+func TestPathRoot(t *testing.T) {
+	r := testRecord(t, `{"a.b":1,"a":{"b":2}}`)
+	for path, want := range map[string]string{
+		"a.b":        "a.b",
+		"a.c":        "a",
+		"$.x[0].y":   "x",
+		"x['a.b']":   "x",
+		"$['a.c'].d": "a.c",
+		"a..b":       "",
+	} {
+		if got := r.PathRoot(path); got != want {
+			t.Errorf("PathRoot(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
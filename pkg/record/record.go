@@ -0,0 +1,157 @@
+// Package record defines the ordered record type that flows through pipelines.
+package record
+
+import (
+	"sort"
+)
+
+// Record is a set of named fields that remembers the order in which its
+// fields were added, so that column order survives from source to sink.
+type Record struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// New returns an empty record.
+func New() *Record {
+	return &Record{values: make(map[string]interface{})}
+}
+
+// FromMap returns a record with the fields of m in sorted key order.
+func FromMap(m map[string]interface{}) *Record {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return FromMapOrdered(m, keys)
+}
+
+// FromMapOrdered returns a record with the fields of m, placing the keys listed
+// in order first and any remaining keys after them in sorted order.
+func FromMapOrdered(m map[string]interface{}, order []string) *Record {
+	r := &Record{values: make(map[string]interface{}, len(m))}
+	for _, key := range order {
+		if value, ok := m[key]; ok {
+			r.Set(key, value)
+		}
+	}
+	if len(r.keys) < len(m) {
+		var rest []string
+		for key := range m {
+			if !r.Has(key) {
+				rest = append(rest, key)
+			}
+		}
+		sort.Strings(rest)
+		for _, key := range rest {
+			r.Set(key, m[key])
+		}
+	}
+	return r
+}
+
+// Get returns the value of a field and whether the field exists.
+func (r *Record) Get(key string) (interface{}, bool) {
+	value, ok := r.values[key]
+	return value, ok
+}
+
+// Has reports whether the record has the field.
+func (r *Record) Has(key string) bool {
+	_, ok := r.values[key]
+	return ok
+}
+
+// Set sets the value of a field, appending it if the field is new.
+func (r *Record) Set(key string, value interface{}) {
+	if _, ok := r.values[key]; !ok {
+		r.keys = append(r.keys, key)
+	}
+	r.values[key] = value
+}
+
+// Delete removes a field from the record.
+func (r *Record) Delete(key string) {
+	if _, ok := r.values[key]; !ok {
+		return
+	}
+	delete(r.values, key)
+	r.keys = removeKey(r.keys, key)
+}
+
+// Rename renames a field in place, keeping its position. A field already
+// named to is replaced. It reports whether the field existed.
+func (r *Record) Rename(from, to string) bool {
+	value, ok := r.values[from]
+	if !ok || from == to {
+		return ok
+	}
+	if _, exists := r.values[to]; exists {
+		r.keys = removeKey(r.keys, to)
+	}
+	for i, key := range r.keys {
+		if key == from {
+			r.keys[i] = to
+			break
+		}
+	}
+	delete(r.values, from)
+	r.values[to] = value
+	return true
+}
+
+// Reorder moves the listed fields to the front of the record in the given
+// order. Fields that are not listed keep their relative order after them.
+func (r *Record) Reorder(order []string) {
+	keys := make([]string, 0, len(r.keys))
+	listed := make(map[string]bool, len(order))
+	for _, key := range order {
+		if r.Has(key) && !listed[key] {
+			keys = append(keys, key)
+			listed[key] = true
+		}
+	}
+	for _, key := range r.keys {
+		if !listed[key] {
+			keys = append(keys, key)
+		}
+	}
+	r.keys = keys
+}
+
+// Keys returns the field names in order.
+func (r *Record) Keys() []string {
+	keys := make([]string, len(r.keys))
+	copy(keys, r.keys)
+	return keys
+}
+
+// Len returns the number of fields.
+func (r *Record) Len() int {
+	return len(r.keys)
+}
+
+// ToMap returns the fields as a map.
+func (r *Record) ToMap() map[string]interface{} {
+	m := make(map[string]interface{}, len(r.values))
+	for key, value := range r.values {
+		m[key] = value
+	}
+	return m
+}
+
+// Clone returns a shallow copy of the record.
+func (r *Record) Clone() *Record {
+	return &Record{keys: r.Keys(), values: r.ToMap()}
+}
+
+// Helper function to remove a key from a key list
+func removeKey(keys []string, key string) []string {
+	for i, k := range keys {
+		if k == key {
+			return append(keys[:i], keys[i+1:]...)
+		}
+	}
+	return keys
+}
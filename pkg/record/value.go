@@ -0,0 +1,222 @@
+package record
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Field values are plain Go values of one of these types, reported by KindOf:
+//
+//	nil          KindNull
+//	bool         KindBool
+//	int64        KindInt
+//	float64      KindFloat
+//	string       KindString
+//	time.Time    KindTimestamp
+//	[]byte       KindBytes
+//	[]interface{} KindArray, holding values of these types
+//	*Record      KindObject, a nested object
+//	GeoPoint     KindGeoPoint
+//	Reference    KindReference
+//
+// Normalize converts other Go values to these types.
+
+// Kind identifies the type of a field value.
+type Kind int
+
+const (
+	KindNull Kind = iota
+	KindBool
+	KindInt
+	KindFloat
+	KindString
+	KindTimestamp
+	KindBytes
+	KindArray
+	KindObject
+	KindGeoPoint
+	KindReference
+)
+
+var kindNames = [...]string{"null", "bool", "int", "float", "string", "timestamp", "bytes", "array", "object", "geopoint", "reference"}
+
+func (k Kind) String() string {
+	if int(k) < len(kindNames) {
+		return kindNames[k]
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// GeoPoint is a latitude/longitude pair, such as a Firestore geopoint.
+type GeoPoint struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// Reference is a reference to another document, identified by its path
+// relative to the database root, such as "users/alice".
+type Reference struct {
+	Path string
+}
+
+// KindOf returns the kind of a normalized value. Values of other types are
+// reported as KindString, matching how Normalize converts them.
+func KindOf(v interface{}) Kind {
+	switch v.(type) {
+	case nil:
+		return KindNull
+	case bool:
+		return KindBool
+	case int64:
+		return KindInt
+	case float64:
+		return KindFloat
+	case time.Time:
+		return KindTimestamp
+	case []byte:
+		return KindBytes
+	case []interface{}:
+		return KindArray
+	case *Record:
+		return KindObject
+	case GeoPoint:
+		return KindGeoPoint
+	case Reference:
+		return KindReference
+	}
+	return KindString
+}
+
+// Normalize converts a Go value to one of the field value types: other
+// integer and float types are widened, json.Number becomes int64 or float64,
+// maps become nested records with sorted keys, and values of unknown types
+// are formatted as strings.
+func Normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil, bool, int64, float64, string, time.Time, []byte, *Record, GeoPoint, Reference:
+		return v
+	case int:
+		return int64(v)
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case uint:
+		return int64(v)
+	case uint8:
+		return int64(v)
+	case uint16:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case uint64:
+		if v > math.MaxInt64 {
+			return float64(v)
+		}
+		return int64(v)
+	case float32:
+		return float64(v)
+	case json.Number:
+		return parseNumber(string(v))
+	case *GeoPoint:
+		if v == nil {
+			return nil
+		}
+		return *v
+	case *Reference:
+		if v == nil {
+			return nil
+		}
+		return *v
+	case *time.Time:
+		if v == nil {
+			return nil
+		}
+		return *v
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = Normalize(item)
+		}
+		return items
+	case []string:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = item
+		}
+		return items
+	case map[string]interface{}:
+		r := FromMap(v)
+		for _, key := range r.keys {
+			r.values[key] = Normalize(r.values[key])
+		}
+		return r
+	case map[string]string:
+		r := New()
+		for _, key := range sortedStringKeys(v) {
+			r.Set(key, v[key])
+		}
+		return r
+	}
+	return fmt.Sprint(v)
+}
+
+// Format returns the text form of a value, as written to CSV files: null is
+// empty, numbers use the shortest exact form, timestamps use RFC 3339, bytes
+// are base64 encoded and arrays and objects are written as JSON.
+func Format(v interface{}) string {
+	switch v := Normalize(v).(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v)
+	case GeoPoint:
+		return fmt.Sprintf("%s,%s", strconv.FormatFloat(v.Latitude, 'f', -1, 64), strconv.FormatFloat(v.Longitude, 'f', -1, 64))
+	case Reference:
+		return v.Path
+	default:
+		// Arrays and nested objects
+		b, err := marshalValue(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(b)
+	}
+}
+
+// Helper function to parse a JSON number as int64 when it is an integer
+func parseNumber(s string) interface{} {
+	if !strings.ContainsAny(s, ".eE") {
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n
+		}
+	}
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
+
+// Helper function to return the keys of a string map in sorted order
+func sortedStringKeys(m map[string]string) []string {
+	values := make(map[string]interface{}, len(m))
+	for key := range m {
+		values[key] = nil
+	}
+	return FromMap(values).keys
+}
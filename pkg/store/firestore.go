@@ -0,0 +1,354 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"cloud.google.com/go/firestore"
+	"github.com/avii09/hookit/pkg/record"
+	"google.golang.org/api/iterator"
+	"google.golang.org/genproto/googleapis/type/latlng"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Firestore is a document store backed by a Firestore client.
+type Firestore struct {
+	client *firestore.Client
+}
+
+// NewFirestore returns a store that reads and writes through the client.
+// Closing the store closes the client.
+func NewFirestore(client *firestore.Client) *Firestore {
+	return &Firestore{client: client}
+}
+
+// List reads every document of a collection.
+func (s *Firestore) List(ctx context.Context, collection string) DocumentIterator {
+	return s.Query(ctx, collection, Query{})
+}
+
+// Query reads the documents of a collection, or collection group, that match
+// the query. With a page size, each page is a query of its own that starts
+// after the last document of the previous page, so that long reads are not
+// held open.
+func (s *Firestore) Query(ctx context.Context, collection string, q Query) DocumentIterator {
+	var query firestore.Query
+	if q.CollectionGroup {
+		group := s.client.CollectionGroup(collection)
+		if group == nil || strings.Contains(collection, "/") {
+			return errorIterator{fmt.Errorf("invalid collection group %q", collection)}
+		}
+		query = group.Query
+	} else {
+		ref := s.client.Collection(collection)
+		if ref == nil {
+			return errorIterator{fmt.Errorf("invalid collection path %q", collection)}
+		}
+		query = ref.Query
+	}
+
+	for _, filter := range q.Where {
+		query = query.Where(filter.Field, filter.Op, s.toFirestoreValue(filter.Value))
+	}
+	for _, order := range q.OrderBy {
+		direction := firestore.Asc
+		if order.Descending {
+			direction = firestore.Desc
+		}
+		query = query.OrderBy(order.Field, direction)
+	}
+	if len(q.Select) > 0 {
+		query = query.Select(q.Select...)
+	}
+
+	it := &firestoreIterator{ctx: ctx, query: query, pageSize: q.PageSize, limit: -1}
+	if q.Limit > 0 {
+		it.limit = q.Limit
+	}
+	it.iter = it.page().Documents(ctx)
+	return it
+}
+
+// Get reads a document. It returns nil when the document does not exist.
+func (s *Firestore) Get(ctx context.Context, path string) (*Document, error) {
+	ref := s.client.Doc(path)
+	if ref == nil {
+		return nil, fmt.Errorf("invalid document path %q", path)
+	}
+	snapshot, err := ref.Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return fromSnapshot(snapshot), nil
+}
+
+// Set creates or replaces a document.
+func (s *Firestore) Set(ctx context.Context, path string, data map[string]interface{}) error {
+	ref := s.client.Doc(path)
+	if ref == nil {
+		return fmt.Errorf("invalid document path %q", path)
+	}
+	_, err := ref.Set(ctx, s.toFirestore(data))
+	return err
+}
+
+// WriteBatch writes documents to a collection, either with a BulkWriter,
+// which sends the writes in parallel and retries them one by one, or in one
+// transaction when atomic.
+func (s *Firestore) WriteBatch(ctx context.Context, collection string, writes []Write, atomic bool) []error {
+	ref := s.client.Collection(collection)
+	if ref == nil {
+		return repeatError(fmt.Errorf("invalid collection path %q", collection), len(writes))
+	}
+	if atomic {
+		return s.writeTransaction(ctx, ref, writes)
+	}
+	return s.writeBulk(ctx, ref, writes)
+}
+
+// Helper function to write documents with a BulkWriter and wait for their results
+func (s *Firestore) writeBulk(ctx context.Context, collection *firestore.CollectionRef, writes []Write) []error {
+	bulk := s.client.BulkWriter(ctx)
+	errs := make([]error, len(writes))
+	jobs := make([]*firestore.BulkWriterJob, len(writes))
+	for i, w := range writes {
+		if errs[i] = checkWrite(w); errs[i] != nil {
+			continue
+		}
+		ref, data := documentRef(collection, w.ID), s.toFirestore(w.Data)
+		switch w.Mode {
+		case WriteAdd, WriteCreate:
+			jobs[i], errs[i] = bulk.Create(ref, data)
+		case WriteSet:
+			jobs[i], errs[i] = bulk.Set(ref, data)
+		case WriteMerge:
+			jobs[i], errs[i] = bulk.Set(ref, data, firestore.MergeAll)
+		case WriteUpdate:
+			jobs[i], errs[i] = bulk.Update(ref, fieldUpdates(data))
+		}
+	}
+	bulk.End()
+
+	failed := false
+	for i, job := range jobs {
+		if job != nil {
+			_, errs[i] = job.Results()
+		}
+		if errs[i] != nil {
+			failed = true
+		}
+	}
+	if !failed {
+		return nil
+	}
+	return errs
+}
+
+// Helper function to write documents in one transaction
+func (s *Firestore) writeTransaction(ctx context.Context, collection *firestore.CollectionRef, writes []Write) []error {
+	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		for _, w := range writes {
+			if err := checkWrite(w); err != nil {
+				return err
+			}
+			ref, data := documentRef(collection, w.ID), s.toFirestore(w.Data)
+			var err error
+			switch w.Mode {
+			case WriteAdd, WriteCreate:
+				err = tx.Create(ref, data)
+			case WriteSet:
+				err = tx.Set(ref, data)
+			case WriteMerge:
+				err = tx.Set(ref, data, firestore.MergeAll)
+			case WriteUpdate:
+				err = tx.Update(ref, fieldUpdates(data))
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return repeatError(err, len(writes))
+	}
+	return nil
+}
+
+// Close closes the Firestore client.
+func (s *Firestore) Close() error {
+	return s.client.Close()
+}
+
+// Helper function to return the reference of a document, generating an ID when it has none
+func documentRef(collection *firestore.CollectionRef, id string) *firestore.DocumentRef {
+	if id == "" {
+		return collection.NewDoc()
+	}
+	return collection.Doc(id)
+}
+
+// Helper function to update every top-level field of a document. The names
+// are used as they are, so a field named "a.b" is not read as a path.
+func fieldUpdates(data map[string]interface{}) []firestore.Update {
+	updates := make([]firestore.Update, 0, len(data))
+	for key, value := range data {
+		updates = append(updates, firestore.Update{FieldPath: firestore.FieldPath{key}, Value: value})
+	}
+	return updates
+}
+
+// firestoreIterator reads the documents of a Firestore query, a page at a time
+// when the query has a page size.
+type firestoreIterator struct {
+	ctx      context.Context
+	query    firestore.Query
+	iter     *firestore.DocumentIterator
+	pageSize int
+	limit    int // documents left to read, or -1 for no limit
+	inPage   int
+	last     *firestore.DocumentSnapshot
+}
+
+// Helper function to return the query for the next page
+func (it *firestoreIterator) page() firestore.Query {
+	query := it.query
+	if it.last != nil {
+		query = query.StartAfter(it.last)
+	}
+	size := it.pageSize
+	if it.limit >= 0 && (size == 0 || it.limit < size) {
+		size = it.limit
+	}
+	if size > 0 {
+		query = query.Limit(size)
+	}
+	return query
+}
+
+func (it *firestoreIterator) Next() (*Document, error) {
+	for {
+		if it.limit == 0 {
+			return nil, io.EOF
+		}
+		snapshot, err := it.iter.Next()
+		if err == iterator.Done {
+			// A full page may be followed by another one.
+			if it.pageSize > 0 && it.inPage == it.pageSize {
+				it.iter.Stop()
+				it.iter = it.page().Documents(it.ctx)
+				it.inPage = 0
+				continue
+			}
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, err
+		}
+		it.last = snapshot
+		it.inPage++
+		if it.limit > 0 {
+			it.limit--
+		}
+		return fromSnapshot(snapshot), nil
+	}
+}
+
+func (it *firestoreIterator) Stop() {
+	it.iter.Stop()
+}
+
+// errorIterator returns its error from every call to Next.
+type errorIterator struct {
+	err error
+}
+
+func (it errorIterator) Next() (*Document, error) {
+	return nil, it.err
+}
+
+func (it errorIterator) Stop() {}
+
+// Helper function to convert a document snapshot to a document
+func fromSnapshot(snapshot *firestore.DocumentSnapshot) *Document {
+	return &Document{Path: documentPath(snapshot.Ref), Data: fromFirestore(snapshot.Data())}
+}
+
+// Helper function to convert Firestore document data, mapping geopoints and
+// document references to their record types
+func fromFirestore(data map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		out[key] = fromFirestoreValue(value)
+	}
+	return out
+}
+
+// Helper function to convert a Firestore field value
+func fromFirestoreValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case *latlng.LatLng:
+		if v == nil {
+			return nil
+		}
+		return record.GeoPoint{Latitude: v.GetLatitude(), Longitude: v.GetLongitude()}
+	case *firestore.DocumentRef:
+		if v == nil {
+			return nil
+		}
+		return record.Reference{Path: documentPath(v)}
+	case map[string]interface{}:
+		return fromFirestore(v)
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = fromFirestoreValue(item)
+		}
+		return items
+	}
+	return value
+}
+
+// Helper function to convert document data to Firestore field values
+func (s *Firestore) toFirestore(data map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		out[key] = s.toFirestoreValue(value)
+	}
+	return out
+}
+
+// Helper function to convert a field value to a Firestore field value
+func (s *Firestore) toFirestoreValue(value interface{}) interface{} {
+	switch v := record.Normalize(value).(type) {
+	case record.GeoPoint:
+		return &latlng.LatLng{Latitude: v.Latitude, Longitude: v.Longitude}
+	case record.Reference:
+		return s.client.Doc(v.Path)
+	case *record.Record:
+		return s.toFirestore(v.ToMap())
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = s.toFirestoreValue(item)
+		}
+		return items
+	default:
+		return v
+	}
+}
+
+// Helper function to return the path of a document relative to the database
+// root, such as "users/alice"
+func documentPath(ref *firestore.DocumentRef) string {
+	if i := strings.Index(ref.Path, "/documents/"); i >= 0 {
+		return ref.Path[i+len("/documents/"):]
+	}
+	return ref.Path
+}
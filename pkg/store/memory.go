@@ -0,0 +1,561 @@
+package store
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/avii09/hookit/pkg/record"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Memory is a document store that keeps its documents in memory, so that
+// pipelines can be tested without Firestore. Queries follow Firestore's
+// rules: a filter or order on a field leaves out the documents without it,
+// values of different types never match a comparison and are ordered by
+// type, and documents are ordered by path after the query's own orders.
+// Writes fail with the same status codes as Firestore's.
+type Memory struct {
+	mu   sync.Mutex
+	docs map[string]*record.Record // by document path
+}
+
+// NewMemory returns an empty in-memory store.
+func NewMemory() *Memory {
+	return &Memory{docs: make(map[string]*record.Record)}
+}
+
+// List reads every document of a collection.
+func (s *Memory) List(ctx context.Context, collection string) DocumentIterator {
+	return s.Query(ctx, collection, Query{})
+}
+
+// Query reads the documents of a collection, or collection group, that match
+// the query. With a page size, each page is read when the one before it has
+// been, starting after its last document, as Firestore does, so documents
+// written during the read may be seen.
+func (s *Memory) Query(ctx context.Context, collection string, q Query) DocumentIterator {
+	if q.CollectionGroup && strings.Contains(collection, "/") {
+		return errorIterator{fmt.Errorf("invalid collection group %q", collection)}
+	}
+	if !q.CollectionGroup && !validPath(collection, 1) {
+		return errorIterator{fmt.Errorf("invalid collection path %q", collection)}
+	}
+	for _, filter := range q.Where {
+		if !isOperator(filter.Op) {
+			return errorIterator{fmt.Errorf("invalid operator %q", filter.Op)}
+		}
+	}
+
+	it := &memoryIterator{store: s, collection: collection, query: q, limit: -1}
+	if q.Limit > 0 {
+		it.limit = q.Limit
+	}
+	it.docs = it.page()
+	return it
+}
+
+// memoryMatch is a document that matches a query.
+type memoryMatch struct {
+	path string
+	data *record.Record
+}
+
+// Helper function to read the documents of a query, in order, that come after
+// the document after, if any, up to size documents, or all when size is 0
+func (s *Memory) queryPage(collection string, q Query, after *memoryMatch, size int) []memoryMatch {
+	var matches []memoryMatch
+	s.mu.Lock()
+	for path, data := range s.docs {
+		parent := path[:strings.LastIndexByte(path, '/')]
+		inCollection := parent == collection
+		if q.CollectionGroup {
+			inCollection = parent[strings.LastIndexByte(parent, '/')+1:] == collection
+		}
+		if inCollection && matchesQuery(data, q) {
+			m := memoryMatch{path, data}
+			if after == nil || compareMatches(q, *after, m) < 0 {
+				matches = append(matches, m)
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Slice(matches, func(i, j int) bool {
+		return compareMatches(q, matches[i], matches[j]) < 0
+	})
+	if size > 0 && len(matches) > size {
+		matches = matches[:size]
+	}
+	return matches
+}
+
+// Helper function to compare two documents in the order of a query. Ties are
+// broken by path, in the direction of the last order.
+func compareMatches(q Query, a, b memoryMatch) int {
+	for _, order := range q.OrderBy {
+		x, _ := a.data.Lookup(order.Field)
+		y, _ := b.data.Lookup(order.Field)
+		if c := compareValues(x, y); c != 0 {
+			if order.Descending {
+				return -c
+			}
+			return c
+		}
+	}
+	c := strings.Compare(a.path, b.path)
+	if len(q.OrderBy) > 0 && q.OrderBy[len(q.OrderBy)-1].Descending {
+		return -c
+	}
+	return c
+}
+
+// Get reads a document. It returns nil when the document does not exist.
+func (s *Memory) Get(ctx context.Context, path string) (*Document, error) {
+	if !validPath(path, 0) {
+		return nil, fmt.Errorf("invalid document path %q", path)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.docs[path]
+	if !ok {
+		return nil, nil
+	}
+	return &Document{Path: path, Data: plainData(data)}, nil
+}
+
+// Set creates or replaces a document.
+func (s *Memory) Set(ctx context.Context, path string, data map[string]interface{}) error {
+	if !validPath(path, 0) {
+		return fmt.Errorf("invalid document path %q", path)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.docs[path] = normalizeData(data)
+	return nil
+}
+
+// WriteBatch writes documents to a collection. Atomic batches are checked
+// against the documents as they are before being applied, so that a failed
+// write leaves every document of the batch unchanged.
+func (s *Memory) WriteBatch(ctx context.Context, collection string, writes []Write, atomic bool) []error {
+	if !validPath(collection, 1) {
+		return repeatError(fmt.Errorf("invalid collection path %q", collection), len(writes))
+	}
+	if err := ctx.Err(); err != nil {
+		return repeatError(err, len(writes))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	docs := s.docs
+	if atomic {
+		docs = make(map[string]*record.Record, len(s.docs))
+		for path, data := range s.docs {
+			docs[path] = data
+		}
+	}
+
+	errs := make([]error, len(writes))
+	failed := false
+	for i, w := range writes {
+		errs[i] = applyWrite(docs, collection, w)
+		if errs[i] == nil {
+			continue
+		}
+		if atomic {
+			return repeatError(errs[i], len(writes))
+		}
+		failed = true
+	}
+	if atomic {
+		s.docs = docs
+	}
+	if !failed {
+		return nil
+	}
+	return errs
+}
+
+// Close does nothing; the documents stay available.
+func (s *Memory) Close() error {
+	return nil
+}
+
+// Helper function to apply a write to the documents. Stored documents are
+// never changed in place, so an atomic batch can work on a copy of the map.
+func applyWrite(docs map[string]*record.Record, collection string, w Write) error {
+	if err := checkWrite(w); err != nil {
+		return err
+	}
+	if w.ID == "" {
+		w.ID = newDocumentID()
+	}
+	path := collection + "/" + w.ID
+	existing, exists := docs[path]
+	data := normalizeData(w.Data)
+
+	switch w.Mode {
+	case WriteAdd, WriteCreate:
+		if exists {
+			return status.Errorf(codes.AlreadyExists, "document already exists: %s", path)
+		}
+	case WriteMerge:
+		if exists {
+			merged := record.CopyValue(existing).(*record.Record)
+			mergeInto(merged, data)
+			data = merged
+		}
+	case WriteUpdate:
+		if !exists {
+			return status.Errorf(codes.NotFound, "no document to update: %s", path)
+		}
+		updated := record.CopyValue(existing).(*record.Record)
+		for _, key := range data.Keys() {
+			value, _ := data.Get(key)
+			updated.Set(key, value)
+		}
+		data = updated
+	}
+	docs[path] = data
+	return nil
+}
+
+// Helper function to merge the fields of src into dst, merging nested objects field by field
+func mergeInto(dst, src *record.Record) {
+	for _, key := range src.Keys() {
+		value, _ := src.Get(key)
+		current, _ := dst.Get(key)
+		from, ok1 := value.(*record.Record)
+		into, ok2 := current.(*record.Record)
+		if ok1 && ok2 {
+			mergeInto(into, from)
+			continue
+		}
+		dst.Set(key, value)
+	}
+}
+
+// Helper function to check that a path has an even number of segments for a
+// document (parity 0) or an odd number for a collection (parity 1), none empty
+func validPath(path string, parity int) bool {
+	segments := strings.Split(path, "/")
+	for _, segment := range segments {
+		if segment == "" {
+			return false
+		}
+	}
+	return len(segments)%2 == parity
+}
+
+// Helper function to check if op is a known operator
+func isOperator(op string) bool {
+	for _, known := range Operators {
+		if op == known {
+			return true
+		}
+	}
+	return false
+}
+
+// idAlphabet is the alphabet of generated document IDs, as used by Firestore.
+const idAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+// Helper function to generate a random 20-character document ID
+func newDocumentID() string {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	for i := range b {
+		b[i] = idAlphabet[int(b[i])%len(idAlphabet)]
+	}
+	return string(b)
+}
+
+// Helper function to copy document data into a record of normalized values
+func normalizeData(data map[string]interface{}) *record.Record {
+	return record.CopyValue(record.Normalize(data)).(*record.Record)
+}
+
+// Helper function to copy a stored document into plain document data
+func plainData(r *record.Record) map[string]interface{} {
+	data := make(map[string]interface{}, r.Len())
+	for _, key := range r.Keys() {
+		value, _ := r.Get(key)
+		data[key] = plainValue(value)
+	}
+	return data
+}
+
+// Helper function to copy a stored value into a plain value
+func plainValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case *record.Record:
+		return plainData(v)
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = plainValue(item)
+		}
+		return items
+	case []byte:
+		return append([]byte(nil), v...)
+	}
+	return value
+}
+
+// Helper function to keep only the selected fields of a document
+func selectFields(r *record.Record, fields []string) *record.Record {
+	selected := record.New()
+	for _, field := range fields {
+		if value, ok := r.Lookup(field); ok {
+			selected.SetPath(field, value)
+		}
+	}
+	return selected
+}
+
+// Helper function to check a document against the filters of a query and
+// check that it has the fields the query orders by
+func matchesQuery(r *record.Record, q Query) bool {
+	for _, order := range q.OrderBy {
+		if _, ok := r.Lookup(order.Field); !ok {
+			return false
+		}
+	}
+	for _, filter := range q.Where {
+		value, ok := r.Lookup(filter.Field)
+		if !ok || !matchesFilter(value, filter.Op, record.Normalize(filter.Value)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Helper function to compare a field value with the value of a filter
+func matchesFilter(value interface{}, op string, want interface{}) bool {
+	comparable := typeRank(value) == typeRank(want)
+	switch op {
+	case "==":
+		return comparable && compareValues(value, want) == 0
+	case "!=":
+		return value != nil && compareValues(value, want) != 0
+	case "<":
+		return comparable && compareValues(value, want) < 0
+	case "<=":
+		return comparable && compareValues(value, want) <= 0
+	case ">":
+		return comparable && compareValues(value, want) > 0
+	case ">=":
+		return comparable && compareValues(value, want) >= 0
+	case "in":
+		return containsValue(want, value)
+	case "not-in":
+		return value != nil && !containsValue(want, value)
+	case "array-contains":
+		return containsValue(value, want)
+	case "array-contains-any":
+		items, _ := want.([]interface{})
+		for _, item := range items {
+			if containsValue(value, item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Helper function to check if array is an array holding a value equal to value
+func containsValue(array, value interface{}) bool {
+	items, _ := array.([]interface{})
+	for _, item := range items {
+		if compareValues(item, value) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Helper function to rank a value by type, in Firestore's order of types
+func typeRank(v interface{}) int {
+	switch v.(type) {
+	case nil:
+		return 0
+	case bool:
+		return 1
+	case int64, float64:
+		return 2
+	case time.Time:
+		return 3
+	case string:
+		return 4
+	case []byte:
+		return 5
+	case record.Reference:
+		return 6
+	case record.GeoPoint:
+		return 7
+	case []interface{}:
+		return 8
+	}
+	return 9 // *record.Record
+}
+
+// Helper function to compare two normalized values in Firestore's order,
+// returning -1, 0 or 1. Values of different types are ordered by type;
+// integers and floats compare as numbers, with NaN before every other number.
+func compareValues(a, b interface{}) int {
+	if ra, rb := typeRank(a), typeRank(b); ra != rb {
+		return cmp.Compare(ra, rb)
+	}
+	switch a := a.(type) {
+	case nil:
+		return 0
+	case bool:
+		b := b.(bool)
+		switch {
+		case a == b:
+			return 0
+		case !a:
+			return -1
+		}
+		return 1
+	case int64, float64:
+		return compareNumbers(a, b)
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	case string:
+		return strings.Compare(a, b.(string))
+	case []byte:
+		return bytes.Compare(a, b.([]byte))
+	case record.Reference:
+		return strings.Compare(a.Path, b.(record.Reference).Path)
+	case record.GeoPoint:
+		b := b.(record.GeoPoint)
+		if c := cmp.Compare(a.Latitude, b.Latitude); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Longitude, b.Longitude)
+	case []interface{}:
+		b := b.([]interface{})
+		for i := 0; i < len(a) && i < len(b); i++ {
+			if c := compareValues(a[i], b[i]); c != 0 {
+				return c
+			}
+		}
+		return cmp.Compare(len(a), len(b))
+	case *record.Record:
+		// Objects compare field by field in key order.
+		b := b.(*record.Record)
+		aKeys, bKeys := sortedKeys(a), sortedKeys(b)
+		for i := 0; i < len(aKeys) && i < len(bKeys); i++ {
+			if c := strings.Compare(aKeys[i], bKeys[i]); c != 0 {
+				return c
+			}
+			av, _ := a.Get(aKeys[i])
+			bv, _ := b.Get(bKeys[i])
+			if c := compareValues(av, bv); c != 0 {
+				return c
+			}
+		}
+		return cmp.Compare(len(aKeys), len(bKeys))
+	}
+	return 0
+}
+
+// Helper function to compare two numbers, exactly when both are integers
+func compareNumbers(a, b interface{}) int {
+	ai, aInt := a.(int64)
+	bi, bInt := b.(int64)
+	if aInt && bInt {
+		return cmp.Compare(ai, bi)
+	}
+	return cmp.Compare(toFloat(a), toFloat(b))
+}
+
+// Helper function to convert an int64 or float64 to float64
+func toFloat(v interface{}) float64 {
+	if i, ok := v.(int64); ok {
+		return float64(i)
+	}
+	return v.(float64)
+}
+
+// Helper function to return the keys of a record in sorted order
+func sortedKeys(r *record.Record) []string {
+	keys := r.Keys()
+	sort.Strings(keys)
+	return keys
+}
+
+// memoryIterator returns the documents of an in-memory query, a page at a
+// time when the query has a page size.
+type memoryIterator struct {
+	store      *Memory
+	collection string
+	query      Query
+	docs       []*Document // the documents of the page left to return
+	limit      int         // documents left to read, or -1 for no limit
+	inPage     int
+	last       *memoryMatch
+	pages      int // the number of pages read
+}
+
+// Helper function to read the next page
+func (it *memoryIterator) page() []*Document {
+	size := it.query.PageSize
+	if it.limit >= 0 && (size == 0 || it.limit < size) {
+		size = it.limit
+	}
+	matches := it.store.queryPage(it.collection, it.query, it.last, size)
+	it.pages++
+	it.inPage = len(matches)
+	if len(matches) > 0 {
+		it.last = &matches[len(matches)-1]
+	}
+
+	docs := make([]*Document, len(matches))
+	for i, m := range matches {
+		data := m.data
+		if len(it.query.Select) > 0 {
+			data = selectFields(data, it.query.Select)
+		}
+		docs[i] = &Document{Path: m.path, Data: plainData(data)}
+	}
+	return docs
+}
+
+func (it *memoryIterator) Next() (*Document, error) {
+	if it.limit == 0 {
+		return nil, io.EOF
+	}
+	if len(it.docs) == 0 {
+		// A full page may be followed by another one.
+		if it.store == nil || it.query.PageSize == 0 || it.inPage < it.query.PageSize {
+			return nil, io.EOF
+		}
+		if it.docs = it.page(); len(it.docs) == 0 {
+			return nil, io.EOF
+		}
+	}
+	doc := it.docs[0]
+	it.docs = it.docs[1:]
+	if it.limit > 0 {
+		it.limit--
+	}
+	return doc, nil
+}
+
+func (it *memoryIterator) Stop() {
+	it.docs = nil
+	it.store = nil
+}
@@ -0,0 +1,187 @@
+package store
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/avii09/hookit/pkg/record"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Helper function to return a store holding a few documents of different shapes
+func testStore(t *testing.T) *Memory {
+	t.Helper()
+	s := NewMemory()
+	docs := map[string]map[string]interface{}{
+		"items/a":          {"n": 1, "tags": []interface{}{"red", "big"}, "owner": map[string]interface{}{"name": "ann"}},
+		"items/b":          {"n": 2.5, "tags": []interface{}{"blue"}},
+		"items/c":          {"n": "3", "owner": nil},
+		"items/d":          {"n": 3, "ref": record.Reference{Path: "users/ann"}},
+		"items/a/parts/p1": {"n": 10},
+		"other/e":          {"n": 1},
+	}
+	for path, data := range docs {
+		if err := s.Set(context.Background(), path, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+// Helper function to read the IDs of the documents of a query
+func queryIDs(t *testing.T, s DocumentStore, collection string, q Query) string {
+	t.Helper()
+	it := s.Query(context.Background(), collection, q)
+	defer it.Stop()
+	var ids []string
+	for {
+		doc, err := it.Next()
+		if err == io.EOF {
+			return strings.Join(ids, ",")
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, doc.ID())
+	}
+}
+
+func TestMemoryQuery(t *testing.T) {
+	s := testStore(t)
+	tests := []struct {
+		name string
+		q    Query
+		want string
+	}{
+		{"all", Query{}, "a,b,c,d"},
+		{"equal", Query{Where: []Filter{{"n", "==", 3}}}, "d"},
+		{"int equals float", Query{Where: []Filter{{"n", "==", 1.0}}}, "a"},
+		{"not equal skips missing and null", Query{Where: []Filter{{"owner", "!=", "x"}}}, "a"},
+		{"range keeps to the type", Query{Where: []Filter{{"n", ">=", 2}}}, "b,d"},
+		{"in", Query{Where: []Filter{{"n", "in", []interface{}{1, "3"}}}}, "a,c"},
+		{"not in", Query{Where: []Filter{{"n", "not-in", []interface{}{1, "3"}}}}, "b,d"},
+		{"array contains", Query{Where: []Filter{{"tags", "array-contains", "red"}}}, "a"},
+		{"array contains any", Query{Where: []Filter{{"tags", "array-contains-any", []interface{}{"blue", "big"}}}}, "a,b"},
+		{"nested field", Query{Where: []Filter{{"owner.name", "==", "ann"}}}, "a"},
+		{"reference", Query{Where: []Filter{{"ref", "==", record.Reference{Path: "users/ann"}}}}, "d"},
+		{"order by type then value", Query{OrderBy: []Order{{Field: "n"}}}, "a,b,d,c"},
+		{"order descending with limit", Query{OrderBy: []Order{{Field: "n", Descending: true}}, Limit: 2}, "c,d"},
+		{"order skips missing fields", Query{OrderBy: []Order{{Field: "tags"}}}, "b,a"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := queryIDs(t, s, "items", test.q); got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+
+	if got := queryIDs(t, s, "items/a/parts", Query{}); got != "p1" {
+		t.Errorf("subcollection: got %s, want p1", got)
+	}
+	if got := queryIDs(t, s, "parts", Query{CollectionGroup: true, Where: []Filter{{"n", ">", 5}}}); got != "p1" {
+		t.Errorf("collection group: got %s, want p1", got)
+	}
+
+	it := s.Query(context.Background(), "items", Query{Select: []string{"owner.name"}, Where: []Filter{{"n", "==", 1}}})
+	doc, err := it.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := record.Format(doc.Data); got != `{"owner":{"name":"ann"}}` {
+		t.Errorf("select: got %s", got)
+	}
+}
+
+func TestMemoryPaging(t *testing.T) {
+	var s DocumentStore = testStore(t)
+	queries := []Query{
+		{},
+		{OrderBy: []Order{{Field: "n"}}},
+		{OrderBy: []Order{{Field: "n", Descending: true}}, Limit: 3},
+		{Where: []Filter{{"n", "!=", 2.5}}, Limit: 2},
+	}
+	for _, q := range queries {
+		want := queryIDs(t, s, "items", q)
+		for _, pageSize := range []int{1, 2, 3, 10} {
+			q.PageSize = pageSize
+			// Paging gives the same documents as a single query
+			if got := queryIDs(t, s, "items", q); got != want {
+				t.Errorf("%+v: got %s, want %s", q, got, want)
+			}
+		}
+	}
+
+	// Each page is read when the one before it has been, starting after its
+	// last document
+	it := s.Query(context.Background(), "items", Query{OrderBy: []Order{{Field: "n"}}, PageSize: 2})
+	defer it.Stop()
+	var ids []string
+	for {
+		doc, err := it.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, doc.ID())
+		if len(ids) == 2 {
+			for id, n := range map[string]interface{}{"early": 0, "late": 2.7} {
+				if err := s.Set(context.Background(), "items/"+id, map[string]interface{}{"n": n}); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+	if got, want := strings.Join(ids, ","), "a,b,late,d,c"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if pages := it.(*memoryIterator).pages; pages != 3 {
+		t.Errorf("got %d pages, want 3", pages)
+	}
+}
+
+func TestMemoryWriteBatch(t *testing.T) {
+	ctx := context.Background()
+	s := testStore(t)
+
+	errs := s.WriteBatch(ctx, "items", []Write{
+		{Mode: WriteCreate, ID: "a", Data: map[string]interface{}{"n": 0}},
+		{Mode: WriteUpdate, ID: "zz", Data: map[string]interface{}{"n": 0}},
+		{Mode: WriteMerge, ID: "a", Data: map[string]interface{}{"owner": map[string]interface{}{"age": 30}}},
+		{Mode: WriteSet, Data: map[string]interface{}{"n": 0}},
+		{Mode: WriteAdd, Data: map[string]interface{}{"n": 4}},
+	}, false)
+	wantCodes := []codes.Code{codes.AlreadyExists, codes.NotFound, codes.OK, codes.Unknown, codes.OK}
+	for i, want := range wantCodes {
+		if got := status.Code(errs[i]); got != want {
+			t.Errorf("write %d: got %v (%v), want %v", i, got, errs[i], want)
+		}
+	}
+	a, _ := s.Get(ctx, "items/a")
+	if got := record.Format(a.Data["owner"]); got != `{"age":30,"name":"ann"}` {
+		t.Errorf("merged owner: got %s", got)
+	}
+	if got := queryIDs(t, s, "items", Query{Where: []Filter{{"n", "==", 4}}}); len(got) != 20 {
+		t.Errorf("added document ID %q is not generated", got)
+	}
+
+	// A failed atomic batch leaves every document unchanged
+	errs = s.WriteBatch(ctx, "items", []Write{
+		{Mode: WriteSet, ID: "b", Data: map[string]interface{}{"n": 0}},
+		{Mode: WriteUpdate, ID: "zz", Data: map[string]interface{}{"n": 0}},
+	}, true)
+	if status.Code(errs[0]) != codes.NotFound || status.Code(errs[1]) != codes.NotFound {
+		t.Errorf("atomic batch errors: %v", errs)
+	}
+	if b, _ := s.Get(ctx, "items/b"); b.Data["n"] != 2.5 {
+		t.Errorf("document b changed by a failed atomic batch: %v", b.Data)
+	}
+	if missing, err := s.Get(ctx, "items/zz"); missing != nil || err != nil {
+		t.Errorf("missing document: got %v, %v", missing, err)
+	}
+}
@@ -0,0 +1,156 @@
+// Package store defines the document store that Firebase inputs and outputs
+// read from and write to, with a Firestore implementation and an in-memory
+// one for tests.
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Document data holds plain Go values: nil, bool, int64, float64, string,
+// time.Time, []byte, []interface{}, map[string]interface{} for nested
+// objects, record.GeoPoint and record.Reference. Data given to a store may
+// also hold nested *record.Record values and other integer and float types.
+
+// DocumentStore reads and writes the documents of a Firestore-like database,
+// where documents live in collections and may have subcollections of their
+// own. Collections are named by their path, such as "orders" or
+// "orders/o1/items", and documents by theirs, such as "orders/o1".
+type DocumentStore interface {
+	// List reads every document of a collection.
+	List(ctx context.Context, collection string) DocumentIterator
+	// Query reads the documents of a collection, or of every collection with
+	// the name when q.CollectionGroup is set, that match the query.
+	Query(ctx context.Context, collection string, q Query) DocumentIterator
+	// Get reads a document. It returns nil when the document does not exist.
+	Get(ctx context.Context, path string) (*Document, error)
+	// Set creates or replaces a document.
+	Set(ctx context.Context, path string, data map[string]interface{}) error
+	// WriteBatch writes documents to a collection and returns the error of
+	// each, in order, or nil when every write succeeded. Atomic batches are
+	// written together or not at all, so their documents share the error.
+	WriteBatch(ctx context.Context, collection string, writes []Write, atomic bool) []error
+	// Close releases the connection to the store.
+	Close() error
+}
+
+// DocumentIterator returns the documents of a list or query one at a time.
+type DocumentIterator interface {
+	// Next returns the next document, or io.EOF after the last one.
+	Next() (*Document, error)
+	// Stop ends the query.
+	Stop()
+}
+
+// Document is a document read from a store.
+type Document struct {
+	Path string // relative to the database root, such as "users/alice"
+	Data map[string]interface{}
+}
+
+// ID returns the ID of the document, the last segment of its path.
+func (d *Document) ID() string {
+	return d.Path[strings.LastIndexByte(d.Path, '/')+1:]
+}
+
+// CollectionID returns the ID of the collection the document is in, such as
+// "items" for "orders/o1/items/i1".
+func (d *Document) CollectionID() string {
+	collection := d.Path[:strings.LastIndexByte(d.Path, '/')+1]
+	collection = strings.TrimSuffix(collection, "/")
+	return collection[strings.LastIndexByte(collection, '/')+1:]
+}
+
+// Operators lists the comparison operators of where clauses.
+var Operators = []string{"==", "!=", "<", "<=", ">", ">=", "in", "not-in", "array-contains", "array-contains-any"}
+
+// Query narrows down and orders the documents read from a collection. The
+// zero value reads every document.
+type Query struct {
+	CollectionGroup bool // read every collection with the name, wherever it is nested
+	Where           []Filter
+	OrderBy         []Order
+	Limit           int      // the most documents to read, or 0 for all
+	Select          []string // the fields to read, or every field when empty
+	PageSize        int      // read the documents in pages of this size, or in one query when 0
+}
+
+// Filter is a where clause, such as status == "shipped".
+type Filter struct {
+	Field string
+	Op    string
+	Value interface{}
+}
+
+// Order orders the documents by a field.
+type Order struct {
+	Field      string
+	Descending bool
+}
+
+// ParseOrder parses an order such as "created_at" or "created_at desc".
+func ParseOrder(s string) (Order, error) {
+	parts := strings.Fields(s)
+	switch {
+	case len(parts) == 1:
+		return Order{Field: parts[0]}, nil
+	case len(parts) == 2 && strings.EqualFold(parts[1], "asc"):
+		return Order{Field: parts[0]}, nil
+	case len(parts) == 2 && strings.EqualFold(parts[1], "desc"):
+		return Order{Field: parts[0], Descending: true}, nil
+	}
+	return Order{}, fmt.Errorf("invalid order %q (expected \"field\", \"field asc\" or \"field desc\")", s)
+}
+
+// Write modes of a batch write.
+const (
+	WriteAdd    = "add"    // add a document with a generated ID
+	WriteCreate = "create" // create a document, failing if it exists
+	WriteSet    = "set"    // create or replace a document
+	WriteMerge  = "merge"  // create a document or merge the fields into it
+	WriteUpdate = "update" // update the fields of a document, failing if it does not exist
+)
+
+// WriteModes lists the write modes in the order they are documented.
+var WriteModes = []string{WriteAdd, WriteCreate, WriteSet, WriteMerge, WriteUpdate}
+
+// MaxBatchSize is the largest number of writes Firestore commits atomically.
+const MaxBatchSize = 500
+
+// Write is a write of a batch. An empty ID gives the document a generated
+// one, which only the add and create modes allow.
+type Write struct {
+	Mode string
+	ID   string
+	Data map[string]interface{}
+}
+
+// Helper function to check the write mode and document ID of a write
+func checkWrite(w Write) error {
+	switch w.Mode {
+	case WriteAdd, WriteCreate, WriteSet, WriteMerge, WriteUpdate:
+	default:
+		return fmt.Errorf("unknown write mode %q", w.Mode)
+	}
+	if w.ID == "" {
+		if w.Mode != WriteAdd && w.Mode != WriteCreate {
+			return fmt.Errorf("write mode %q needs a document ID", w.Mode)
+		}
+		return nil
+	}
+	if strings.Contains(w.ID, "/") || w.ID == "." || w.ID == ".." {
+		return fmt.Errorf("invalid document ID %q", w.ID)
+	}
+	return nil
+}
+
+// Helper function to report the same error for every write of a batch
+func repeatError(err error, n int) []error {
+	errs := make([]error, n)
+	for i := range errs {
+		errs[i] = err
+	}
+	return errs
+}
@@ -0,0 +1,430 @@
+package transform
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/avii09/hookit/pkg/expr"
+	"github.com/avii09/hookit/pkg/record"
+)
+
+// Aggregation modes. In group mode one record is emitted per group, holding
+// the group_by fields and the aggregated values. In window mode every record
+// is kept and the aggregated values of its group are added to it.
+const (
+	AggregationModeGroup  = "group"
+	AggregationModeWindow = "window"
+)
+
+// aggregationOperations lists the supported values of AggregationRule.Operation.
+// Percentiles may also be written as "p" followed by the percentile, as in "p95".
+var aggregationOperations = []string{"sum", "count", "avg", "min", "max", "count_distinct", "median", "percentile"}
+
+// AggregationModeOrDefault returns the mode the rules run in: the configured mode, or
+// group mode when group_by is set and window mode otherwise.
+func (rules TransformationRules) AggregationModeOrDefault() string {
+	if rules.AggregationMode != "" {
+		return rules.AggregationMode
+	}
+	if len(rules.GroupBy) > 0 {
+		return AggregationModeGroup
+	}
+	return AggregationModeWindow
+}
+
+// Aggregate computes the aggregation rules over the records, grouped by the
+// groupBy fields (all records form one group when groupBy is empty). Columns,
+// group_by fields and result names may be paths into nested values.
+func Aggregate(data []*record.Record, groupBy []string, aggregations []AggregationRule, mode string) ([]*record.Record, error) {
+	for _, aggregation := range aggregations {
+		if err := ValidateAggregation(aggregation); err != nil {
+			return nil, err
+		}
+	}
+	if mode == "" {
+		mode = AggregationModeGroup
+	}
+
+	// Group records by their group_by values, in order of first appearance.
+	var order []string
+	groups := make(map[string][]*record.Record)
+	for _, r := range data {
+		key := groupKey(r, groupBy)
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], r)
+	}
+
+	// Expand "*" once over all records, so every group gets the same results.
+	var targets [][]aggregationTarget
+	numeric := numericColumns(data, groupBy)
+	for _, aggregation := range aggregations {
+		targets = append(targets, aggregationTargets(aggregation, numeric))
+	}
+
+	var result []*record.Record
+	for _, key := range order {
+		members := groups[key]
+		metrics := record.New()
+		for i, aggregation := range aggregations {
+			for _, target := range targets[i] {
+				metrics.Set(target.as, aggregateValues(aggregation, members, target.column))
+			}
+		}
+
+		switch mode {
+		case AggregationModeWindow:
+			for _, r := range members {
+				if err := setMetrics(r, metrics); err != nil {
+					return nil, err
+				}
+			}
+		default:
+			out := record.New()
+			for _, field := range groupBy {
+				value, _ := members[0].Lookup(field)
+				if err := out.SetPath(field, value); err != nil {
+					return nil, err
+				}
+			}
+			if err := setMetrics(out, metrics); err != nil {
+				return nil, err
+			}
+			result = append(result, out)
+		}
+	}
+
+	if mode == AggregationModeWindow {
+		return data, nil
+	}
+	return result, nil
+}
+
+// ValidateOperation checks that an aggregation operation is supported.
+func ValidateOperation(operation string) error {
+	if _, ok := percentileShorthand(operation); ok {
+		return nil
+	}
+	for _, op := range aggregationOperations {
+		if op == operation {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported operation %q (supported: %s, or pNN for a percentile)", operation, strings.Join(aggregationOperations, ", "))
+}
+
+// ValidateAggregation checks the operation of an aggregation rule and, for
+// percentiles, that the percentile is between 0 and 100.
+func ValidateAggregation(aggregation AggregationRule) error {
+	if err := ValidateOperation(aggregation.Operation); err != nil {
+		return err
+	}
+	if aggregation.Operation == "percentile" {
+		if aggregation.Percentile == nil {
+			return fmt.Errorf("operation \"percentile\" requires a \"percentile\" value between 0 and 100")
+		}
+		if p := *aggregation.Percentile; !(p >= 0 && p <= 100) {
+			return fmt.Errorf("percentile must be between 0 and 100, got %v", p)
+		}
+	}
+	return nil
+}
+
+// ValidateAggregationMode checks that an aggregation mode is supported.
+func ValidateAggregationMode(mode string) error {
+	switch mode {
+	case "", AggregationModeGroup, AggregationModeWindow:
+		return nil
+	}
+	return fmt.Errorf("unsupported aggregation mode %q (supported: %s, %s)", mode, AggregationModeGroup, AggregationModeWindow)
+}
+
+// aggregationTarget is a column to aggregate and the field that receives the result.
+type aggregationTarget struct {
+	column string
+	as     string
+}
+
+// Helper function to expand an aggregation over "*" into one target per numeric column
+func aggregationTargets(aggregation AggregationRule, numeric []string) []aggregationTarget {
+	if aggregation.Column != "*" {
+		return []aggregationTarget{{column: aggregation.Column, as: resultName(aggregation.As, aggregation.Column)}}
+	}
+
+	// count(*) counts records unless the result is named per column.
+	if aggregation.Operation == "count" && !namesColumn(aggregation.As) {
+		return []aggregationTarget{{column: "*", as: aggregation.As}}
+	}
+
+	var targets []aggregationTarget
+	for _, column := range numeric {
+		targets = append(targets, aggregationTarget{column: column, as: resultName(aggregation.As, column)})
+	}
+	return targets
+}
+
+// Helper function to list the numeric columns of the records other than the
+// group_by fields, in order of first appearance. A column is numeric when
+// every value it has, other than null, is a number or numeric text.
+func numericColumns(data []*record.Record, groupBy []string) []string {
+	var columns []string
+	numeric := make(map[string]bool)
+	for _, r := range data {
+		for _, column := range r.Keys() {
+			value, _ := r.Get(column)
+			isNumber, seen := numeric[column]
+			if !seen {
+				if containsString(groupBy, column) {
+					numeric[column] = false
+					continue
+				}
+				columns = append(columns, column)
+				isNumber = true
+			}
+			numeric[column] = isNumber && (value == nil || isNumericValue(value))
+		}
+	}
+
+	var result []string
+	for _, column := range columns {
+		if numeric[column] && hasValue(data, column) {
+			result = append(result, column)
+		}
+	}
+	return result
+}
+
+// Helper function to check if any record has a value other than null for a column
+func hasValue(data []*record.Record, column string) bool {
+	for _, r := range data {
+		if value, ok := r.Get(column); ok && value != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Helper function to set the aggregated values on a record
+func setMetrics(r *record.Record, metrics *record.Record) error {
+	for _, name := range metrics.Keys() {
+		value, _ := metrics.Get(name)
+		if err := r.SetPath(name, value); err != nil {
+			return fmt.Errorf("error setting aggregation result %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Helper function to compute one aggregation over the records of a group
+func aggregateValues(aggregation AggregationRule, members []*record.Record, column string) interface{} {
+	operation := aggregation.Operation
+	switch operation {
+	case "count":
+		count := 0
+		for _, r := range members {
+			if value, ok := r.Lookup(column); column == "*" || (ok && value != nil) {
+				count++
+			}
+		}
+		return int64(count)
+	case "count_distinct":
+		seen := make(map[string]bool)
+		for _, r := range members {
+			if value, ok := r.Lookup(column); ok && value != nil {
+				seen[record.Format(value)] = true
+			}
+		}
+		return int64(len(seen))
+	}
+
+	numbers := numericValues(members, column)
+	// sum, min and max of integer fields stay integers
+	ints := integerColumn(members, column)
+	if ints {
+		if result, ok := integerAggregate(operation, members, column); ok {
+			return result
+		}
+	}
+	if operation == "sum" {
+		sum := 0.0
+		for _, n := range numbers {
+			sum += n
+		}
+		return numberResult(sum, ints)
+	}
+	if len(numbers) == 0 {
+		return nil
+	}
+
+	switch operation {
+	case "avg":
+		sum := 0.0
+		for _, n := range numbers {
+			sum += n
+		}
+		return sum / float64(len(numbers))
+	case "min":
+		min := numbers[0]
+		for _, n := range numbers[1:] {
+			min = math.Min(min, n)
+		}
+		return numberResult(min, ints)
+	case "max":
+		max := numbers[0]
+		for _, n := range numbers[1:] {
+			max = math.Max(max, n)
+		}
+		return numberResult(max, ints)
+	case "median":
+		return percentile(numbers, 50)
+	case "percentile":
+		return percentile(numbers, *aggregation.Percentile)
+	}
+	if p, ok := percentileShorthand(operation); ok {
+		return percentile(numbers, p)
+	}
+	return nil
+}
+
+// Helper function to collect the numeric values of a column
+func numericValues(members []*record.Record, column string) []float64 {
+	var numbers []float64
+	for _, r := range members {
+		value, ok := r.Lookup(column)
+		if !ok {
+			continue
+		}
+		if n, ok := toNumber(value); ok {
+			numbers = append(numbers, n)
+		}
+	}
+	return numbers
+}
+
+// Helper function to check if every value of a column is an integer
+func integerColumn(members []*record.Record, column string) bool {
+	found := false
+	for _, r := range members {
+		value, ok := r.Lookup(column)
+		if !ok || value == nil {
+			continue
+		}
+		if _, ok := value.(int64); !ok {
+			return false
+		}
+		found = true
+	}
+	return found
+}
+
+// Helper function to compute the sum, min or max of an integer column
+// exactly. It reports false for other operations and when the sum overflows
+// int64.
+func integerAggregate(operation string, members []*record.Record, column string) (int64, bool) {
+	if operation != "sum" && operation != "min" && operation != "max" {
+		return 0, false
+	}
+	var result int64
+	found := false
+	for _, r := range members {
+		value, _ := r.Lookup(column)
+		n, ok := value.(int64)
+		if !ok {
+			continue
+		}
+		switch operation {
+		case "sum":
+			if (n > 0 && result > math.MaxInt64-n) || (n < 0 && result < math.MinInt64-n) {
+				return 0, false
+			}
+			result += n
+		case "min":
+			if !found || n < result {
+				result = n
+			}
+		case "max":
+			if !found || n > result {
+				result = n
+			}
+		}
+		found = true
+	}
+	return result, true
+}
+
+// Helper function to return an aggregated number as int64 for integer columns
+func numberResult(n float64, ints bool) interface{} {
+	if ints && n == math.Trunc(n) && math.Abs(n) < 1<<63 {
+		return int64(n)
+	}
+	return n
+}
+
+// Helper function to compute a percentile with linear interpolation between
+// the closest ranks
+func percentile(numbers []float64, p float64) float64 {
+	sorted := append([]float64(nil), numbers...)
+	sort.Float64s(sorted)
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	if lower == upper {
+		return sorted[lower]
+	}
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
+
+// Helper function to parse a percentile shorthand such as "p95"
+func percentileShorthand(operation string) (float64, bool) {
+	if len(operation) < 2 || operation[0] != 'p' {
+		return 0, false
+	}
+	p, err := strconv.ParseFloat(operation[1:], 64)
+	if err != nil || !(p >= 0 && p <= 100) {
+		return 0, false
+	}
+	return p, true
+}
+
+// Helper function to build the key that identifies a record's group
+func groupKey(r *record.Record, groupBy []string) string {
+	parts := make([]string, len(groupBy))
+	for i, field := range groupBy {
+		value, _ := r.Lookup(field)
+		parts[i] = fmt.Sprintf("%s:%s", record.KindOf(value), record.Format(value))
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// Helper function to convert a number or numeric string to float64
+func toNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case string:
+		return expr.ParseNumber(strings.TrimSpace(v))
+	}
+	return 0, false
+}
+
+// Helper function to check if a string is in a list
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,275 @@
+package transform
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/avii09/hookit/pkg/record"
+)
+
+// Helper function to build records from JSON objects
+func testRecords(t *testing.T, objects ...string) []*record.Record {
+	t.Helper()
+	records, err := record.DecodeJSON(strings.NewReader(strings.Join(objects, "\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+// Helper function to encode records as JSON lines for comparison
+func recordsJSON(t *testing.T, records []*record.Record) string {
+	t.Helper()
+	lines := make([]string, len(records))
+	for i, r := range records {
+		b, err := r.MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines[i] = string(b)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func percentileOf(p float64) *float64 {
+	return &p
+}
+
+func employees(t *testing.T) []*record.Record {
+	return testRecords(t,
+		`{"dept":"eng","salary":100,"rating":4.5}`,
+		`{"dept":"ops","salary":50,"rating":3.0}`,
+		`{"dept":"eng","salary":300,"rating":3.5}`,
+		`{"dept":"eng","salary":200,"rating":null}`,
+	)
+}
+
+func TestAggregateGroupMode(t *testing.T) {
+	got, err := Aggregate(employees(t), []string{"dept"}, []AggregationRule{
+		{Operation: "sum", Column: "salary", As: "total"},
+		{Operation: "min", Column: "salary", As: "lowest"},
+		{Operation: "max", Column: "rating", As: "best"},
+		{Operation: "avg", Column: "salary", As: "avg"},
+		{Operation: "count", Column: "*", As: "n"},
+		{Operation: "count", Column: "rating", As: "rated"},
+		{Operation: "count_distinct", Column: "rating", As: "ratings"},
+	}, AggregationModeGroup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"dept":"eng","total":600,"lowest":100,"best":4.5,"avg":200.0,"n":3,"rated":2,"ratings":2}
+{"dept":"ops","total":50,"lowest":50,"best":3.0,"avg":50.0,"n":1,"rated":1,"ratings":1}`
+	if got := recordsJSON(t, got); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestAggregateWindowMode(t *testing.T) {
+	got, err := Aggregate(employees(t), []string{"dept"}, []AggregationRule{
+		{Operation: "sum", Column: "salary", As: "dept_total"},
+	}, AggregationModeWindow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"dept":"eng","salary":100,"rating":4.5,"dept_total":600}
+{"dept":"ops","salary":50,"rating":3.0,"dept_total":50}
+{"dept":"eng","salary":300,"rating":3.5,"dept_total":600}
+{"dept":"eng","salary":200,"rating":null,"dept_total":600}`
+	if got := recordsJSON(t, got); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestAggregatePercentiles(t *testing.T) {
+	data := testRecords(t, `{"v":1}`, `{"v":2}`, `{"v":3}`, `{"v":4}`, `{"v":"10"}`)
+	got, err := Aggregate(data, nil, []AggregationRule{
+		{Operation: "median", Column: "v", As: "median"},
+		{Operation: "p25", Column: "v", As: "p25"},
+		{Operation: "p90", Column: "v", As: "p90"},
+		{Operation: "percentile", Percentile: percentileOf(100), Column: "v", As: "max"},
+		{Operation: "percentile", Percentile: percentileOf(0), Column: "v", As: "min"},
+	}, AggregationModeGroup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"median":3.0,"p25":2.0,"p90":7.6000000000000005,"max":10.0,"min":1.0}`
+	if got := recordsJSON(t, got); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestAggregateKeepsIntegers(t *testing.T) {
+	tests := []struct {
+		values string
+		want   string
+	}{
+		{`{"v":2} {"v":5}`, `{"sum":7,"min":2,"max":5}`},
+		{`{"v":2} {"v":5.5}`, `{"sum":7.5,"min":2.0,"max":5.5}`},
+		{`{"v":"2"} {"v":"5"}`, `{"sum":7.0,"min":2.0,"max":5.0}`},
+		{`{"v":2} {"v":null} {"x":1}`, `{"sum":2,"min":2,"max":2}`},
+		{`{"v":9007199254740993} {"v":2}`, `{"sum":9007199254740995,"min":2,"max":9007199254740993}`},
+		{`{"v":9223372036854775807} {"v":-1}`, `{"sum":9223372036854775806,"min":-1,"max":9223372036854775807}`},
+		// The sum overflows int64, so it is a float
+		{`{"v":9223372036854775807} {"v":1}`, `{"sum":9223372036854776000.0,"min":1,"max":9223372036854775807}`},
+	}
+	for _, test := range tests {
+		got, err := Aggregate(testRecords(t, test.values), nil, []AggregationRule{
+			{Operation: "sum", Column: "v", As: "sum"},
+			{Operation: "min", Column: "v", As: "min"},
+			{Operation: "max", Column: "v", As: "max"},
+		}, AggregationModeGroup)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := recordsJSON(t, got); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.values, got, test.want)
+		}
+	}
+}
+
+func TestAggregateEmptyGroups(t *testing.T) {
+	rules := []AggregationRule{
+		{Operation: "sum", Column: "v", As: "sum"},
+		{Operation: "avg", Column: "v", As: "avg"},
+		{Operation: "min", Column: "v", As: "min"},
+		{Operation: "median", Column: "v", As: "median"},
+		{Operation: "count", Column: "v", As: "count"},
+	}
+	for _, mode := range []string{AggregationModeGroup, AggregationModeWindow} {
+		got, err := Aggregate(nil, nil, rules, mode)
+		if err != nil || len(got) != 0 {
+			t.Errorf("%s mode with no records: got %v, %v", mode, got, err)
+		}
+	}
+
+	// A group without values for the column
+	got, err := Aggregate(testRecords(t, `{"k":"a","v":null}`, `{"k":"a"}`), []string{"k"}, rules, AggregationModeGroup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"k":"a","sum":0.0,"avg":null,"min":null,"median":null,"count":0}`
+	if got := recordsJSON(t, got); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestAggregateAllColumns(t *testing.T) {
+	// Numeric columns are found across all records, not just the first
+	data := testRecords(t,
+		`{"k":"a","bonus":null,"code":"7","n":1}`,
+		`{"k":"b","bonus":5,"code":"A1","n":2,"extra":"3"}`,
+	)
+	got, err := Aggregate(data, []string{"k"}, []AggregationRule{
+		{Operation: "sum", Column: "*", As: "sum_<column>"},
+		{Operation: "max", Column: "*", As: "max_<key>"},
+	}, AggregationModeGroup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"k":"a","sum_bonus":0.0,"sum_n":1,"sum_extra":0.0,"max_bonus":null,"max_n":1,"max_extra":null}
+{"k":"b","sum_bonus":5,"sum_n":2,"sum_extra":3.0,"max_bonus":5,"max_n":2,"max_extra":3.0}`
+	if got := recordsJSON(t, got); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestAggregateErrors(t *testing.T) {
+	if err := ValidateAggregation(AggregationRule{Operation: "percentile", Column: "v", As: "p"}); err == nil {
+		t.Error("percentile without a percentile value: expected an error")
+	}
+	if err := ValidateAggregation(AggregationRule{Operation: "percentile", Percentile: percentileOf(101), Column: "v", As: "p"}); err == nil {
+		t.Error("percentile above 100: expected an error")
+	}
+	if err := ValidateAggregation(AggregationRule{Operation: "percentile", Percentile: percentileOf(math.NaN()), Column: "v", As: "p"}); err == nil {
+		t.Error("NaN percentile: expected an error")
+	}
+	for _, operation := range []string{"pNaN", "pnan", "p101", "p-1"} {
+		if err := ValidateAggregation(AggregationRule{Operation: operation, Column: "v", As: "p"}); err == nil {
+			t.Errorf("%s: expected an error", operation)
+		}
+	}
+	if err := ValidateAggregation(AggregationRule{Operation: "mode", Column: "v", As: "m"}); err == nil {
+		t.Error("unsupported operation: expected an error")
+	}
+
+	// A result path through a scalar field cannot be set
+	_, err := Aggregate(testRecords(t, `{"name":"x","v":1}`), nil, []AggregationRule{
+		{Operation: "sum", Column: "v", As: "name.total"},
+	}, AggregationModeWindow)
+	if err == nil || !strings.Contains(err.Error(), `"name.total"`) {
+		t.Errorf("result path through a scalar: got %v", err)
+	}
+}
+
+func TestCompatibilityWrappers(t *testing.T) {
+	// The CSV, text and JSON entry points give the same results, and name
+	// results with either placeholder. The default window mode adds the
+	// results to each record.
+	csvFile := filepath.Join(t.TempDir(), "in.csv")
+	if err := os.WriteFile(csvFile, []byte("Dept,Salary\neng,100\nops,5\neng,300\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	want := "[map[dept:eng salary:100 salary_max:300 total_salary:400] map[dept:eng salary:300 salary_max:300 total_salary:400]]"
+
+	csvRules := CSVTransformationRules{
+		Filter:  []CSVFilterRule{{Column: "Salary", Condition: "> 10"}},
+		Mapping: CSVMappingRules{DynamicMapping: true},
+		Aggregation: []CSVAggregationRule{
+			{Operation: "sum", Column: "salary", As: "total_<column>"},
+			{Operation: "max", Column: "salary", As: "<key>_max"},
+		},
+	}
+	fromFile, err := ApplyCSVTransformations(csvFile, csvRules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(fromFile); got != want {
+		t.Errorf("ApplyCSVTransformations: got %s, want %s", got, want)
+	}
+	// The CSV values stay text, the results are numbers
+	if got, want := string(CSVToJSON(fromFile)), `[{"dept":"eng","salary":"100","salary_max":300,"total_salary":400},{"dept":"eng","salary":"300","salary_max":300,"total_salary":400}]`; got != want {
+		t.Errorf("CSVToJSON: got %s, want %s", got, want)
+	}
+
+	rows := []map[string]string{{"Dept": "eng", "Salary": "100"}, {"Dept": "ops", "Salary": "5"}, {"Dept": "eng", "Salary": "300"}}
+	fromText, err := ApplyTransformations(rows, csvRules.Rules())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(fromText); got != want {
+		t.Errorf("ApplyTransformations: got %s, want %s", got, want)
+	}
+
+	jsonRules := JSONTransformationRules{
+		Filter:  []JSONFilterRule{{Key: "Salary", Condition: "> 10"}},
+		Mapping: JSONMappingRules{DynamicMapping: true},
+		Aggregation: []JSONAggregationRule{
+			{Operation: "sum", Key: "salary", As: "total_<column>"},
+			{Operation: "max", Key: "salary", As: "<key>_max"},
+		},
+	}
+	fromJSON, err := ApplyJSONTransformations([]map[string]interface{}{
+		{"Dept": "eng", "Salary": int64(100)}, {"Dept": "ops", "Salary": int64(5)}, {"Dept": "eng", "Salary": int64(300)},
+	}, jsonRules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(fromJSON); got != want {
+		t.Errorf("ApplyJSONTransformations: got %s, want %s", got, want)
+	}
+	if got := fromJSON[0]["total_salary"]; got != int64(400) {
+		t.Errorf("ApplyJSONTransformations: got a total of %v (%T), want int64 400", got, got)
+	}
+
+	fromAggregations, err := ApplyAggregations(rows, []AggregationRule{{Operation: "count", Column: "*", As: "rows"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fmt.Sprint(fromAggregations), "[map[Dept:eng Salary:100 rows:3] map[Dept:ops Salary:5 rows:3] map[Dept:eng Salary:300 rows:3]]"; got != want {
+		t.Errorf("ApplyAggregations: got %s, want %s", got, want)
+	}
+}
@@ -1,15 +1,28 @@
 package transform
 
 import (
-	"strconv"
 	"strings"
+
+	"github.com/avii09/hookit/pkg/expr"
+	"github.com/avii09/hookit/pkg/record"
 )
 
 // Define transformation rules structures
 type TransformationRules struct {
-	Filter      []FilterRule      `yaml:"filter"`
-	Mapping     MappingRules      `yaml:"mapping"`
-	Aggregation []AggregationRule `yaml:"aggregation"`
+	Filter           []FilterRule `yaml:"filter"`
+	Mapping          MappingRules `yaml:"mapping"`
+	Set              []SetRule    `yaml:"set"`
+	AggregationRules `yaml:",inline"`
+	Flatten          FlattenRules `yaml:"flatten"`
+	Unflatten        FlattenRules `yaml:"unflatten"`
+}
+
+// AggregationRules are the aggregations, the fields to group by and the
+// aggregation mode.
+type AggregationRules struct {
+	Aggregation     []AggregationRule `yaml:"aggregation"`
+	GroupBy         []string          `yaml:"group_by"`
+	AggregationMode string            `yaml:"aggregation_mode"`
 }
 
 type FilterRule struct {
@@ -21,146 +34,75 @@ type MappingRules struct {
 	NameChange     string            `yaml:"name_change"`
 	AddField       map[string]string `yaml:"add_field"`
 	DynamicMapping bool              `yaml:"dynamic_mapping"`
+	Case           string            `yaml:"case"`
+	CustomMapping  []FieldMapping    `yaml:"custom_mapping"`
+	Copy           []FieldMapping    `yaml:"copy"`
+	Drop           []string          `yaml:"drop"`
+	Keep           []string          `yaml:"keep"`
+	Order          []string          `yaml:"order"`
 }
 
-type AggregationRule struct {
-	Operation string `yaml:"operation"`
-	Column    string `yaml:"column"`
-	As        string `yaml:"as"`
-}
-
-// ApplyTransformations applies all transformations to the data
-func ApplyTransformations(data []map[string]string, rules TransformationRules) []map[string]string {
-	// Apply Filters
-	data = applyFilters(data, rules.Filter)
-
-	// Apply Dynamic Mapping
-	if rules.Mapping.DynamicMapping {
-		data = applyDynamicMapping(data)
-	}
-
-	// Apply Aggregations
-	data = applyAggregations(data, rules.Aggregation)
-
-	return data
+// IsZero reports whether the mapping rules change nothing.
+func (m MappingRules) IsZero() bool {
+	return m.NameChange == "" && len(m.AddField) == 0 && !m.DynamicMapping && m.Case == "" &&
+		len(m.CustomMapping) == 0 && len(m.Copy) == 0 && len(m.Drop) == 0 && len(m.Keep) == 0 && len(m.Order) == 0
 }
 
-// Helper function to apply filters
-func applyFilters(data []map[string]string, filters []FilterRule) []map[string]string {
-	var filteredData []map[string]string
-
-	for _, row := range data {
-		includeRow := true
-		for _, filter := range filters {
-			column := filter.Column
-			condition := filter.Condition
-
-			// Check if column exists
-			if value, exists := row[column]; exists || column == "*" {
-				// Apply numeric conditions if the value is a number
-				if isNumeric(value) {
-					numValue, _ := strconv.ParseFloat(value, 64)
-					includeRow = includeRow && checkCondition(numValue, condition)
-				}
-			}
-		}
-		if includeRow {
-			filteredData = append(filteredData, row)
-		}
-	}
-
-	return filteredData
+type AggregationRule struct {
+	Operation  string   `yaml:"operation"`
+	Column     string   `yaml:"column"`
+	As         string   `yaml:"as"`
+	Percentile *float64 `yaml:"percentile"` // required by the "percentile" operation
 }
 
-// Helper function to apply dynamic mapping (e.g., lowercase column names)
-func applyDynamicMapping(data []map[string]string) []map[string]string {
-	var mappedData []map[string]string
-
-	for _, row := range data {
-		mappedRow := make(map[string]string)
-		for column, value := range row {
-			mappedRow[strings.ToLower(column)] = value
-		}
-		mappedData = append(mappedData, mappedRow)
+// ApplyTransformations applies all transformations to rows of text, such as
+// CSV rows. Numeric text counts as a number in filters and aggregations, and
+// results are converted back to text.
+func ApplyTransformations(data []map[string]string, rules TransformationRules) ([]map[string]string, error) {
+	records := make([]*record.Record, len(data))
+	for i, row := range data {
+		records[i] = stringRowToRecord(row)
 	}
 
-	return mappedData
-}
-
-// Helper function to apply aggregations
-func applyAggregations(data []map[string]string, aggregations []AggregationRule) []map[string]string {
-	if len(data) == 0 {
-		return data
+	records, err := ApplyRecords(records, rules)
+	if err != nil {
+		return nil, err
 	}
 
-	// Calculate aggregations
-	for _, aggregation := range aggregations {
-		operation := aggregation.Operation
-		column := aggregation.Column
-		newColumn := aggregation.As
-
-		if column == "*" { // Apply to all numeric columns
-			for key := range data[0] {
-				if isNumeric(data[0][key]) {
-					data = applySingleAggregation(data, operation, key, newColumn)
-				}
-			}
-		} else { // Apply to specific column
-			data = applySingleAggregation(data, operation, column, newColumn)
+	var transformed []map[string]string
+	for _, r := range records {
+		row := make(map[string]string, r.Len())
+		for _, key := range r.Keys() {
+			value, _ := r.Get(key)
+			row[key] = record.Format(value)
 		}
+		transformed = append(transformed, row)
 	}
-
-	return data
+	return transformed, nil
 }
 
-// Helper function to apply a single aggregation operation
-func applySingleAggregation(data []map[string]string, operation, column, newColumnPattern string) []map[string]string {
-	aggregateValue := 0.0
-	count := 0
-
-	// Compute aggregation
-	for _, row := range data {
-		if value, exists := row[column]; exists && isNumeric(value) {
-			numValue, _ := strconv.ParseFloat(value, 64)
-			switch operation {
-			case "sum":
-				aggregateValue += numValue
-			case "count":
-				count++
-			}
-		}
-	}
-
-	// Add the aggregation result to each row
-	for i := range data {
-		if operation == "sum" {
-			data[i][strings.Replace(newColumnPattern, "<column>", column, 1)] = strconv.FormatFloat(aggregateValue, 'f', 2, 64)
-		} else if operation == "count" {
-			data[i][strings.Replace(newColumnPattern, "<column>", column, 1)] = strconv.Itoa(count)
-		}
-	}
+// MatchesFilters reports whether a single row satisfies every filter rule.
+func MatchesFilters(row map[string]string, filters []FilterRule) bool {
+	return matchAll(pathFields{stringRowToRecord(row)}, compileFilters(filters))
+}
 
-	return data
+// ApplyAggregations applies the aggregation rules to the data
+func ApplyAggregations(data []map[string]string, aggregations []AggregationRule) ([]map[string]string, error) {
+	return ApplyTransformations(data, TransformationRules{AggregationRules: AggregationRules{Aggregation: aggregations}})
 }
 
-// Helper function to check numeric condition
-func checkCondition(value float64, condition string) bool {
-	condition = strings.TrimSpace(condition)
-	if strings.HasPrefix(condition, ">") {
-		threshold, _ := strconv.ParseFloat(strings.TrimSpace(condition[1:]), 64)
-		return value > threshold
-	} else if strings.HasPrefix(condition, "<") {
-		threshold, _ := strconv.ParseFloat(strings.TrimSpace(condition[1:]), 64)
-		return value < threshold
-	} else if strings.HasPrefix(condition, "=") {
-		threshold, _ := strconv.ParseFloat(strings.TrimSpace(condition[1:]), 64)
-		return value == threshold
+// Helper function to convert a string row to a record
+func stringRowToRecord(row map[string]string) *record.Record {
+	fields := make(map[string]interface{}, len(row))
+	for key, value := range row {
+		fields[key] = value
 	}
-	return true
+	return record.FromMap(fields)
 }
 
-// Helper function to check if a string is numeric
+// Helper function to check if a string is a finite decimal number, as
+// expressions treat it
 func isNumeric(value string) bool {
-	_, err := strconv.ParseFloat(value, 64)
-	return err == nil
+	_, ok := expr.ParseNumber(strings.TrimSpace(value))
+	return ok
 }
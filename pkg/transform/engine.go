@@ -0,0 +1,118 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/avii09/hookit/pkg/record"
+)
+
+// Stages lists the transformation stages in the order they are applied when
+// the transformations are not written as a list of steps. Pipelines build
+// their stages from it too.
+var Stages = []string{"filter", "mapping", "set", "aggregation", "flatten", "unflatten"}
+
+// ApplyRecords applies the transformation rules to records, stage by stage in
+// the order of Stages. Rules that do not parse, and records that fail a set
+// rule, are reported as errors.
+func ApplyRecords(data []*record.Record, rules TransformationRules) ([]*record.Record, error) {
+	data = append([]*record.Record(nil), data...)
+	for _, stage := range Stages {
+		var err error
+		if data, err = applyStage(stage, data, rules); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// Helper function to apply the rules of one stage to records
+func applyStage(stage string, data []*record.Record, rules TransformationRules) ([]*record.Record, error) {
+	switch stage {
+	case "filter":
+		if len(rules.Filter) == 0 {
+			return data, nil
+		}
+		filters, err := compileFilterRules(rules.Filter)
+		if err != nil {
+			return nil, err
+		}
+		var kept []*record.Record
+		for _, r := range data {
+			if matchAll(r, filters) {
+				kept = append(kept, r)
+			}
+		}
+		return kept, nil
+	case "mapping":
+		if rules.Mapping.IsZero() {
+			return data, nil
+		}
+		for i, r := range data {
+			mapped, err := MapRecord(r, rules.Mapping)
+			if err != nil {
+				return nil, err
+			}
+			data[i] = mapped
+		}
+	case "set":
+		for _, rule := range rules.Set {
+			setter, err := CompileSet(rule)
+			if err != nil {
+				return nil, fmt.Errorf("invalid set rule for %q: %w", rule.Field, err)
+			}
+			for _, r := range data {
+				if err := setter.Apply(r); err != nil {
+					return nil, err
+				}
+			}
+		}
+	case "aggregation":
+		if len(rules.Aggregation) == 0 && len(rules.GroupBy) == 0 {
+			return data, nil
+		}
+		return Aggregate(data, rules.GroupBy, rules.Aggregation, rules.AggregationModeOrDefault())
+	case "flatten":
+		if rules.Flatten.Enabled {
+			for i, r := range data {
+				data[i] = FlattenRecord(r, rules.Flatten)
+			}
+		}
+	case "unflatten":
+		if rules.Unflatten.Enabled {
+			for i, r := range data {
+				data[i] = UnflattenRecord(r, rules.Unflatten)
+			}
+		}
+	}
+	return data, nil
+}
+
+// Helper function to convert maps to records with sorted fields
+func recordsFromMaps(data []map[string]interface{}) []*record.Record {
+	records := make([]*record.Record, len(data))
+	for i, row := range data {
+		records[i] = record.FromMap(row)
+	}
+	return records
+}
+
+// Helper function to convert records back to maps
+func recordsToMaps(records []*record.Record) []map[string]interface{} {
+	data := make([]map[string]interface{}, len(records))
+	for i, r := range records {
+		data[i] = r.ToMap()
+	}
+	return data
+}
+
+// Helper function to fill in the aggregated column in a result name. Names
+// may refer to the column as "<column>", or as "<key>" like the JSON rules did.
+func resultName(as, column string) string {
+	return strings.Replace(strings.ReplaceAll(as, "<key>", "<column>"), "<column>", column, 1)
+}
+
+// Helper function to check if a result name refers to the aggregated column
+func namesColumn(as string) bool {
+	return strings.Contains(as, "<column>") || strings.Contains(as, "<key>")
+}
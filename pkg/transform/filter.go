@@ -0,0 +1,126 @@
+package transform
+
+import (
+	"fmt"
+
+	"github.com/avii09/hookit/pkg/expr"
+	"github.com/avii09/hookit/pkg/record"
+)
+
+// Filter is a compiled filter rule.
+type Filter struct {
+	column    string
+	condition *expr.Expr
+}
+
+// CompileFilter parses the condition of a filter rule.
+//
+// With a column, the condition may leave out its left operand to compare that
+// column ("> 25", "in ['a', 'b']", "is not null"), and the column "*" applies
+// the condition to every numeric column. Without a column, the condition is a
+// full expression over the record's fields, such as
+// "age > 25 and status == 'active'".
+func CompileFilter(column, condition string) (*Filter, error) {
+	cond, err := expr.CompileCondition(condition)
+	if err != nil {
+		return nil, err
+	}
+	return &Filter{column: column, condition: cond}, nil
+}
+
+// Fields is a record whose fields can be listed and read, such as a
+// *record.Record or an expr.Map.
+type Fields interface {
+	Get(name string) (interface{}, bool)
+	Keys() []string
+}
+
+// Match reports whether a record satisfies the filter. A filter on a named
+// column keeps records that do not have that column. Columns and field names
+// in the condition may be paths into nested values, such as "address.city".
+func (f *Filter) Match(row Fields) bool {
+	if r, ok := row.(*record.Record); ok {
+		row = pathFields{r}
+	}
+	switch f.column {
+	case "":
+		return f.condition.Match(row)
+	case "*":
+		for _, key := range row.Keys() {
+			value, _ := row.Get(key)
+			if isNumericValue(value) && !expr.Truthy(f.condition.EvalSubject(row, value)) {
+				return false
+			}
+		}
+		return true
+	}
+
+	value, exists := row.Get(f.column)
+	if !exists {
+		return true
+	}
+	return expr.Truthy(f.condition.EvalSubject(row, value))
+}
+
+// String describes the filter, as in "age > 25".
+func (f *Filter) String() string {
+	if f.column == "" {
+		return f.condition.String()
+	}
+	return fmt.Sprintf("%s %s", f.column, f.condition)
+}
+
+// pathFields resolves field names as paths into the nested values of a record.
+type pathFields struct {
+	*record.Record
+}
+
+func (p pathFields) Get(name string) (interface{}, bool) {
+	return p.Lookup(name)
+}
+
+// Helper function to compile filter rules, skipping any that do not parse.
+// Configs are validated when loaded, so this only drops rules built in code.
+func compileFilters(rules []FilterRule) []*Filter {
+	var filters []*Filter
+	for _, rule := range rules {
+		if filter, err := CompileFilter(rule.Column, rule.Condition); err == nil {
+			filters = append(filters, filter)
+		}
+	}
+	return filters
+}
+
+// Helper function to compile filter rules, failing on the first that does not parse
+func compileFilterRules(rules []FilterRule) ([]*Filter, error) {
+	var filters []*Filter
+	for _, rule := range rules {
+		filter, err := CompileFilter(rule.Column, rule.Condition)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter condition %q: %w", rule.Condition, err)
+		}
+		filters = append(filters, filter)
+	}
+	return filters, nil
+}
+
+// Helper function to check a record against every filter
+func matchAll(row Fields, filters []*Filter) bool {
+	for _, filter := range filters {
+		if !filter.Match(row) {
+			return false
+		}
+	}
+	return true
+}
+
+// Helper function to check if a value is a number or a numeric string
+func isNumericValue(value interface{}) bool {
+	switch v := value.(type) {
+	case float64, float32, int, int64, int32:
+		return true
+	case string:
+		return isNumeric(v)
+	}
+	return false
+}
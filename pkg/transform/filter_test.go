@@ -0,0 +1,116 @@
+package transform
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/avii09/hookit/pkg/record"
+)
+
+func TestFilterMatch(t *testing.T) {
+	r := record.FromMap(map[string]interface{}{
+		"name":  "Nan",
+		"score": "12",
+		"age":   int64(30),
+		"note":  "Infinity",
+		"city":  record.FromMap(map[string]interface{}{"name": "Pune"}),
+	})
+	tests := []struct {
+		column    string
+		condition string
+		want      bool
+	}{
+		{"*", "> 10", true},
+		{"*", "> 20", false},
+		{"age", ">= 30", true},
+		{"missing", "> 100", true},
+		{"city.name", "== 'Pune'", true},
+		{"", "name == 'Nan' and score > 10", true},
+		{"", "note > 1", false},
+	}
+	for _, test := range tests {
+		f, err := CompileFilter(test.column, test.condition)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := f.Match(r); got != test.want {
+			t.Errorf("%s: got %v, want %v", f, got, test.want)
+		}
+	}
+}
+
+func TestNumericColumns(t *testing.T) {
+	// Text that parses as a number counts as one, other text does not, and
+	// integers count as numbers as well as floats
+	filters := []FilterRule{{Column: "*", Condition: "> 10"}}
+	tests := []struct {
+		row  map[string]string
+		want bool
+	}{
+		{map[string]string{"name": "abc", "score": "12"}, true},
+		{map[string]string{"name": "abc", "score": " 12.5 "}, true},
+		{map[string]string{"name": "abc", "score": "9"}, false},
+		{map[string]string{"name": "1e400", "score": "12"}, true},
+		{map[string]string{"name": "abc"}, true},
+	}
+	for _, test := range tests {
+		if got := MatchesFilters(test.row, filters); got != test.want {
+			t.Errorf("MatchesFilters(%v): got %v, want %v", test.row, got, test.want)
+		}
+	}
+
+	data := []map[string]interface{}{
+		{"name": "abc", "score": int64(12)},
+		{"name": "abc", "score": int64(9)},
+		{"name": "abc", "score": 12.5},
+		{"name": "abc", "score": 9.5},
+	}
+	got, err := ApplyJSONTransformations(data, JSONTransformationRules{Filter: []JSONFilterRule{{Key: "*", Condition: "> 10"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0]["score"] != int64(12) || got[1]["score"] != 12.5 {
+		t.Errorf("ApplyJSONTransformations: got %v, want the rows with scores 12 and 12.5", got)
+	}
+}
+
+func TestApplyRecords(t *testing.T) {
+	in := testRecords(t,
+		`{"Region":"east","Amount":10}`,
+		`{"Region":"west","Amount":5}`,
+		`{"Region":"east","Amount":30}`,
+	)
+	rules := TransformationRules{
+		// Filters see the names before mapping, set rules the names after it
+		Filter:  []FilterRule{{Column: "Amount", Condition: "> 6"}},
+		Mapping: MappingRules{DynamicMapping: true},
+		Set:     []SetRule{{Field: "double", Expr: "amount * 2"}},
+		AggregationRules: AggregationRules{
+			GroupBy:     []string{"region"},
+			Aggregation: []AggregationRule{{Operation: "sum", Column: "double", As: "total"}},
+		},
+	}
+	got, err := ApplyRecords(in, rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"region":"east","total":80}`; recordsJSON(t, got) != want {
+		t.Errorf("got %s, want %s", recordsJSON(t, got), want)
+	}
+	// The input slice is left as it was
+	if len(in) != 3 {
+		t.Errorf("got %d input records, want 3", len(in))
+	}
+
+	// Rules that do not parse are reported
+	if _, err := ApplyRecords(in, TransformationRules{Filter: []FilterRule{{Condition: "amount >> 1"}}}); err == nil {
+		t.Error("invalid filter: expected an error")
+	}
+	if _, err := ApplyRecords(in, TransformationRules{Set: []SetRule{{Field: "x", Expr: "amount +"}}}); err == nil {
+		t.Error("invalid set rule: expected an error")
+	}
+	_, err = ApplyRecords(in, TransformationRules{Set: []SetRule{{Field: "x", Template: "{missing}"}}})
+	if err == nil || !strings.Contains(err.Error(), `field "missing" not found`) {
+		t.Errorf("failing set rule: got %v", err)
+	}
+}
@@ -1,15 +1,24 @@
 package transform
 
-func ApplyFirebaseTransformations(data []map[string]interface{}, mapping MappingRules) []map[string]interface{} {
-	for _, row := range data {
-		// Change the name field
-		if _, exists := row["name"]; exists {
-			row["name"] = mapping.NameChange
-		}
-		// Add new fields
-		for key, value := range mapping.AddField {
-			row[key] = value
+import "github.com/avii09/hookit/pkg/record"
+
+// ApplyMapping applies the mapping rules to records of any input type.
+// See MapRecord for the order in which the rules are applied.
+func ApplyMapping(data []map[string]interface{}, mapping MappingRules) ([]map[string]interface{}, error) {
+	for i, row := range data {
+		r, err := MapRecord(record.FromMap(row), mapping)
+		if err != nil {
+			return nil, err
 		}
+		data[i] = r.ToMap()
 	}
-	return data
+	return data, nil
+}
+
+// ApplyFirebaseTransformations changes the name field and adds constant
+// fields to each document, as the name_change and add_field mapping rules do.
+// The set transformation generalizes it to any field, computed values and
+// conditions, for records of every input type.
+func ApplyFirebaseTransformations(data []map[string]interface{}, mapping MappingRules) ([]map[string]interface{}, error) {
+	return ApplyMapping(data, MappingRules{NameChange: mapping.NameChange, AddField: mapping.AddField})
 }
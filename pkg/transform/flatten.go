@@ -0,0 +1,56 @@
+package transform
+
+import (
+	"github.com/avii09/hookit/pkg/record"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultFlattenSeparator joins the names of nested fields, as in "address.city".
+const defaultFlattenSeparator = "."
+
+// FlattenRules configures the flatten and unflatten transforms. In YAML they
+// are switched on with a boolean or with a mapping of options:
+//
+//	flatten: true
+//	unflatten:
+//	  separator: "_"
+type FlattenRules struct {
+	Enabled   bool   `yaml:"-"`
+	Separator string `yaml:"separator"`
+}
+
+// UnmarshalYAML accepts a boolean or a mapping of options, which enables the rules.
+func (f *FlattenRules) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*f = FlattenRules{}
+		return node.Decode(&f.Enabled)
+	}
+	type plain FlattenRules
+	if err := node.Decode((*plain)(f)); err != nil {
+		return err
+	}
+	f.Enabled = true
+	return nil
+}
+
+// SeparatorOrDefault returns the configured separator, or "." when none is set.
+func (f FlattenRules) SeparatorOrDefault() string {
+	if f.Separator == "" {
+		return defaultFlattenSeparator
+	}
+	return f.Separator
+}
+
+// FlattenRecord moves the fields of nested objects and arrays to the top level
+// of a record, as in "address.city" and "items[0].sku", so nested data can be
+// written to CSV.
+func FlattenRecord(r *record.Record, rules FlattenRules) *record.Record {
+	return record.Flatten(r, rules.SeparatorOrDefault())
+}
+
+// UnflattenRecord turns fields named like "address.city" and "items[0].sku"
+// into nested objects and arrays, so flat data such as CSV rows can be
+// written as nested JSON.
+func UnflattenRecord(r *record.Record, rules FlattenRules) *record.Record {
+	return record.Unflatten(r, rules.SeparatorOrDefault())
+}
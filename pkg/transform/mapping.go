@@ -0,0 +1,213 @@
+package transform
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/avii09/hookit/pkg/record"
+)
+
+// FieldMapping renames or copies the field From to To.
+type FieldMapping struct {
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+}
+
+// caseConventions lists the supported values of MappingRules.Case.
+var caseConventions = []string{"lower", "upper", "snake", "camel", "pascal", "kebab"}
+
+// MapRecord applies the mapping rules to a record, in this order:
+//
+//  1. case conversion of every field name (dynamic_mapping lowercases them)
+//  2. renames listed in custom_mapping
+//  3. copies listed in copy
+//  4. the name change and added fields
+//  5. drop, then the keep whitelist
+//  6. order, which moves the listed fields to the front
+//
+// Field names in later steps refer to the names produced by earlier ones.
+// Renames, copies, added fields, drop and keep accept paths into nested
+// values, such as "address.city" or "items[0].sku"; case conversion and
+// order apply to top-level fields.
+//
+// It fails when a rename, copy or added field cannot be set at its path, such
+// as a path through a string value. Renames that fail leave their value where
+// it was, but the rules applied before the failure are not undone.
+func MapRecord(r *record.Record, mapping MappingRules) (*record.Record, error) {
+	// Case conventions
+	if mapping.DynamicMapping {
+		renameAll(r, strings.ToLower)
+	}
+	if mapping.Case != "" {
+		renameAll(r, func(name string) string {
+			converted, err := ConvertCase(name, mapping.Case)
+			if err != nil {
+				return name
+			}
+			return converted
+		})
+	}
+
+	// Renames and copies
+	for _, m := range mapping.CustomMapping {
+		if _, err := r.RenamePath(m.From, m.To); err != nil {
+			return nil, fmt.Errorf("error renaming %q to %q: %w", m.From, m.To, err)
+		}
+	}
+	for _, m := range mapping.Copy {
+		if value, ok := r.Lookup(m.From); ok {
+			if err := r.SetPath(m.To, record.CopyValue(value)); err != nil {
+				return nil, fmt.Errorf("error copying %q to %q: %w", m.From, m.To, err)
+			}
+		}
+	}
+
+	// Change the name field and add new fields
+	if _, exists := r.Get("name"); exists && mapping.NameChange != "" {
+		r.Set("name", mapping.NameChange)
+	}
+	for _, key := range sortedMapKeys(mapping.AddField) {
+		if err := r.SetPath(key, mapping.AddField[key]); err != nil {
+			return nil, fmt.Errorf("error adding field %q: %w", key, err)
+		}
+	}
+
+	// Drops and whitelist
+	for _, path := range mapping.Drop {
+		r.DeletePath(path)
+	}
+	if len(mapping.Keep) > 0 {
+		keepPaths(r, mapping.Keep)
+	}
+
+	if len(mapping.Order) > 0 {
+		r.Reorder(mapping.Order)
+	}
+	return r, nil
+}
+
+// ConvertCase converts a field name to a case convention: "lower", "upper",
+// "snake" (first_name), "camel" (firstName), "pascal" (FirstName) or
+// "kebab" (first-name).
+func ConvertCase(name, convention string) (string, error) {
+	switch convention {
+	case "lower":
+		return strings.ToLower(name), nil
+	case "upper":
+		return strings.ToUpper(name), nil
+	}
+
+	words := splitWords(name)
+	if len(words) == 0 {
+		return name, nil
+	}
+	switch convention {
+	case "snake":
+		return strings.ToLower(strings.Join(words, "_")), nil
+	case "kebab":
+		return strings.ToLower(strings.Join(words, "-")), nil
+	case "camel", "pascal":
+		var b strings.Builder
+		for i, word := range words {
+			if i == 0 && convention == "camel" {
+				b.WriteString(strings.ToLower(word))
+				continue
+			}
+			b.WriteString(capitalize(word))
+		}
+		return b.String(), nil
+	}
+	return "", ValidateCase(convention)
+}
+
+// ValidateCase checks that a case convention is supported.
+func ValidateCase(convention string) error {
+	for _, c := range caseConventions {
+		if c == convention {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported case %q (supported: %s)", convention, strings.Join(caseConventions, ", "))
+}
+
+// Helper function to remove every field that is not on one of the kept paths.
+// Nested objects on a kept path are pruned to the kept fields.
+func keepPaths(r *record.Record, paths []string) {
+	kept := record.New()
+	for _, path := range paths {
+		value, ok := r.Lookup(path)
+		if !ok {
+			continue
+		}
+		if r.Has(path) {
+			kept.Set(path, value)
+		} else {
+			kept.SetPath(path, value)
+		}
+	}
+	for _, key := range r.Keys() {
+		if value, ok := kept.Get(key); ok {
+			r.Set(key, value)
+		} else {
+			r.Delete(key)
+		}
+	}
+}
+
+// Helper function to rename every field of a record
+func renameAll(r *record.Record, rename func(string) string) {
+	for _, key := range r.Keys() {
+		r.Rename(key, rename(key))
+	}
+}
+
+// Helper function to split a field name into words at separators and case changes,
+// so that "firstName", "first_name" and "First Name" all give [first name]
+func splitWords(name string) []string {
+	var words []string
+	var current []rune
+	flush := func() {
+		if len(current) > 0 {
+			words = append(words, string(current))
+			current = nil
+		}
+	}
+
+	runes := []rune(name)
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			flush()
+			continue
+		}
+		if unicode.IsUpper(r) && len(current) > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			// Split "firstName" before N and "HTTPServer" before S.
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				flush()
+			}
+		}
+		current = append(current, r)
+	}
+	flush()
+	return words
+}
+
+// Helper function to upper-case the first letter of a word and lower-case the rest
+func capitalize(word string) string {
+	runes := []rune(strings.ToLower(word))
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
+
+// Helper function to return the keys of a map in sorted order
+func sortedMapKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,231 @@
+package transform
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/avii09/hookit/pkg/record"
+)
+
+func TestMapRecord(t *testing.T) {
+	input := `{"id":1,"First Name":"ann","name":"a","address":{"city":"Pune","zip":"411001"},"items":[{"sku":"x"},{"sku":"y"}]}`
+	tests := []struct {
+		name    string
+		mapping MappingRules
+		want    string
+	}{
+		{
+			"rename keeps the position",
+			MappingRules{CustomMapping: []FieldMapping{{From: "id", To: "user_id"}}},
+			`{"user_id":1,"First Name":"ann","name":"a","address":{"city":"Pune","zip":"411001"},"items":[{"sku":"x"},{"sku":"y"}]}`,
+		},
+		{
+			"rename replaces an existing field",
+			MappingRules{CustomMapping: []FieldMapping{{From: "id", To: "name"}}},
+			`{"name":1,"First Name":"ann","address":{"city":"Pune","zip":"411001"},"items":[{"sku":"x"},{"sku":"y"}]}`,
+		},
+		{
+			"rename a missing field",
+			MappingRules{CustomMapping: []FieldMapping{{From: "missing", To: "x"}}},
+			input,
+		},
+		{
+			"rename out of a nested object",
+			MappingRules{CustomMapping: []FieldMapping{{From: "address.city", To: "city"}}},
+			`{"id":1,"First Name":"ann","name":"a","address":{"zip":"411001"},"items":[{"sku":"x"},{"sku":"y"}],"city":"Pune"}`,
+		},
+		{
+			"rename into a nested object",
+			MappingRules{CustomMapping: []FieldMapping{{From: "id", To: "meta.id"}}},
+			`{"First Name":"ann","name":"a","address":{"city":"Pune","zip":"411001"},"items":[{"sku":"x"},{"sku":"y"}],"meta":{"id":1}}`,
+		},
+		{
+			"renames apply in order",
+			MappingRules{CustomMapping: []FieldMapping{{From: "id", To: "a"}, {From: "a", To: "b"}}},
+			`{"b":1,"First Name":"ann","name":"a","address":{"city":"Pune","zip":"411001"},"items":[{"sku":"x"},{"sku":"y"}]}`,
+		},
+		{
+			"copy",
+			MappingRules{Copy: []FieldMapping{{From: "address.city", To: "city"}, {From: "items[1]", To: "last"}, {From: "missing", To: "x"}}},
+			`{"id":1,"First Name":"ann","name":"a","address":{"city":"Pune","zip":"411001"},"items":[{"sku":"x"},{"sku":"y"}],"city":"Pune","last":{"sku":"y"}}`,
+		},
+		{
+			"copy after rename sees the new name",
+			MappingRules{CustomMapping: []FieldMapping{{From: "id", To: "user_id"}}, Copy: []FieldMapping{{From: "user_id", To: "key"}}},
+			`{"user_id":1,"First Name":"ann","name":"a","address":{"city":"Pune","zip":"411001"},"items":[{"sku":"x"},{"sku":"y"}],"key":1}`,
+		},
+		{
+			"drop",
+			MappingRules{Drop: []string{"name", "address.zip", "items[0]", "missing"}},
+			`{"id":1,"First Name":"ann","address":{"city":"Pune"},"items":[{"sku":"y"}]}`,
+		},
+		{
+			"drop after copy",
+			MappingRules{Copy: []FieldMapping{{From: "address", To: "home"}}, Drop: []string{"address"}},
+			`{"id":1,"First Name":"ann","name":"a","items":[{"sku":"x"},{"sku":"y"}],"home":{"city":"Pune","zip":"411001"}}`,
+		},
+		{
+			"keep",
+			MappingRules{Keep: []string{"address.city", "id", "missing"}},
+			`{"id":1,"address":{"city":"Pune"}}`,
+		},
+		{
+			"drop wins over an added field",
+			MappingRules{AddField: map[string]string{"source": "api"}, Drop: []string{"source"}},
+			input,
+		},
+		{
+			"name change and added fields",
+			MappingRules{NameChange: "b", AddField: map[string]string{"source": "api", "meta.by": "etl"}},
+			`{"id":1,"First Name":"ann","name":"b","address":{"city":"Pune","zip":"411001"},"items":[{"sku":"x"},{"sku":"y"}],"meta":{"by":"etl"},"source":"api"}`,
+		},
+		{
+			"case before renames",
+			MappingRules{Case: "snake", CustomMapping: []FieldMapping{{From: "first_name", To: "given"}}},
+			`{"id":1,"given":"ann","name":"a","address":{"city":"Pune","zip":"411001"},"items":[{"sku":"x"},{"sku":"y"}]}`,
+		},
+		{
+			"dynamic mapping lowercases",
+			MappingRules{DynamicMapping: true},
+			`{"id":1,"first name":"ann","name":"a","address":{"city":"Pune","zip":"411001"},"items":[{"sku":"x"},{"sku":"y"}]}`,
+		},
+		{
+			"order",
+			MappingRules{Order: []string{"name", "missing", "id"}},
+			`{"name":"a","id":1,"First Name":"ann","address":{"city":"Pune","zip":"411001"},"items":[{"sku":"x"},{"sku":"y"}]}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, err := MapRecord(testRecord(t, input), test.mapping)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := recordsJSON(t, []*record.Record{r}); got != test.want {
+				t.Errorf("got  %s\nwant %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestMapRecordCopyIsIndependent(t *testing.T) {
+	r, err := MapRecord(testRecord(t, `{"address":{"city":"Pune"},"tags":["a"]}`), MappingRules{Copy: []FieldMapping{
+		{From: "address", To: "home"},
+		{From: "tags", To: "labels"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.SetPath("home.city", "Goa")
+	r.SetPath("labels[0]", "b")
+
+	want := `{"address":{"city":"Pune"},"tags":["a"],"home":{"city":"Goa"},"labels":["b"]}`
+	if got := recordsJSON(t, []*record.Record{r}); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestMapRecordErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		mapping MappingRules
+		err     string
+	}{
+		{
+			"rename onto a scalar",
+			MappingRules{CustomMapping: []FieldMapping{{From: "id", To: "name.id"}}},
+			`error renaming "id" to "name.id": cannot set "name.id": field "id" is inside a string value`,
+		},
+		{
+			"rename onto an out of range index",
+			MappingRules{CustomMapping: []FieldMapping{{From: "id", To: "items[3]"}}},
+			`error renaming "id" to "items[3]": cannot set "items[3]": [3] is past the end of an array of 0 items`,
+		},
+		{
+			"copy onto a scalar",
+			MappingRules{Copy: []FieldMapping{{From: "id", To: "name.id"}}},
+			`error copying "id" to "name.id": cannot set "name.id": field "id" is inside a string value`,
+		},
+		{
+			"added field onto a scalar",
+			MappingRules{AddField: map[string]string{"id.x": "1"}},
+			`error adding field "id.x": cannot set "id.x": field "x" is inside a int value`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := testRecord(t, `{"id":1,"name":"a","items":[]}`)
+			_, err := MapRecord(r, test.mapping)
+			if err == nil || err.Error() != test.err {
+				t.Fatalf("got error %v, want %s", err, test.err)
+			}
+			// A failed rename leaves its value where it was
+			if got, want := recordsJSON(t, []*record.Record{r}), `{"id":1,"name":"a","items":[]}`; got != want {
+				t.Errorf("got %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestNameChange(t *testing.T) {
+	mapping := MappingRules{NameChange: "anon", AddField: map[string]string{"source": "api"}}
+	tests := []struct {
+		object string
+		want   string
+	}{
+		{`{"id":1,"name":"ann"}`, `{"id":1,"name":"anon","source":"api"}`},
+		// A null name is a name field, and is changed too
+		{`{"id":2,"name":null}`, `{"id":2,"name":"anon","source":"api"}`},
+		{`{"id":3}`, `{"id":3,"source":"api"}`},
+	}
+	for _, test := range tests {
+		r, err := MapRecord(testRecord(t, test.object), mapping)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := recordsJSON(t, []*record.Record{r}); got != test.want {
+			t.Errorf("MapRecord(%s): got %s, want %s", test.object, got, test.want)
+		}
+
+		// The compatibility wrapper gives the same result
+		data, err := ApplyFirebaseTransformations([]map[string]interface{}{testRecord(t, test.object).ToMap()}, mapping)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := data[0], testRecord(t, test.want).ToMap(); !reflect.DeepEqual(got, want) {
+			t.Errorf("ApplyFirebaseTransformations(%s): got %v, want %v", test.object, got, want)
+		}
+	}
+}
+
+func TestConvertCase(t *testing.T) {
+	tests := []struct {
+		name       string
+		convention string
+		want       string
+	}{
+		{"firstName", "snake", "first_name"},
+		{"First Name", "snake", "first_name"},
+		{"HTTPServer", "snake", "http_server"},
+		{"user_id2", "camel", "userId2"},
+		{"first-name", "pascal", "FirstName"},
+		{"firstName", "kebab", "first-name"},
+		{"firstName", "upper", "FIRSTNAME"},
+		{"FirstName", "lower", "firstname"},
+		{"__", "snake", "__"},
+	}
+	for _, test := range tests {
+		got, err := ConvertCase(test.name, test.convention)
+		if err != nil {
+			t.Errorf("%s to %s: %v", test.name, test.convention, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s to %s: got %q, want %q", test.name, test.convention, got, test.want)
+		}
+	}
+
+	if _, err := ConvertCase("a", "title"); err == nil {
+		t.Error("title: expected an error")
+	}
+}
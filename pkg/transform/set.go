@@ -0,0 +1,148 @@
+package transform
+
+import (
+	"fmt"
+
+	"github.com/avii09/hookit/pkg/expr"
+	"github.com/avii09/hookit/pkg/record"
+)
+
+// SetRule assigns a value to a field, creating or replacing it. The value is
+// one of:
+//
+//   - value: a constant, such as "active", 10 or [a, b]; null when omitted
+//   - template: a string built from other fields, such as "{region}-{zip}"
+//   - expr: an expression over other fields, such as "price * quantity"
+//
+// With when, the field is only set on records that match the condition, such
+// as "total > 1000". Field may be a path into nested values.
+type SetRule struct {
+	Field    string      `yaml:"field"`
+	Value    interface{} `yaml:"value"`
+	Template string      `yaml:"template"`
+	Expr     string      `yaml:"expr"`
+	When     string      `yaml:"when"`
+}
+
+// Setter is a compiled set rule.
+type Setter struct {
+	field    string
+	value    interface{}
+	template *Template
+	expr     *expr.Expr
+	when     *expr.Expr
+}
+
+// CompileSet parses the template, expression and condition of a set rule.
+func CompileSet(rule SetRule) (*Setter, error) {
+	if err := record.ValidatePath(rule.Field); err != nil {
+		return nil, err
+	}
+	if rule.Template != "" && rule.Expr != "" {
+		return nil, fmt.Errorf("set rule for %q cannot have both a template and an expr", rule.Field)
+	}
+	if (rule.Template != "" || rule.Expr != "") && rule.Value != nil {
+		return nil, fmt.Errorf("set rule for %q cannot have a value along with a template or expr", rule.Field)
+	}
+
+	s := &Setter{field: rule.Field, value: record.Normalize(rule.Value)}
+	var err error
+	if rule.Template != "" {
+		if s.template, err = CompileTemplate(rule.Template); err != nil {
+			return nil, err
+		}
+	}
+	if rule.Expr != "" {
+		if s.expr, err = expr.Compile(rule.Expr); err != nil {
+			return nil, err
+		}
+	}
+	if rule.When != "" {
+		if s.when, err = expr.Compile(rule.When); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Apply sets the field on the record, unless the record does not match the
+// condition. Fields missing from a template are an error; an expression over
+// missing or non-numeric fields gives null.
+func (s *Setter) Apply(r *record.Record) error {
+	if s.when != nil && !s.when.Match(pathFields{r}) {
+		return nil
+	}
+
+	var value interface{}
+	switch {
+	case s.template != nil:
+		expanded, err := s.template.Expand(r)
+		if err != nil {
+			return err
+		}
+		value = expanded
+	case s.expr != nil:
+		value = record.Normalize(s.expr.Eval(pathFields{r}))
+	default:
+		// Each record gets its own copy of a constant array or object.
+		value = record.CopyValue(s.value)
+	}
+	return r.SetPath(s.field, value)
+}
+
+// ApplySetters applies setters to a record in order. If one fails, the fields
+// set by the ones before it are put back, so that the record is left as it was.
+func ApplySetters(r *record.Record, setters []*Setter) error {
+	type saved struct {
+		root    string
+		value   interface{}
+		existed bool
+	}
+	var undo []saved
+	for i, s := range setters {
+		// A setter that fails changes nothing, so the last needs no undo
+		if i < len(setters)-1 {
+			root := r.PathRoot(s.field)
+			value, existed := r.Get(root)
+			if root != s.field {
+				// A nested path changes the value in place
+				value = record.CopyValue(value)
+			}
+			undo = append(undo, saved{root, value, existed})
+		}
+		if err := s.Apply(r); err != nil {
+			for j := i - 1; j >= 0; j-- {
+				if undo[j].existed {
+					r.Set(undo[j].root, undo[j].value)
+				} else {
+					r.Delete(undo[j].root)
+				}
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// String describes the rule, as in `tier = "gold" when total > 1000`.
+func (s *Setter) String() string {
+	var value string
+	switch {
+	case s.template != nil:
+		value = fmt.Sprintf("template %q", s.template)
+	case s.expr != nil:
+		value = s.expr.String()
+	case s.value == nil:
+		value = "null"
+	default:
+		value = record.Format(s.value)
+		if _, ok := s.value.(string); ok {
+			value = fmt.Sprintf("%q", value)
+		}
+	}
+	desc := s.field + " = " + value
+	if s.when != nil {
+		desc += " when " + s.when.String()
+	}
+	return desc
+}
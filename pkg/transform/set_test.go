@@ -0,0 +1,143 @@
+package transform
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/avii09/hookit/pkg/record"
+)
+
+// Helper function to build a record from JSON
+func testRecord(t *testing.T, object string) *record.Record {
+	t.Helper()
+	records, err := record.DecodeJSON(strings.NewReader(object))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return records[0]
+}
+
+func TestSetterApply(t *testing.T) {
+	tests := []struct {
+		name string
+		rule SetRule
+		want string
+	}{
+		{"constant", SetRule{Field: "status", Value: "active"}, `"status":"active"`},
+		{"integer constant", SetRule{Field: "n", Value: 10}, `"n":10`},
+		{"array constant", SetRule{Field: "tags", Value: []interface{}{"a", "b"}}, `"tags":["a","b"]`},
+		{"null", SetRule{Field: "note"}, `"note":null`},
+		{"replace", SetRule{Field: "qty", Value: 4}, ``},
+		{"nested path", SetRule{Field: "meta.source", Value: "api"}, `"meta":{"source":"api"}`},
+		{"template", SetRule{Field: "key", Template: "{region}-{address.zip}"}, `"key":"east-411001"`},
+		{"integer expr", SetRule{Field: "total", Expr: "amount * qty"}, `"total":30`},
+		{"float expr", SetRule{Field: "half", Expr: "amount / 4"}, `"half":2.5`},
+		{"expr over a missing field", SetRule{Field: "x", Expr: "missing * 2"}, `"x":null`},
+		{"when matches", SetRule{Field: "tier", Value: "gold", When: "amount * qty > 20"}, `"tier":"gold"`},
+		{"when does not match", SetRule{Field: "tier", Value: "gold", When: "amount > 20"}, ``},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := testRecord(t, `{"region":"east","amount":10,"qty":3,"address":{"zip":"411001"}}`)
+			setter, err := CompileSet(test.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := setter.Apply(r); err != nil {
+				t.Fatal(err)
+			}
+			b, _ := r.MarshalJSON()
+			want := `{"region":"east","amount":10,"qty":3,"address":{"zip":"411001"}}`
+			if test.want != "" {
+				want = want[:len(want)-1] + "," + test.want + "}"
+			}
+			if test.rule.Field == "qty" {
+				want = strings.Replace(want, `"qty":3`, `"qty":4`, 1)
+			}
+			if string(b) != want {
+				t.Errorf("got %s, want %s", b, want)
+			}
+		})
+	}
+}
+
+func TestSetterErrors(t *testing.T) {
+	for _, rule := range []SetRule{
+		{Field: "", Value: 1},
+		{Field: "x", Template: "{a}", Expr: "a"},
+		{Field: "x", Value: 1, Expr: "a"},
+		{Field: "x", Template: "{a"},
+		{Field: "x", Expr: "a +"},
+		{Field: "x", Value: 1, When: "a >"},
+	} {
+		if _, err := CompileSet(rule); err == nil {
+			t.Errorf("CompileSet(%+v): expected an error", rule)
+		}
+	}
+
+	setter, err := CompileSet(SetRule{Field: "key", Template: "{missing}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := setter.Apply(record.New()); err == nil || !strings.Contains(err.Error(), `field "missing" not found`) {
+		t.Errorf("template with a missing field: got %v", err)
+	}
+}
+
+func TestSetterString(t *testing.T) {
+	tests := []struct {
+		rule SetRule
+		want string
+	}{
+		{SetRule{Field: "tier", Value: "gold", When: "total > 1000"}, `tier = "gold" when total > 1000`},
+		{SetRule{Field: "tags", Value: []interface{}{"a"}}, `tags = ["a"]`},
+		{SetRule{Field: "x"}, `x = null`},
+		{SetRule{Field: "key", Template: "{a}-{b}"}, `key = template "{a}-{b}"`},
+		{SetRule{Field: "total", Expr: "price * qty"}, `total = price * qty`},
+	}
+	for _, test := range tests {
+		setter, err := CompileSet(test.rule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := setter.String(); got != test.want {
+			t.Errorf("got %s, want %s", got, test.want)
+		}
+	}
+}
+
+func TestApplySetters(t *testing.T) {
+	var setters []*Setter
+	for _, rule := range []SetRule{
+		{Field: "total", Expr: "amount * 2"},
+		{Field: "address.zip", Value: "411002"},
+		{Field: "address.geo.lat", Value: 1.5},
+		{Field: "amount", Value: 0},
+		{Field: "key", Template: "{region}-{address.zip}"},
+	} {
+		setter, err := CompileSet(rule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		setters = append(setters, setter)
+	}
+
+	r := testRecord(t, `{"region":"east","amount":10,"address":{"zip":"411001"}}`)
+	if err := ApplySetters(r, setters); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"region":"east","amount":0,"address":{"zip":"411002","geo":{"lat":1.5}},"total":20,"key":"east-411002"}`
+	if b, _ := r.MarshalJSON(); string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+
+	// When the last setter fails, the record is left as it was
+	original := `{"amount":10,"address":{"zip":"411001"}}`
+	r = testRecord(t, original)
+	if err := ApplySetters(r, setters); err == nil || !strings.Contains(err.Error(), `field "region" not found`) {
+		t.Errorf("got error %v", err)
+	}
+	if b, _ := r.MarshalJSON(); string(b) != original {
+		t.Errorf("got %s, want the record unchanged: %s", b, original)
+	}
+}
@@ -0,0 +1,178 @@
+package transform
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/avii09/hookit/pkg/record"
+	"gopkg.in/yaml.v3"
+)
+
+// Transformations holds the transformations of a pipeline, written in YAML in
+// one of two forms. A mapping of rules runs each kind of rule once, in the
+// fixed order filter, mapping, set, aggregation, flatten, unflatten:
+//
+//	transformations:
+//	  filter: [...]
+//	  mapping: {...}
+//
+// A list of steps runs them in the order listed, and a type may appear more
+// than once:
+//
+//	transformations:
+//	  - type: mapping
+//	    custom_mapping: [{from: amt, to: amount}]
+//	  - type: filter
+//	    condition: "amount > 0"
+type Transformations struct {
+	TransformationRules `yaml:",inline"`
+	Steps               []Step `yaml:"-"` // nil for the mapping form
+}
+
+// UnmarshalYAML accepts either a mapping of rules or a list of steps.
+func (t *Transformations) UnmarshalYAML(node *yaml.Node) error {
+	*t = Transformations{}
+	if node.Kind == yaml.SequenceNode {
+		t.Steps = []Step{}
+		return node.Decode(&t.Steps)
+	}
+	return node.Decode(&t.TransformationRules)
+}
+
+// Step is one step of an ordered list of transformations. Type names the
+// stage, and the other fields of the step are its options:
+//
+//   - filter: a single filter rule (column and condition)
+//   - mapping: the mapping rules (custom_mapping, case, drop, ...)
+//   - set: a single set rule (field, value, template, expr and when)
+//   - aggregation: the aggregation rules (aggregation, group_by and
+//     aggregation_mode)
+//   - flatten, unflatten: the separator, if any
+//
+// The options of a type added with RegisterStepType are the whole rules. A
+// step of any other type has no options; ValidateStepType reports it.
+type Step struct {
+	Type  string
+	Rules TransformationRules // only the section of the step's type is set
+}
+
+// UnmarshalYAML decodes the options of the step according to its type.
+func (s *Step) UnmarshalYAML(node *yaml.Node) error {
+	var typed struct {
+		Type string `yaml:"type"`
+	}
+	if err := node.Decode(&typed); err != nil {
+		return err
+	}
+	*s = Step{Type: typed.Type}
+	options := s.Options()
+	if options == nil {
+		return nil
+	}
+	return node.Decode(options)
+}
+
+// Options returns a pointer to the part of the step's rules that its options
+// are decoded into, or nil when the step's type is not supported.
+func (s *Step) Options() interface{} {
+	switch s.Type {
+	case "filter":
+		if len(s.Rules.Filter) == 0 {
+			s.Rules.Filter = make([]FilterRule, 1)
+		}
+		return &s.Rules.Filter[0]
+	case "mapping":
+		return &s.Rules.Mapping
+	case "set":
+		if len(s.Rules.Set) == 0 {
+			s.Rules.Set = make([]SetRule, 1)
+		}
+		return &s.Rules.Set[0]
+	case "aggregation":
+		return &s.Rules.AggregationRules
+	case "flatten":
+		return &s.Rules.Flatten
+	case "unflatten":
+		return &s.Rules.Unflatten
+	}
+	if isRegisteredStepType(s.Type) {
+		return &s.Rules
+	}
+	return nil
+}
+
+var (
+	stepTypesMu sync.RWMutex
+	stepTypes   = make(map[string]bool) // step types registered besides the stages
+)
+
+// RegisterStepType makes a step type available besides the built-in stages,
+// for transformations added to a pipeline. The options of its steps are
+// decoded into the whole TransformationRules.
+func RegisterStepType(name string) {
+	for _, stage := range Stages {
+		if stage == name {
+			return
+		}
+	}
+	stepTypesMu.Lock()
+	defer stepTypesMu.Unlock()
+	stepTypes[name] = true
+}
+
+// Helper function to check if a step type was registered with RegisterStepType
+func isRegisteredStepType(name string) bool {
+	stepTypesMu.RLock()
+	defer stepTypesMu.RUnlock()
+	return stepTypes[name]
+}
+
+// stepAlternatives names the steps that do the work of step types that are
+// not supported.
+var stepAlternatives = map[string]string{
+	"rename": "a mapping step with custom_mapping",
+	"derive": "a set step with an expr",
+	"cast":   `a set step with an expr, such as "amount * 1" for a number`,
+}
+
+// ValidateStepType checks that a transformation step type is supported.
+func ValidateStepType(stepType string) error {
+	if alternative, ok := stepAlternatives[stepType]; ok {
+		return fmt.Errorf("unsupported transformation type %q, use %s", stepType, alternative)
+	}
+	for _, stage := range Stages {
+		if stage == stepType {
+			return nil
+		}
+	}
+	if isRegisteredStepType(stepType) {
+		return nil
+	}
+
+	supported := append([]string(nil), Stages...)
+	stepTypesMu.RLock()
+	registered := make([]string, 0, len(stepTypes))
+	for name := range stepTypes {
+		registered = append(registered, name)
+	}
+	stepTypesMu.RUnlock()
+	sort.Strings(registered)
+	supported = append(supported, registered...)
+	return fmt.Errorf("unsupported transformation type %q (supported: %s)", stepType, strings.Join(supported, ", "))
+}
+
+// ApplySteps applies each step to the records in turn.
+func ApplySteps(data []*record.Record, steps []Step) ([]*record.Record, error) {
+	for i, step := range steps {
+		if err := ValidateStepType(step.Type); err != nil {
+			return nil, fmt.Errorf("error applying step %d: %w", i+1, err)
+		}
+		var err error
+		if data, err = ApplyRecords(data, step.Rules); err != nil {
+			return nil, fmt.Errorf("error applying step %d (%s): %w", i+1, step.Type, err)
+		}
+	}
+	return data, nil
+}
@@ -0,0 +1,91 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/avii09/hookit/pkg/record"
+)
+
+// Template builds a string from the fields of a record. Field paths go in
+// braces, as in "{region}-{address.zip}"; "{{" and "}}" stand for literal
+// braces.
+type Template struct {
+	source string
+	parts  []templatePart
+}
+
+// templatePart is literal text or, when isField is set, a field path.
+type templatePart struct {
+	text    string
+	isField bool
+}
+
+// CompileTemplate parses a template.
+func CompileTemplate(s string) (*Template, error) {
+	t := &Template{source: s}
+	var literal strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case strings.HasPrefix(s[i:], "{{"), strings.HasPrefix(s[i:], "}}"):
+			literal.WriteByte(s[i])
+			i++
+		case s[i] == '{':
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid template %q: unclosed \"{\"", s)
+			}
+			path := strings.TrimSpace(s[i+1 : i+end])
+			if err := record.ValidatePath(path); err != nil {
+				return nil, fmt.Errorf("invalid template %q: %v", s, err)
+			}
+			if literal.Len() > 0 {
+				t.parts = append(t.parts, templatePart{text: literal.String()})
+				literal.Reset()
+			}
+			t.parts = append(t.parts, templatePart{text: path, isField: true})
+			i += end
+		case s[i] == '}':
+			return nil, fmt.Errorf("invalid template %q: unexpected \"}\" (use \"}}\" for a literal brace)", s)
+		default:
+			literal.WriteByte(s[i])
+		}
+	}
+	if literal.Len() > 0 {
+		t.parts = append(t.parts, templatePart{text: literal.String()})
+	}
+	return t, nil
+}
+
+// Expand fills in the fields of the record. It fails when a field is missing.
+func (t *Template) Expand(r *record.Record) (string, error) {
+	var b strings.Builder
+	for _, part := range t.parts {
+		if !part.isField {
+			b.WriteString(part.text)
+			continue
+		}
+		value, ok := r.Lookup(part.text)
+		if !ok {
+			return "", fmt.Errorf("template %q: field %q not found", t.source, part.text)
+		}
+		b.WriteString(record.Format(value))
+	}
+	return b.String(), nil
+}
+
+// Fields returns the field paths the template uses, in order.
+func (t *Template) Fields() []string {
+	var fields []string
+	for _, part := range t.parts {
+		if part.isField {
+			fields = append(fields, part.text)
+		}
+	}
+	return fields
+}
+
+// String returns the template as written.
+func (t *Template) String() string {
+	return t.source
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/avii09/hookit/pkg/config"
+	"github.com/avii09/hookit/pkg/pipeline"
+)
+
+// configList collects the values of a repeatable -config flag.
+type configList []string
+
+func (c *configList) String() string {
+	return strings.Join(*c, ",")
+}
+
+func (c *configList) Set(value string) error {
+	*c = append(*c, value)
+	return nil
+}
+
+// runCommand runs every pipeline given with -config or as a positional argument,
+// in order. It returns the process exit code.
+func runCommand(args []string) int {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	var configs configList
+	fs.Var(&configs, "config", "Path to a pipeline config file (may be repeated)")
+	failFast := fs.Bool("fail-fast", false, "Stop at the first pipeline that fails")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: hookit run -config <pipeline.yaml> [-config <pipeline.yaml> ...]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	configs = append(configs, fs.Args()...)
+
+	// Validate if a config is provided
+	if len(configs) == 0 {
+		fmt.Fprintln(os.Stderr, "Error: Missing required flag '-config'. Use 'hookit run -config path/to/pipeline.yaml'.")
+		return 2
+	}
+
+	failed := 0
+	for _, configFilePath := range configs {
+		if err := runPipeline(configFilePath); err != nil {
+			log.Printf("pipeline '%s' failed: %v", configFilePath, err)
+			failed++
+			if *failFast {
+				break
+			}
+			continue
+		}
+		log.Printf("pipeline '%s' completed successfully", configFilePath)
+	}
+
+	if failed > 0 {
+		if len(configs) > 1 {
+			log.Printf("%d of %d pipelines failed", failed, len(configs))
+		}
+		return 1
+	}
+	return 0
+}
+
+// runPipeline loads a config file and runs the pipeline it describes.
+func runPipeline(configFilePath string) error {
+	// Load the configuration file
+	cfg, err := config.LoadConfig(configFilePath)
+	if err != nil {
+		return fmt.Errorf("error loading config file: %w", err)
+	}
+
+	// Build the pipeline from the input and output types in the config
+	p, err := pipeline.New(cfg)
+	if err != nil {
+		return fmt.Errorf("error building pipeline: %w", err)
+	}
+	defer func() {
+		if cerr := p.Close(); cerr != nil {
+			log.Printf("error closing pipeline: %v", cerr)
+		}
+	}()
+
+	// Stop the pipeline and its workers on Ctrl-C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	return p.Run(ctx)
+}
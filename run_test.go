@@ -0,0 +1,119 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Helper function to write a JSON to JSON pipeline config and its input file,
+// returning the config path and the output path
+func writePipeline(t *testing.T, dir, name, input string) (string, string) {
+	t.Helper()
+	in := filepath.Join(dir, name+".json")
+	out := filepath.Join(dir, name+".out.json")
+	if err := os.WriteFile(in, []byte(input), 0644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name+".yaml")
+	content := `pipeline:
+  input:
+    type: json
+    config:
+      filePath: ` + in + `
+  output:
+    type: json
+    config:
+      filePath: ` + out + `
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path, out
+}
+
+// Helper function to hide the log and stderr output of a command
+func quiet(t *testing.T) {
+	t.Helper()
+	stderr := os.Stderr
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stderr = devNull
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() {
+		os.Stderr = stderr
+		log.SetOutput(stderr)
+		devNull.Close()
+	})
+}
+
+func TestRunCommand(t *testing.T) {
+	quiet(t)
+	dir := t.TempDir()
+	first, firstOut := writePipeline(t, dir, "first", `[{"id":1}]`)
+	second, secondOut := writePipeline(t, dir, "second", `[{"id":2}]`)
+	missing := filepath.Join(dir, "missing.yaml")
+
+	tests := []struct {
+		name string
+		args []string
+		code int
+		ran  []string
+	}{
+		{"no config", nil, 2, nil},
+		{"unknown flag", []string{"-pipeline", "csv"}, 2, nil},
+		{"repeated flag", []string{"-config", first, "-config", second}, 0, []string{firstOut, secondOut}},
+		{"positional configs", []string{first, second}, 0, []string{firstOut, secondOut}},
+		{"a failure does not stop later pipelines", []string{"-config", missing, "-config", second}, 1, []string{secondOut}},
+		{"fail fast", []string{"-fail-fast", "-config", missing, "-config", second}, 1, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			os.Remove(firstOut)
+			os.Remove(secondOut)
+			if code := runCommand(test.args); code != test.code {
+				t.Errorf("got exit code %d, want %d", code, test.code)
+			}
+			ran := make(map[string]bool)
+			for _, out := range test.ran {
+				ran[out] = true
+			}
+			for _, out := range []string{firstOut, secondOut} {
+				if _, err := os.Stat(out); (err == nil) != ran[out] {
+					t.Errorf("%s: got written %v, want %v", filepath.Base(out), err == nil, ran[out])
+				}
+			}
+		})
+	}
+}
+
+func TestRunPipeline(t *testing.T) {
+	dir := t.TempDir()
+	path, out := writePipeline(t, dir, "users", `[{"id":1,"name":"ann"}]`)
+	if err := runPipeline(path); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), "[\n  {\n    \"id\": 1,\n    \"name\": \"ann\"\n  }\n]"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// The types in the config decide what runs
+	bad := filepath.Join(dir, "bad.yaml")
+	if err := os.WriteFile(bad, []byte("pipeline:\n  input:\n    type: xml\n  output:\n    type: json\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := runPipeline(bad); err == nil {
+		t.Error("unsupported input type: expected an error")
+	}
+	if err := runPipeline(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Error("missing config: expected an error")
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/avii09/hookit/pkg/pipeline"
+)
+
+// initCommand writes a new pipeline config for the chosen input and output types.
+func initCommand(args []string) int {
+	fs := flag.NewFlagSet("init", flag.ContinueOnError)
+	inputType := fs.String("input", "csv", "Input type: "+strings.Join(pipeline.SourceTypes(), ", "))
+	outputType := fs.String("output", "json", "Output type: "+strings.Join(pipeline.SinkTypes(), ", "))
+	outPath := fs.String("o", "", "File to write the config to (default: stdout)")
+	force := fs.Bool("force", false, "Overwrite the file if it already exists")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: hookit init -input <type> -output <type> [-o pipeline.yaml]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if !pipeline.HasSource(*inputType) {
+		fmt.Fprintf(os.Stderr, "Error: unsupported input type '%s'. Supported types are: %s\n", *inputType, strings.Join(pipeline.SourceTypes(), ", "))
+		return 2
+	}
+	if !pipeline.HasSink(*outputType) {
+		fmt.Fprintf(os.Stderr, "Error: unsupported output type '%s'. Supported types are: %s\n", *outputType, strings.Join(pipeline.SinkTypes(), ", "))
+		return 2
+	}
+
+	content := scaffoldConfig(*inputType, *outputType)
+	if *outPath == "" {
+		fmt.Print(content)
+		return 0
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if *force {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	file, err := os.OpenFile(*outPath, flags, 0644)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	defer file.Close()
+	if _, err := file.WriteString(content); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Wrote %s -> %s pipeline config to %s\n", *inputType, *outputType, *outPath)
+	return 0
+}
+
+// scaffoldConfig returns a commented pipeline config for the input and output types.
+func scaffoldConfig(inputType, outputType string) string {
+	var b strings.Builder
+	b.WriteString("pipeline:\n")
+	b.WriteString("  input:\n")
+	fmt.Fprintf(&b, "    type: %q\n", inputType)
+	b.WriteString("    config:\n")
+	b.WriteString(endpointSettings(inputType, "input"))
+	b.WriteString(`
+  transformations:
+    filter:
+      - column: "age" # Column to filter on, or "*" for all numeric columns
+        condition: "> 25" # Keep rows where the condition holds, e.g. ">= 18 and < 65"
+
+    mapping:
+      dynamic_mapping: true # Automatically convert column names to lowercase
+
+    aggregation:
+      - operation: "count" # Options: sum, count, avg, min, max, count_distinct, median, percentile
+        column: "*"
+        as: "row_count"
+
+`)
+	b.WriteString("  output:\n")
+	fmt.Fprintf(&b, "    type: %q\n", outputType)
+	b.WriteString("    config:\n")
+	b.WriteString(endpointSettings(outputType, "output"))
+	return b.String()
+}
+
+// Helper function to return the config settings for an input or output type
+func endpointSettings(endpointType, direction string) string {
+	if endpointType == "firebase" {
+		return "      collection: \"users\" # Firestore collection name\n"
+	}
+	return fmt.Sprintf("      filePath: \"./data/%s.%s\" # Path to the %s %s file\n", direction, endpointType, direction, strings.ToUpper(endpointType))
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/avii09/hookit/pkg/config"
+	"github.com/avii09/hookit/pkg/pipeline"
+)
+
+// validateCommand loads each config file and reports every problem found
+// without running the pipeline. It returns the process exit code.
+func validateCommand(args []string) int {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	var configs configList
+	fs.Var(&configs, "config", "Path to a pipeline config file (may be repeated)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: hookit validate -config <pipeline.yaml> [-config <pipeline.yaml> ...]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	configs = append(configs, fs.Args()...)
+
+	if len(configs) == 0 {
+		fmt.Fprintln(os.Stderr, "Error: Missing required flag '-config'. Use 'hookit validate -config path/to/pipeline.yaml'.")
+		return 2
+	}
+
+	invalid := 0
+	for _, configFilePath := range configs {
+		errs := validateConfig(configFilePath)
+		if len(errs) == 0 {
+			fmt.Printf("%s: ok\n", configFilePath)
+			continue
+		}
+		invalid++
+		fmt.Printf("%s: %d problem(s)\n", configFilePath, len(errs))
+		for _, err := range errs {
+			fmt.Printf("  - %v\n", err)
+		}
+	}
+
+	if invalid > 0 {
+		return 1
+	}
+	return 0
+}
+
+// validateConfig returns every problem found in a config file, ordered by
+// position in the file.
+func validateConfig(configFilePath string) []error {
+	cfg, err := config.LoadConfig(configFilePath)
+
+	// A config that could be decoded is returned along with its problems,
+	// so the pipeline checks can still run on it.
+	var cfgErrs config.Errors
+	if err != nil && !errors.As(err, &cfgErrs) {
+		return []error{err}
+	}
+
+	var other []error
+	for _, err := range pipeline.Validate(cfg) {
+		var cfgErr *config.Error
+		if errors.As(err, &cfgErr) {
+			cfgErrs = append(cfgErrs, cfgErr)
+		} else {
+			other = append(other, err)
+		}
+	}
+	cfgErrs.Sort()
+
+	var errs []error
+	for _, cfgErr := range cfgErrs {
+		errs = append(errs, cfgErr)
+	}
+	return append(errs, other...)
+}